@@ -0,0 +1,119 @@
+package hftorderbook
+
+import (
+	"math/bits"
+)
+
+// Hierarchical bitmap of occupied level indexes: bit i of layer 0 is set if
+// index i is occupied, bit j of layer k+1 is set if word j of layer k is not
+// empty. Searching for the next or previous occupied index climbs up to the
+// first word with a set bit past the position and goes back down with
+// trailing/leading zero counts, so it touches at most 2*log64(N) words.
+type levelBitmap struct {
+	layers [][]uint64 // the last layer is a single word
+}
+
+func newLevelBitmap(n int) levelBitmap {
+	layers := make([][]uint64, 0)
+	for {
+		words := (n + 63) / 64
+		if words == 0 {
+			words = 1
+		}
+		layers = append(layers, make([]uint64, words))
+		if words == 1 {
+			break
+		}
+		n = words
+	}
+	return levelBitmap{layers: layers}
+}
+
+func (b *levelBitmap) has(i int) bool {
+	return b.layers[0][i >> 6] & (1 << (i & 63)) != 0
+}
+
+func (b *levelBitmap) set(i int) {
+	for _, words := range b.layers {
+		w := i >> 6
+		empty := words[w] == 0
+		words[w] |= 1 << (i & 63)
+		if !empty {
+			// upper layers already know about this word
+			return
+		}
+		i = w
+	}
+}
+
+func (b *levelBitmap) clear(i int) {
+	for _, words := range b.layers {
+		w := i >> 6
+		words[w] &^= 1 << (i & 63)
+		if words[w] != 0 {
+			return
+		}
+		i = w
+	}
+}
+
+func (b *levelBitmap) reset() {
+	for _, words := range b.layers {
+		clear(words)
+	}
+}
+
+// least set index >= i, -1 if there is none
+func (b *levelBitmap) next(i int) int {
+	layer := 0
+	for {
+		if layer == len(b.layers) {
+			return -1
+		}
+		words := b.layers[layer]
+		w := i >> 6
+		if w >= len(words) {
+			return -1
+		}
+		if word := words[w] & (^uint64(0) << (i & 63)); word != 0 {
+			i = w << 6 + bits.TrailingZeros64(word)
+			break
+		}
+		// nothing past i in this word, looking for the next non-empty word
+		i = w + 1
+		layer++
+	}
+
+	// descending to the least set index under the found word
+	for layer > 0 {
+		layer--
+		i = i << 6 + bits.TrailingZeros64(b.layers[layer][i])
+	}
+	return i
+}
+
+// greatest set index <= i, -1 if there is none
+func (b *levelBitmap) prev(i int) int {
+	layer := 0
+	for {
+		if layer == len(b.layers) || i < 0 {
+			return -1
+		}
+		words := b.layers[layer]
+		w := i >> 6
+		if word := words[w] & (^uint64(0) >> (63 - i & 63)); word != 0 {
+			i = w << 6 + 63 - bits.LeadingZeros64(word)
+			break
+		}
+		// nothing before i in this word, looking for the previous non-empty word
+		i = w - 1
+		layer++
+	}
+
+	// descending to the greatest set index under the found word
+	for layer > 0 {
+		layer--
+		i = i << 6 + 63 - bits.LeadingZeros64(b.layers[layer][i])
+	}
+	return i
+}
@@ -0,0 +1,72 @@
+package hftorderbook
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestLevelBitmapRandom(t *testing.T) {
+	for _, n := range []int{1, 64, 65, 4096, 4097, 300000} {
+		b := newLevelBitmap(n)
+		set := make([]bool, n)
+		for i := 0; i < 2000; i += 1 {
+			j := rand.Intn(n)
+			if rand.Intn(3) == 0 {
+				b.clear(j)
+				set[j] = false
+			} else {
+				b.set(j)
+				set[j] = true
+			}
+		}
+
+		for i := 0; i < 1000; i += 1 {
+			j := rand.Intn(n)
+			next, prev := -1, -1
+			for k := j; k < n; k += 1 {
+				if set[k] {
+					next = k
+					break
+				}
+			}
+			for k := j; k >= 0; k -= 1 {
+				if set[k] {
+					prev = k
+					break
+				}
+			}
+			if b.has(j) != set[j] || b.next(j) != next || b.prev(j) != prev {
+				t.Fatalf("n %d at %d: expected next %d and prev %d, got %d and %d", n, j, next, prev, b.next(j), b.prev(j))
+			}
+		}
+
+		b.reset()
+		if b.next(0) != -1 || b.prev(n - 1) != -1 {
+			t.Errorf("bitmap should be empty after reset")
+		}
+	}
+}
+
+func TestLevelBitmapEdges(t *testing.T) {
+	b := newLevelBitmap(64 * 64 * 2)
+	b.set(0)
+	b.set(64 * 64 * 2 - 1)
+	if b.next(1) != 64 * 64 * 2 - 1 || b.prev(64 * 64 * 2 - 2) != 0 {
+		t.Errorf("search should cross the upper layers")
+	}
+	if b.next(64 * 64 * 2) != -1 || b.prev(-1) != -1 {
+		t.Errorf("search out of the bitmap should find nothing")
+	}
+}
+
+func BenchmarkLevelBitmapNext(b *testing.B) {
+	bm := newLevelBitmap(1 << 20)
+	for i := 0; i < 1 << 20; i += 1 << 12 {
+		bm.set(i)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		bm.next(i & (1 << 20 - 1))
+	}
+}
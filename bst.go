@@ -1,37 +1,46 @@
 package hftorderbook
 
 import (
+	"cmp"
 	"fmt"
 )
 
 // Simple Binary Search Tree, not self-balancing, good for random input
 
-type nodeBST struct {
-	Key float64
-	Value *LimitOrder
-	Next *nodeBST
-	Prev *nodeBST
+type BSTNode[K cmp.Ordered, V any] struct {
+	Key K
+	Value V
+	Next *BSTNode[K, V]
+	Prev *BSTNode[K, V]
 	
-	left *nodeBST
-	right *nodeBST
+	left *BSTNode[K, V]
+	right *BSTNode[K, V]
 	size int
 }
 
-type bst struct {
-	root *nodeBST
-	minC *nodeBST // cached min/max keys for O(1) access
-	maxC *nodeBST
+type BST[K cmp.Ordered, V any] struct {
+	root *BSTNode[K, V]
+	minC *BSTNode[K, V] // cached min/max keys for O(1) access
+	maxC *BSTNode[K, V]
 }
 
+// price levels tree, the zero value of any BST is an empty tree
+type bst = BST[float64, *LimitOrder]
+type nodeBST = BSTNode[float64, *LimitOrder]
+
 func NewBST() bst {
 	return bst{}
 }
 
-func (t *bst) Size() int {
+func NewBSTOf[K cmp.Ordered, V any]() BST[K, V] {
+	return BST[K, V]{}
+}
+
+func (t *BST[K, V]) Size() int {
 	return t.size(t.root)
 }
 
-func (t *bst) size(n *nodeBST) int {
+func (t *BST[K, V]) size(n *BSTNode[K, V]) int {
 	if n == nil {
 		return 0
 	}
@@ -39,32 +48,32 @@ func (t *bst) size(n *nodeBST) int {
 	return n.size
 }
 
-func (t *bst) IsEmpty() bool {
+func (t *BST[K, V]) IsEmpty() bool {
 	return t.size(t.root) == 0
 }
 
-func (t *bst) panicIfEmpty() {
+func (t *BST[K, V]) panicIfEmpty() {
 	if t.IsEmpty() {
 		panic("BST is empty")
 	}
 }
 
-func (t *bst) Contains(key float64) bool {
+func (t *BST[K, V]) Contains(key K) bool {
 	return t.get(t.root, key) != nil
 }
 
-func (t *bst) Get(key float64) *LimitOrder {
+func (t *BST[K, V]) Get(key K) V {
 	t.panicIfEmpty()
 
 	x := t.get(t.root, key)
 	if x == nil {
-		panic(fmt.Sprintf("key %0.8f does not exist", key))
+		panic(fmt.Sprintf("key %v does not exist", key))
 	}
 
 	return x.Value
 }
 
-func (t *bst) get(n *nodeBST, key float64) *nodeBST {
+func (t *BST[K, V]) get(n *BSTNode[K, V], key K) *BSTNode[K, V] {
 	if n == nil {
 		return nil
 	}
@@ -80,14 +89,14 @@ func (t *bst) get(n *nodeBST, key float64) *nodeBST {
 	}
 }
 
-func (t *bst) Put(key float64, value *LimitOrder) {
+func (t *BST[K, V]) Put(key K, value V) {
 	t.root = t.put(t.root, key, value)
 }
 
-func (t *bst) put(n *nodeBST, key float64, value *LimitOrder) *nodeBST {
+func (t *BST[K, V]) put(n *BSTNode[K, V], key K, value V) *BSTNode[K, V] {
 	if n == nil {
 		// search miss, creating a new node
-		n := &nodeBST{
+		n := &BSTNode[K, V]{
 			Value: value,
 			Key: key,
 			size: 1,
@@ -144,7 +153,7 @@ func (t *bst) put(n *nodeBST, key float64, value *LimitOrder) *nodeBST {
 	return n
 }
 
-func (t *bst) Height() int {
+func (t *BST[K, V]) Height() int {
 	if t.IsEmpty() {
 		return 0
 	}
@@ -152,7 +161,7 @@ func (t *bst) Height() int {
 	return t.height(t.root)
 }
 
-func (t *bst) height(n *nodeBST) int {
+func (t *BST[K, V]) height(n *BSTNode[K, V]) int {
 	if n == nil {
 		return 0
 	}
@@ -168,22 +177,22 @@ func (t *bst) height(n *nodeBST) int {
 	return height + 1
 }
 
-func (t *bst) Min() float64 {
+func (t *BST[K, V]) Min() K {
 	t.panicIfEmpty()
 	return t.minC.Key
 }
 
-func (t *bst) MinValue() *LimitOrder {
+func (t *BST[K, V]) MinValue() V {
 	t.panicIfEmpty()
 	return t.minC.Value
 }
 
-func (t *bst) MinPointer() *nodeBST {
+func (t *BST[K, V]) MinPointer() *BSTNode[K, V] {
 	t.panicIfEmpty()
 	return t.minC
 }
 
-func (t *bst) min(n *nodeBST) *nodeBST {
+func (t *BST[K, V]) min(n *BSTNode[K, V]) *BSTNode[K, V] {
 	if n.left == nil {
 		return n
 	}
@@ -191,22 +200,22 @@ func (t *bst) min(n *nodeBST) *nodeBST {
 	return t.min(n.left)
 }
 
-func (t *bst) Max() float64 {
+func (t *BST[K, V]) Max() K {
 	t.panicIfEmpty()
 	return t.maxC.Key
 }
 
-func (t *bst) MaxValue() *LimitOrder {
+func (t *BST[K, V]) MaxValue() V {
 	t.panicIfEmpty()
 	return t.maxC.Value
 }
 
-func (t *bst) MaxPointer() *nodeBST {
+func (t *BST[K, V]) MaxPointer() *BSTNode[K, V] {
 	t.panicIfEmpty()
 	return t.maxC
 }
 
-func (t *bst) max(n *nodeBST) *nodeBST {
+func (t *BST[K, V]) max(n *BSTNode[K, V]) *BSTNode[K, V] {
 	if n.right == nil {
 		return n
 	}
@@ -214,18 +223,18 @@ func (t *bst) max(n *nodeBST) *nodeBST {
 	return t.max(n.right)
 }
 
-func (t *bst) Floor(key float64) float64 {
+func (t *BST[K, V]) Floor(key K) K {
 	t.panicIfEmpty()
 
 	floor := t.floor(t.root, key)
 	if floor == nil {
-		panic(fmt.Sprintf("there are no keys <= %0.8f", key))
+		panic(fmt.Sprintf("there are no keys <= %v", key))
 	}
 
 	return floor.Key
 }
 
-func (t *bst) floor(n *nodeBST, key float64) *nodeBST {
+func (t *BST[K, V]) floor(n *BSTNode[K, V], key K) *BSTNode[K, V] {
 	if n == nil {
 		// search miss
 		return nil
@@ -250,18 +259,18 @@ func (t *bst) floor(n *nodeBST, key float64) *nodeBST {
 	return n
 }
 
-func (t *bst) Ceiling(key float64) float64 {
+func (t *BST[K, V]) Ceiling(key K) K {
 	t.panicIfEmpty()
 
 	ceiling := t.ceiling(t.root, key)
 	if ceiling == nil {
-		panic(fmt.Sprintf("there are no keys >= %0.8f", key))
+		panic(fmt.Sprintf("there are no keys >= %v", key))
 	}
 
 	return ceiling.Key
 }
 
-func (t *bst) ceiling(n *nodeBST, key float64) *nodeBST {
+func (t *BST[K, V]) ceiling(n *BSTNode[K, V], key K) *BSTNode[K, V] {
 	if n == nil {
 		// search miss
 		return nil
@@ -286,7 +295,7 @@ func (t *bst) ceiling(n *nodeBST, key float64) *nodeBST {
 	return n
 }
 
-func (t *bst) Select(k int) float64 {
+func (t *BST[K, V]) Select(k int) K {
 	if k < 0 || k >= t.Size() {
 		panic("index out of range")
 	}
@@ -294,7 +303,7 @@ func (t *bst) Select(k int) float64 {
 	return t.selectNode(t.root, k).Key
 }
 
-func (t *bst) selectNode(n *nodeBST, k int) *nodeBST {
+func (t *BST[K, V]) selectNode(n *BSTNode[K, V], k int) *BSTNode[K, V] {
 	if t.size(n.left) == k {
 		return n
 	}
@@ -307,12 +316,12 @@ func (t *bst) selectNode(n *nodeBST, k int) *nodeBST {
 	return t.selectNode(n.right, k)
 }
 
-func (t *bst) Rank(key float64) int {
+func (t *BST[K, V]) Rank(key K) int {
 	t.panicIfEmpty()
 	return t.rank(t.root, key)
 }
 
-func (t *bst) rank(n *nodeBST, key float64) int {
+func (t *BST[K, V]) rank(n *BSTNode[K, V], key K) int {
 	if n == nil {
 		return 0
 	}
@@ -328,7 +337,7 @@ func (t *bst) rank(n *nodeBST, key float64) int {
 	return t.size(n.left) + 1 + t.rank(n.right, key)
 }
 
-func (t *bst) deleteMin(n *nodeBST) *nodeBST {
+func (t *BST[K, V]) deleteMin(n *BSTNode[K, V]) *BSTNode[K, V] {
 	if n == nil {
 		return nil
 	}
@@ -361,19 +370,33 @@ func (t *bst) deleteMin(n *nodeBST) *nodeBST {
 	return n
 }
 
-func (t *bst) Delete(key float64) {
+func (t *BST[K, V]) Delete(key K) {
 	t.panicIfEmpty()
 
 	t.root = t.delete(t.root, key)
 }
 
-func (t *bst) delete(n *nodeBST, key float64) *nodeBST {
+func (t *BST[K, V]) delete(n *BSTNode[K, V], key K) *BSTNode[K, V] {
 	if n == nil {
 		return nil
 	}
 
 	if n.Key == key {
 		// search hit
+		if n.left != nil && n.right != nil {
+			// replacing the key with the successor, its node is unlinked by deleteMin
+			// and the current one keeps its place in the linked list
+			rightMin := t.min(n.right)
+			n.Key = rightMin.Key
+			n.Value = rightMin.Value
+			n.right = t.deleteMin(n.right)
+
+			if t.maxC == rightMin {
+				t.maxC = n
+			}
+			n.size = t.size(n.left) + 1 + t.size(n.right)
+			return n
+		}
 
 		// updating linked list
 		next := n.Next
@@ -395,17 +418,10 @@ func (t *bst) delete(n *nodeBST, key float64) *nodeBST {
 			t.maxC = prev
 		}
 
-		// replacing by successor (we can do similar with precedessor)
 		if n.left == nil {
 			return n.right
-		} else if n.right == nil {
-			return n.left
 		}
-
-		newn := t.min(n.right)
-		newn.right = t.deleteMin(n.right)
-		newn.left = n.left
-		n = newn
+		return n.left
 	} else if n.Key > key {
 		n.left = t.delete(n.left, key)	
 	} else {
@@ -416,7 +432,67 @@ func (t *bst) delete(n *nodeBST, key float64) *nodeBST {
 	return n
 }
 
-func (t *bst) Keys(lo, hi float64) []float64 {
+// value of the least key > key, zero value if there is none
+func (t *BST[K, V]) Next(key K) V {
+	var next *BSTNode[K, V]
+	for n := t.root; n != nil; {
+		if n.Key > key {
+			next = n
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	if next == nil {
+		var zero V
+		return zero
+	}
+	return next.Value
+}
+
+// value of the greatest key < key, zero value if there is none
+func (t *BST[K, V]) Prev(key K) V {
+	var prev *BSTNode[K, V]
+	for n := t.root; n != nil; {
+		if n.Key < key {
+			prev = n
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	if prev == nil {
+		var zero V
+		return zero
+	}
+	return prev.Value
+}
+
+// walks the keys linked list from the ceiling of lo
+func (t *BST[K, V]) Range(lo, hi K, fn func(value V) bool) {
+	for n := t.ceiling(t.root, lo); n != nil && n.Key <= hi; n = n.Next {
+		if !fn(n.Value) {
+			return
+		}
+	}
+}
+
+// walks the keys linked list from the min or the max
+func (t *BST[K, V]) Walk(descending bool, fn func(value V) bool) {
+	n := t.minC
+	if descending {
+		n = t.maxC
+	}
+	for n != nil && fn(n.Value) {
+		if descending {
+			n = n.Prev
+		} else {
+			n = n.Next
+		}
+	}
+}
+
+func (t *BST[K, V]) Keys(lo, hi K) []K {
 	if lo < t.Min() || hi > t.Max() {
 		panic("keys out of range")
 	}
@@ -424,7 +500,7 @@ func (t *bst) Keys(lo, hi float64) []float64 {
 	return t.keys(t.root, lo, hi)
 }
 
-func (t *bst) keys(n *nodeBST, lo, hi float64) []float64 {
+func (t *BST[K, V]) keys(n *BSTNode[K, V], lo, hi K) []K {
 	if n == nil {
 		return nil
 	}
@@ -438,7 +514,7 @@ func (t *bst) keys(n *nodeBST, lo, hi float64) []float64 {
 	l := t.keys(n.left, lo, hi)
 	r := t.keys(n.right, lo, hi)
 	
-	keys := make([]float64, 0)
+	keys := make([]K, 0)
 	if l != nil {
 		keys = append(keys, l...)
 	}
@@ -450,18 +526,18 @@ func (t *bst) keys(n *nodeBST, lo, hi float64) []float64 {
 	return keys
 }
 
-func (t *bst) Print() {
+func (t *BST[K, V]) Print() {
 	fmt.Println()
 	t.print(t.root)
 	fmt.Println()
 }
 
-func (t *bst) print(n *nodeBST) {
+func (t *BST[K, V]) print(n *BSTNode[K, V]) {
 	if n == nil {
 		return
 	}
 
-	fmt.Printf("%0.8f ", n.Key)
+	fmt.Printf("%v ", n.Key)
 
 	t.print(n.left)
 	t.print(n.right)
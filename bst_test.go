@@ -3,6 +3,7 @@ package hftorderbook
 import (
 	"testing"
 	"math/rand"
+	"cmp"
 	//"fmt"
 )
 
@@ -288,6 +289,27 @@ func TestBSTPutDeleteLinkedListOrder(t *testing.T) {
 	}
 }
 
+func TestBSTDeleteKeepsLinkedList(t *testing.T) {
+	st := NewBST()
+	for i := 0; i < 1000; i += 1 {
+		st.Put(rand.Float64(), nil)
+	}
+
+	for st.Size() > 1 {
+		st.Delete(st.Select(rand.Intn(st.Size())))
+
+		// nodes replaced by their successors should stay in the list
+		count := 0
+		for p := st.MinPointer(); p != nil; p = p.Next {
+			count += 1
+		}
+		if count != st.Size() || st.MaxPointer().Key != st.Select(st.Size() - 1) {
+			t.Errorf("keys list is detached from the tree")
+			break
+		}
+	}
+}
+
 func BenchmarkBSTLimitedRandomInsertWithCaching(b *testing.B) {
 	st := NewBST()
 
@@ -337,3 +359,40 @@ func BenchmarkBSTLimitedRandomInsertWithCaching(b *testing.B) {
 		}
 	}
 }
+
+func TestBSTGenericKeys(t *testing.T) {
+	// expiries by unix time
+	st := NewBSTOf[int64, int]()
+	for i, k := range []int64{1700000300, 1700000100, 1700000200} {
+		st.Put(k, i)
+	}
+	if st.Min() != 1700000100 || st.MaxValue() != 0 || st.Next(1700000100) != 2 {
+		t.Errorf("invalid generic tree")
+	}
+}
+
+func benchmarkBSTPutDelete[K cmp.Ordered](key func(x float64) K, b *testing.B) {
+	st := NewBSTOf[K, *LimitOrder]()
+	for i := 0; i < 10000; i += 1 {
+		st.Put(key(rand.Float64()), nil)
+	}
+	keys := make([]K, 1024)
+	for i := range keys {
+		keys[i] = key(rand.Float64())
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		k := keys[i & 1023]
+		st.Put(k, nil)
+		st.Delete(k)
+	}
+}
+
+func BenchmarkBSTPutDelete(b *testing.B) {
+	benchmarkBSTPutDelete(func(x float64) float64 { return x }, b)
+}
+
+func BenchmarkBSTInt64PutDelete(b *testing.B) {
+	benchmarkBSTPutDelete(func(x float64) int64 { return int64(x * 1e8) }, b)
+}
@@ -0,0 +1,594 @@
+package hftorderbook
+
+import (
+	"cmp"
+	"fmt"
+)
+
+// B-tree with up to 2*bTreeDegree-1 keys per node kept in contiguous slices,
+// a search touches lgN/lg(bTreeDegree) nodes instead of lgN for binary trees.
+// Every node counts the keys of its subtree for select and rank.
+
+const bTreeDegree = 32
+
+type bTreeNode[K cmp.Ordered, V any] struct {
+	keys []K
+	values []V
+	children []*bTreeNode[K, V] // nil for leaves
+	size int
+}
+
+func (n *bTreeNode[K, V]) isLeaf() bool {
+	return n.children == nil
+}
+
+// index of the first key >= key
+func (n *bTreeNode[K, V]) lowerBound(key K) int {
+	lo, hi := 0, len(n.keys)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if n.keys[mid] < key {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// index of the first key > key
+func (n *bTreeNode[K, V]) upperBound(key K) int {
+	lo, hi := 0, len(n.keys)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if n.keys[mid] <= key {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+type BTree[K cmp.Ordered, V any] struct {
+	root *bTreeNode[K, V]
+}
+
+// price levels B-tree, the zero value of any BTree is an empty tree
+type bTree = BTree[float64, *LimitOrder]
+
+func NewBTree() bTree {
+	return bTree{}
+}
+
+func NewBTreeOf[K cmp.Ordered, V any]() BTree[K, V] {
+	return BTree[K, V]{}
+}
+
+func (t *BTree[K, V]) newNode(leaf bool) *bTreeNode[K, V] {
+	n := &bTreeNode[K, V]{
+		keys: make([]K, 0, 2*bTreeDegree - 1),
+		values: make([]V, 0, 2*bTreeDegree - 1),
+	}
+	if !leaf {
+		n.children = make([]*bTreeNode[K, V], 0, 2*bTreeDegree)
+	}
+	return n
+}
+
+func (t *BTree[K, V]) Size() int {
+	if t.root == nil {
+		return 0
+	}
+	return t.root.size
+}
+
+func (t *BTree[K, V]) IsEmpty() bool {
+	return t.Size() == 0
+}
+
+func (t *BTree[K, V]) panicIfEmpty() {
+	if t.IsEmpty() {
+		panic("B-tree is empty")
+	}
+}
+
+// node and index of the key, nil if there is no such key
+func (t *BTree[K, V]) get(key K) (*bTreeNode[K, V], int) {
+	for n := t.root; n != nil; {
+		i := n.lowerBound(key)
+		if i < len(n.keys) && n.keys[i] == key {
+			return n, i
+		}
+		if n.isLeaf() {
+			break
+		}
+		n = n.children[i]
+	}
+	return nil, 0
+}
+
+func (t *BTree[K, V]) Contains(key K) bool {
+	n, _ := t.get(key)
+	return n != nil
+}
+
+func (t *BTree[K, V]) Get(key K) V {
+	t.panicIfEmpty()
+
+	n, i := t.get(key)
+	if n == nil {
+		panic(fmt.Sprintf("key %v does not exist", key))
+	}
+	return n.values[i]
+}
+
+func (t *BTree[K, V]) Put(key K, value V) {
+	if n, i := t.get(key); n != nil {
+		// search hit, updating the value
+		n.values[i] = value
+		return
+	}
+
+	if t.root == nil {
+		t.root = t.newNode(true)
+	}
+	if len(t.root.keys) == 2*bTreeDegree - 1 {
+		// splitting the full root, the tree grows in height
+		root := t.newNode(false)
+		root.children = append(root.children, t.root)
+		root.size = t.root.size
+		t.split(root, 0)
+		t.root = root
+	}
+	t.put(t.root, key, value)
+}
+
+// inserts the new key into the subtree of the non-full node
+func (t *BTree[K, V]) put(n *bTreeNode[K, V], key K, value V) {
+	for {
+		n.size++
+		i := n.lowerBound(key)
+		if n.isLeaf() {
+			n.keys = append(n.keys, key)
+			n.values = append(n.values, value)
+			copy(n.keys[i+1:], n.keys[i:])
+			copy(n.values[i+1:], n.values[i:])
+			n.keys[i] = key
+			n.values[i] = value
+			return
+		}
+
+		if len(n.children[i].keys) == 2*bTreeDegree - 1 {
+			// splitting on the way down, so there is always room for the median
+			t.split(n, i)
+			if key > n.keys[i] {
+				i++
+			}
+		}
+		n = n.children[i]
+	}
+}
+
+// moves the upper half of the full i-th child into a new node and the median up
+func (t *BTree[K, V]) split(n *bTreeNode[K, V], i int) {
+	y := n.children[i]
+	z := t.newNode(y.isLeaf())
+	m := bTreeDegree - 1
+
+	z.keys = append(z.keys, y.keys[m+1:]...)
+	z.values = append(z.values, y.values[m+1:]...)
+	z.size = len(z.keys)
+	if !y.isLeaf() {
+		z.children = append(z.children, y.children[m+1:]...)
+		for _, c := range z.children {
+			z.size += c.size
+		}
+		clear(y.children[m+1:])
+		y.children = y.children[:m+1]
+	}
+
+	key, value := y.keys[m], y.values[m]
+	clear(y.values[m:])
+	y.keys = y.keys[:m]
+	y.values = y.values[:m]
+	y.size -= z.size + 1
+
+	n.keys = append(n.keys, key)
+	n.values = append(n.values, value)
+	copy(n.keys[i+1:], n.keys[i:])
+	copy(n.values[i+1:], n.values[i:])
+	n.keys[i] = key
+	n.values[i] = value
+
+	n.children = append(n.children, nil)
+	copy(n.children[i+2:], n.children[i+1:])
+	n.children[i+1] = z
+}
+
+func (t *BTree[K, V]) Delete(key K) {
+	t.panicIfEmpty()
+
+	if n, _ := t.get(key); n == nil {
+		// search miss
+		return
+	}
+
+	t.delete(t.root, key)
+	if len(t.root.keys) == 0 {
+		// the tree shrinks in height
+		if t.root.isLeaf() {
+			t.root = nil
+		} else {
+			t.root = t.root.children[0]
+		}
+	}
+}
+
+// removes the existing key from the subtree, every child is made to have
+// at least bTreeDegree keys before going down into it
+func (t *BTree[K, V]) delete(n *bTreeNode[K, V], key K) {
+	for {
+		n.size--
+		i := n.lowerBound(key)
+		found := i < len(n.keys) && n.keys[i] == key
+
+		if n.isLeaf() {
+			t.remove(n, i)
+			return
+		}
+
+		if found {
+			if left := n.children[i]; len(left.keys) >= bTreeDegree {
+				// replacing with the predecessor
+				p := left
+				for !p.isLeaf() {
+					p = p.children[len(p.children) - 1]
+				}
+				key = p.keys[len(p.keys) - 1]
+				n.keys[i], n.values[i] = key, p.values[len(p.values) - 1]
+				n = left
+				continue
+			}
+			if right := n.children[i+1]; len(right.keys) >= bTreeDegree {
+				// replacing with the successor
+				s := right
+				for !s.isLeaf() {
+					s = s.children[0]
+				}
+				key = s.keys[0]
+				n.keys[i], n.values[i] = key, s.values[0]
+				n = right
+				continue
+			}
+			// both children are minimal, the key goes down with the merge
+			t.merge(n, i)
+			n = n.children[i]
+			continue
+		}
+
+		if len(n.children[i].keys) < bTreeDegree {
+			i = t.fill(n, i)
+		}
+		n = n.children[i]
+	}
+}
+
+// removes the i-th key of the leaf
+func (t *BTree[K, V]) remove(n *bTreeNode[K, V], i int) {
+	var zero V
+	copy(n.keys[i:], n.keys[i+1:])
+	copy(n.values[i:], n.values[i+1:])
+	n.values[len(n.values) - 1] = zero
+	n.keys = n.keys[:len(n.keys) - 1]
+	n.values = n.values[:len(n.values) - 1]
+}
+
+// gives the minimal i-th child one more key from a sibling or merges it with
+// one, returns the index of the child now holding its keys
+func (t *BTree[K, V]) fill(n *bTreeNode[K, V], i int) int {
+	child := n.children[i]
+	if i > 0 && len(n.children[i-1].keys) >= bTreeDegree {
+		// rotating right through the separator
+		left := n.children[i-1]
+		last := len(left.keys) - 1
+
+		child.keys = append(child.keys, n.keys[i-1])
+		child.values = append(child.values, n.values[i-1])
+		copy(child.keys[1:], child.keys)
+		copy(child.values[1:], child.values)
+		child.keys[0], child.values[0] = n.keys[i-1], n.values[i-1]
+		n.keys[i-1], n.values[i-1] = left.keys[last], left.values[last]
+		t.remove(left, last)
+
+		moved := 1
+		if !child.isLeaf() {
+			c := left.children[len(left.children) - 1]
+			left.children[len(left.children) - 1] = nil
+			left.children = left.children[:len(left.children) - 1]
+			child.children = append(child.children, nil)
+			copy(child.children[1:], child.children)
+			child.children[0] = c
+			moved += c.size
+		}
+		left.size -= moved
+		child.size += moved
+		return i
+	}
+
+	if i < len(n.children) - 1 && len(n.children[i+1].keys) >= bTreeDegree {
+		// rotating left through the separator
+		right := n.children[i+1]
+
+		child.keys = append(child.keys, n.keys[i])
+		child.values = append(child.values, n.values[i])
+		n.keys[i], n.values[i] = right.keys[0], right.values[0]
+		t.remove(right, 0)
+
+		moved := 1
+		if !child.isLeaf() {
+			c := right.children[0]
+			copy(right.children, right.children[1:])
+			right.children[len(right.children) - 1] = nil
+			right.children = right.children[:len(right.children) - 1]
+			child.children = append(child.children, c)
+			moved += c.size
+		}
+		right.size -= moved
+		child.size += moved
+		return i
+	}
+
+	if i == len(n.children) - 1 {
+		// the last child merges into its left sibling
+		i--
+	}
+	t.merge(n, i)
+	return i
+}
+
+// merges the i+1-th child and the i-th separator into the i-th child
+func (t *BTree[K, V]) merge(n *bTreeNode[K, V], i int) {
+	left, right := n.children[i], n.children[i+1]
+
+	left.keys = append(left.keys, n.keys[i])
+	left.values = append(left.values, n.values[i])
+	left.keys = append(left.keys, right.keys...)
+	left.values = append(left.values, right.values...)
+	if !left.isLeaf() {
+		left.children = append(left.children, right.children...)
+	}
+	left.size += right.size + 1
+
+	t.remove(n, i)
+	copy(n.children[i+1:], n.children[i+2:])
+	n.children[len(n.children) - 1] = nil
+	n.children = n.children[:len(n.children) - 1]
+}
+
+func (t *BTree[K, V]) minNode() *bTreeNode[K, V] {
+	t.panicIfEmpty()
+	n := t.root
+	for !n.isLeaf() {
+		n = n.children[0]
+	}
+	return n
+}
+
+func (t *BTree[K, V]) maxNode() *bTreeNode[K, V] {
+	t.panicIfEmpty()
+	n := t.root
+	for !n.isLeaf() {
+		n = n.children[len(n.children) - 1]
+	}
+	return n
+}
+
+func (t *BTree[K, V]) Min() K {
+	return t.minNode().keys[0]
+}
+
+func (t *BTree[K, V]) MinValue() V {
+	return t.minNode().values[0]
+}
+
+func (t *BTree[K, V]) Max() K {
+	n := t.maxNode()
+	return n.keys[len(n.keys) - 1]
+}
+
+func (t *BTree[K, V]) MaxValue() V {
+	n := t.maxNode()
+	return n.values[len(n.values) - 1]
+}
+
+// greatest key <= key (or < key if strict), nil node if there is none
+func (t *BTree[K, V]) floor(key K, strict bool) (*bTreeNode[K, V], int) {
+	var best *bTreeNode[K, V]
+	index := 0
+	for n := t.root; n != nil; {
+		var i int
+		if strict {
+			i = n.lowerBound(key)
+		} else {
+			i = n.upperBound(key)
+		}
+		if i > 0 {
+			best, index = n, i - 1
+		}
+		if n.isLeaf() {
+			break
+		}
+		n = n.children[i]
+	}
+	return best, index
+}
+
+// least key >= key (or > key if strict), nil node if there is none
+func (t *BTree[K, V]) ceiling(key K, strict bool) (*bTreeNode[K, V], int) {
+	var best *bTreeNode[K, V]
+	index := 0
+	for n := t.root; n != nil; {
+		var i int
+		if strict {
+			i = n.upperBound(key)
+		} else {
+			i = n.lowerBound(key)
+		}
+		if i < len(n.keys) {
+			best, index = n, i
+		}
+		if n.isLeaf() {
+			break
+		}
+		n = n.children[i]
+	}
+	return best, index
+}
+
+func (t *BTree[K, V]) Floor(key K) K {
+	t.panicIfEmpty()
+
+	n, i := t.floor(key, false)
+	if n == nil {
+		panic(fmt.Sprintf("there are no keys <= %v", key))
+	}
+	return n.keys[i]
+}
+
+func (t *BTree[K, V]) Ceiling(key K) K {
+	t.panicIfEmpty()
+
+	n, i := t.ceiling(key, false)
+	if n == nil {
+		panic(fmt.Sprintf("there are no keys >= %v", key))
+	}
+	return n.keys[i]
+}
+
+// value of the least key > key, zero value if there is none
+func (t *BTree[K, V]) Next(key K) V {
+	n, i := t.ceiling(key, true)
+	if n == nil {
+		var zero V
+		return zero
+	}
+	return n.values[i]
+}
+
+// value of the greatest key < key, zero value if there is none
+func (t *BTree[K, V]) Prev(key K) V {
+	n, i := t.floor(key, true)
+	if n == nil {
+		var zero V
+		return zero
+	}
+	return n.values[i]
+}
+
+// k-th smallest key, 0-based
+func (t *BTree[K, V]) Select(k int) K {
+	if k < 0 || k >= t.Size() {
+		panic("index out of range")
+	}
+
+	n := t.root
+	for !n.isLeaf() {
+		i := 0
+		for ; i < len(n.keys); i++ {
+			size := n.children[i].size
+			if k < size {
+				break
+			}
+			if k == size {
+				return n.keys[i]
+			}
+			k -= size + 1
+		}
+		n = n.children[i]
+	}
+	return n.keys[k]
+}
+
+// number of keys < key
+func (t *BTree[K, V]) Rank(key K) int {
+	rank := 0
+	for n := t.root; n != nil; {
+		i := n.lowerBound(key)
+		rank += i
+		if n.isLeaf() {
+			break
+		}
+		for _, c := range n.children[:i] {
+			rank += c.size
+		}
+		if i < len(n.keys) && n.keys[i] == key {
+			rank += n.children[i].size
+			break
+		}
+		n = n.children[i]
+	}
+	return rank
+}
+
+func (t *BTree[K, V]) Range(lo, hi K, fn func(value V) bool) {
+	if t.root != nil {
+		t.walk(t.root, lo, hi, func(key K, value V) bool {
+			return fn(value)
+		})
+	}
+}
+
+// in-order walk of the keys between lo and hi, false if fn stopped it
+func (t *BTree[K, V]) walk(n *bTreeNode[K, V], lo, hi K, fn func(key K, value V) bool) bool {
+	for i := n.lowerBound(lo); i <= len(n.keys); i++ {
+		if !n.isLeaf() && !t.walk(n.children[i], lo, hi, fn) {
+			return false
+		}
+		if i == len(n.keys) || n.keys[i] > hi {
+			return i == len(n.keys)
+		}
+		if !fn(n.keys[i], n.values[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// in-order walk of all keys in either direction
+func (t *BTree[K, V]) Walk(descending bool, fn func(value V) bool) {
+	if t.root != nil {
+		t.walkAll(t.root, descending, fn)
+	}
+}
+
+// false if fn stopped the walk
+func (t *BTree[K, V]) walkAll(n *bTreeNode[K, V], descending bool, fn func(value V) bool) bool {
+	for j := 0; j <= len(n.keys); j++ {
+		// child i is followed by key i going up and preceded by key i-1 going down
+		i, k := j, j
+		if descending {
+			i, k = len(n.keys) - j, len(n.keys) - j - 1
+		}
+		if !n.isLeaf() && !t.walkAll(n.children[i], descending, fn) {
+			return false
+		}
+		if j < len(n.keys) && !fn(n.values[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *BTree[K, V]) Keys(lo, hi K) []K {
+	if lo < t.Min() || hi > t.Max() {
+		panic("keys out of range")
+	}
+
+	keys := make([]K, 0)
+	t.walk(t.root, lo, hi, func(key K, value V) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
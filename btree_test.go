@@ -0,0 +1,65 @@
+package hftorderbook
+
+import (
+	"cmp"
+	"math/rand"
+	"testing"
+)
+
+// checks node fill, key order and subtree sizes
+func checkBTree[K cmp.Ordered, V any](t *testing.T, n *bTreeNode[K, V], root bool) int {
+	if !root && len(n.keys) < bTreeDegree - 1 || len(n.keys) > 2*bTreeDegree - 1 {
+		t.Fatalf("node has %d keys", len(n.keys))
+	}
+	for i := 1; i < len(n.keys); i += 1 {
+		if n.keys[i - 1] >= n.keys[i] {
+			t.Fatalf("node keys are out of order")
+		}
+	}
+	size := len(n.keys)
+	if !n.isLeaf() {
+		if len(n.children) != len(n.keys) + 1 {
+			t.Fatalf("node has %d keys and %d children", len(n.keys), len(n.children))
+		}
+		for i, c := range n.children {
+			if i > 0 && c.keys[0] <= n.keys[i - 1] || i < len(n.keys) && c.keys[len(c.keys) - 1] >= n.keys[i] {
+				t.Fatalf("child keys are out of the separators")
+			}
+			size += checkBTree(t, c, false)
+		}
+	}
+	if size != n.size {
+		t.Fatalf("node size %d, expected %d", n.size, size)
+	}
+	return size
+}
+
+// splits on the way up and merges or borrows on the way down keep the nodes valid
+func TestBTreeNodes(t *testing.T) {
+	st := NewBTreeOf[int, int]()
+	for i := 0; i < 20000; i += 1 {
+		k := rand.Intn(5000)
+		if rand.Intn(3) == 0 && !st.IsEmpty() {
+			st.Delete(k)
+		} else {
+			st.Put(k, k)
+		}
+		if i % 1000 == 0 && !st.IsEmpty() {
+			checkBTree(t, st.root, true)
+		}
+	}
+	checkBTree(t, st.root, true)
+
+	// ascending and descending runs, the worst cases for splits and merges
+	for i := 0; i < 5000; i += 1 {
+		st.Put(i, i)
+	}
+	checkBTree(t, st.root, true)
+	for i := 4999; i >= 100; i -= 1 {
+		st.Delete(i)
+	}
+	checkBTree(t, st.root, true)
+	if st.Size() != 100 || st.Min() != 0 || st.Max() != 99 {
+		t.Errorf("expected keys 0..99, got %d keys", st.Size())
+	}
+}
@@ -0,0 +1,173 @@
+package hftorderbook
+
+import (
+	"math"
+)
+
+// Aggregation of the book levels into coarser price buckets, bids are floored
+// and asks are ceiled to the bucket price so a bucket never looks better than
+// its levels. The view follows the book through Subscribe: every event marks
+// its level, and once the change is done (at the next event or read) only the
+// difference of that level is applied to its bucket.
+type BucketView struct {
+	book *Orderbook
+	size float64
+	bids bucketSide
+	asks bucketSide
+
+	// level of the last event, its change is applied on the next event or read
+	pending bool
+	pendingBidOrAsk bool
+	pendingPrice float64
+}
+
+type bucketSide struct {
+	levels map[float64]Level // level aggregates already in the buckets
+	buckets RedBlackBST[int64, *Level] // by bucket index
+}
+
+// view over the current levels of the book kept in step with it,
+// it must be used by the goroutine owning the book
+func NewBucketView(book *Orderbook, size float64) *BucketView {
+	if size <= 0 {
+		panic("bucket size should be positive")
+	}
+
+	v := &BucketView{
+		book: book,
+		size: size,
+		bids: bucketSide{levels: make(map[float64]Level), buckets: NewRedBlackBSTOf[int64, *Level]()},
+		asks: bucketSide{levels: make(map[float64]Level), buckets: NewRedBlackBSTOf[int64, *Level]()},
+	}
+	book.ascend(book.Bids, func(limit *LimitOrder) {
+		v.update(true, limit.Price)
+	})
+	book.ascend(book.Asks, func(limit *LimitOrder) {
+		v.update(false, limit.Price)
+	})
+	book.Subscribe(v.onEvent)
+	return v
+}
+
+func (v *BucketView) onEvent(e Event) {
+	v.flush()
+	v.pending = true
+	v.pendingBidOrAsk = e.BidOrAsk
+	v.pendingPrice = e.Price
+}
+
+func (v *BucketView) flush() {
+	if v.pending {
+		v.pending = false
+		v.update(v.pendingBidOrAsk, v.pendingPrice)
+	}
+}
+
+// index of the bucket holding the price, prices on a bucket boundary
+// within float error belong to it
+func (v *BucketView) index(price float64, bidOrAsk bool) int64 {
+	x := price / v.size
+	if r := math.Round(x); math.Abs(x - r) < 1e-9 {
+		return int64(r)
+	}
+	if bidOrAsk {
+		return int64(math.Floor(x))
+	}
+	return int64(math.Ceil(x))
+}
+
+// moves the difference between the level in the book and in its bucket
+func (v *BucketView) update(bidOrAsk bool, price float64) {
+	side, cache := &v.asks, v.book.askLimitsCache
+	if bidOrAsk {
+		side, cache = &v.bids, v.book.bidLimitsCache
+	}
+
+	// cleared and removed limits count as empty
+	var current Level
+	if limit := cache[price]; limit != nil && limit.Size() > 0 {
+		current = Level{Price: price, Volume: limit.TotalVolume(), Orders: limit.Size()}
+	}
+	old := side.levels[price]
+	if current == old {
+		return
+	}
+	if current.Orders > 0 {
+		side.levels[price] = current
+	} else {
+		delete(side.levels, price)
+	}
+
+	i := v.index(price, bidOrAsk)
+	var bucket *Level
+	if side.buckets.Contains(i) {
+		bucket = side.buckets.Get(i)
+	} else {
+		bucket = &Level{Price: float64(i) * v.size}
+		side.buckets.Put(i, bucket)
+	}
+
+	bucket.Orders += current.Orders - old.Orders
+	bucket.Volume += current.Volume - old.Volume
+	if bucket.Orders == 0 {
+		// the last order left, dropping the summing error with the bucket
+		side.buckets.Delete(i)
+	}
+}
+
+// bucket holding the price, zero level if it is empty
+func (v *BucketView) Bucket(bidOrAsk bool, price float64) Level {
+	v.flush()
+
+	side := &v.asks
+	if bidOrAsk {
+		side = &v.bids
+	}
+	i := v.index(price, bidOrAsk)
+	if !side.buckets.Contains(i) {
+		return Level{}
+	}
+	return *side.buckets.Get(i)
+}
+
+// buckets of the side from the best one until fn returns false, no allocations
+func (v *BucketView) Walk(bidOrAsk bool, fn func(bucket Level) bool) {
+	v.flush()
+
+	side := &v.asks
+	if bidOrAsk {
+		side = &v.bids
+	}
+	if side.buckets.IsEmpty() {
+		return
+	}
+
+	n := side.buckets.MinPointer()
+	if bidOrAsk {
+		n = side.buckets.MaxPointer()
+	}
+	for n != nil && fn(*n.Value) {
+		if bidOrAsk {
+			n = n.Prev
+		} else {
+			n = n.Next
+		}
+	}
+}
+
+// top depth buckets of each side, all of them if depth <= 0, as Orderbook.L2
+func (v *BucketView) L2(depth int) L2Book {
+	return L2Book{
+		Bids: v.levels(true, depth),
+		Asks: v.levels(false, depth),
+	}
+}
+
+func (v *BucketView) levels(bidOrAsk bool, depth int) []Level {
+	levels := make([]Level, 0)
+	v.Walk(bidOrAsk, func(bucket Level) bool {
+		levels = append(levels, bucket)
+		return depth <= 0 || len(levels) < depth
+	})
+	return levels
+}
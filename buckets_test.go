@@ -0,0 +1,179 @@
+package hftorderbook
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// buckets computed from scratch out of the book levels
+func bucketsOf(book *Orderbook, size float64, bidOrAsk bool) map[float64]Level {
+	side := book.Asks
+	if bidOrAsk {
+		side = book.Bids
+	}
+
+	buckets := make(map[float64]Level)
+	book.ascend(side, func(limit *LimitOrder) {
+		if limit.Size() == 0 {
+			return
+		}
+		x := math.Round(limit.Price / size * 1e6) / 1e6
+		price := math.Ceil(x) * size
+		if bidOrAsk {
+			price = math.Floor(x) * size
+		}
+		b := buckets[price]
+		b.Price = price
+		b.Volume += limit.TotalVolume()
+		b.Orders += limit.Size()
+		buckets[price] = b
+	})
+	return buckets
+}
+
+func sameBuckets(t *testing.T, view *BucketView, book *Orderbook, bidOrAsk bool) {
+	expected := bucketsOf(book, view.size, bidOrAsk)
+	prev := math.NaN()
+	count := 0
+	view.Walk(bidOrAsk, func(b Level) bool {
+		count += 1
+		e, ok := expected[b.Price]
+		if !ok || e.Orders != b.Orders || math.Abs(e.Volume - b.Volume) > 1e-9 {
+			t.Fatalf("bucket %0.2f: expected %v, got %v", b.Price, e, b)
+		}
+		if !math.IsNaN(prev) && (bidOrAsk && b.Price >= prev || !bidOrAsk && b.Price <= prev) {
+			t.Fatalf("buckets should go from the best one")
+		}
+		prev = b.Price
+		return true
+	})
+	if count != len(expected) {
+		t.Fatalf("expected %d buckets, got %d", len(expected), count)
+	}
+}
+
+func TestBucketViewRounding(t *testing.T) {
+	book := NewOrderbook()
+	book.Add(0.99, &Order{Id: 1, Volume: 1, BidOrAsk: true})
+	book.Add(1.00, &Order{Id: 2, Volume: 2, BidOrAsk: true})
+	book.Add(1.01, &Order{Id: 3, Volume: 3})
+	book.Add(2.00, &Order{Id: 4, Volume: 4})
+	view := NewBucketView(&book, 1)
+
+	// bids floor, asks ceil, boundaries belong to their own bucket
+	if b := view.Bucket(true, 0.99); b.Price != 0 || b.Volume != 1 {
+		t.Errorf("bid 0.99 should be in the bucket 0, got %v", b)
+	}
+	if b := view.Bucket(true, 1.00); b.Price != 1 || b.Volume != 2 {
+		t.Errorf("bid 1.00 should be in the bucket 1, got %v", b)
+	}
+	if b := view.Bucket(false, 1.01); b.Price != 2 || b.Volume != 7 || b.Orders != 2 {
+		t.Errorf("asks 1.01 and 2.00 should be in the bucket 2, got %v", b)
+	}
+
+	// float error on the boundary
+	book.Add(0.3, &Order{Id: 5, Volume: 1, BidOrAsk: true})
+	fine := NewBucketView(&book, 0.1)
+	if b := fine.Bucket(true, 0.3); math.Abs(b.Price - 0.3) > 1e-9 || b.Volume != 1 {
+		t.Errorf("bid 0.3 should be in the bucket 0.3, got %v", b)
+	}
+}
+
+func TestBucketViewIncremental(t *testing.T) {
+	book := NewOrderbookWithConfig(OrderbookConfig{MaxLevels: 150})
+	view := NewBucketView(&book, 0.25)
+
+	orders := make([]*Order, 0)
+	// orders of a cleared or deleted level are gone from the book
+	drop := func(limit *LimitOrder) {
+		kept := orders[:0]
+		for _, o := range orders {
+			if o.Limit != limit {
+				kept = append(kept, o)
+			}
+		}
+		orders = kept
+	}
+	for i := 0; i < 20000; i += 1 {
+		switch r := rand.Intn(20); {
+		case r < 5 && len(orders) > 0:
+			j := rand.Intn(len(orders))
+			book.Cancel(orders[j])
+			orders[j] = orders[len(orders) - 1]
+			orders = orders[:len(orders) - 1]
+		case r < 9 && len(orders) > 0:
+			j := rand.Intn(len(orders))
+			o := orders[j]
+			book.Reduce(o, 0.5)
+			if o.Volume <= 0 {
+				orders[j] = orders[len(orders) - 1]
+				orders = orders[:len(orders) - 1]
+			}
+		case r == 9 && book.ALength() > 0:
+			price := book.GetBestOffer()
+			drop(book.askLimitsCache[price])
+			book.ClearAskLimit(price)
+		case r == 10 && book.BLength() > 0:
+			price := book.GetBestBid()
+			drop(book.bidLimitsCache[price])
+			book.DeleteBidLimit(price)
+		default:
+			price := float64(rand.Intn(1000)) / 100
+			o := &Order{Id: i, Volume: float64(1 + rand.Intn(3)) / 2, BidOrAsk: price < 5}
+			book.Add(price, o)
+			orders = append(orders, o)
+		}
+
+		if i % 1000 == 0 {
+			sameBuckets(t, view, &book, true)
+			sameBuckets(t, view, &book, false)
+		}
+	}
+	sameBuckets(t, view, &book, true)
+	sameBuckets(t, view, &book, false)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		view.Walk(true, func(b Level) bool { return true })
+	})
+	if allocs > 0 {
+		t.Errorf("walking buckets should not allocate, got %v allocations", allocs)
+	}
+
+	top := view.L2(3)
+	if len(top.Bids) > 3 || len(top.Asks) > 3 {
+		t.Errorf("L2 should keep the top 3 buckets")
+	}
+}
+
+// top bucket read after every change, incremental against recomputed from the levels
+func benchmarkBucketView(levels int, incremental bool, b *testing.B) {
+	book := NewOrderbook()
+	for i := 0; i < levels; i += 1 {
+		book.Add(float64(i) / 100, &Order{Id: i, Volume: 1})
+	}
+	view := NewBucketView(&book, 1)
+	orders := make([]Order, 1024)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		o := &orders[i % len(orders)]
+		o.Volume = 1
+		price := float64(rand.Intn(levels)) / 100
+		book.Add(price, o)
+		if incremental {
+			view.Bucket(false, 0.01)
+		} else {
+			_ = bucketsOf(&book, 1, false)[1]
+		}
+		book.Cancel(o)
+	}
+}
+
+func BenchmarkBucketView1kLevelsIncremental(b *testing.B) {
+	benchmarkBucketView(1000, true, b)
+}
+
+func BenchmarkBucketView1kLevelsRecompute(b *testing.B) {
+	benchmarkBucketView(1000, false, b)
+}
@@ -0,0 +1,135 @@
+package hftorderbook
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+)
+
+var ErrChecksumMismatch = errors.New("book checksum mismatch")
+
+const (
+	// bid1:ask1:bid2:ask2:... with "price:volume" per level
+	checksumInterleaved = iota
+	// ask1 ask2 ... bid1 bid2 ... with decimal points and leading zeros removed
+	checksumAsksThenBids
+)
+
+// CRC32 of the top levels of the book as published by venues in their feeds,
+// prices and volumes are formatted with the instrument precision
+// or the shortest representation for negative decimals
+type BookChecksum struct {
+	depth int
+	layout int
+	priceDecimals int
+	volumeDecimals int
+	buf []byte
+	bids []*LimitOrder // top levels, kept to avoid allocations
+	asks []*LimitOrder
+}
+
+// OKX: top 25 levels interleaved, the feed value is the signed int32 of the checksum
+func NewOKXChecksum(priceDecimals, volumeDecimals int) BookChecksum {
+	return NewInterleavedChecksum(25, priceDecimals, volumeDecimals)
+}
+
+// Kraken: top 10 asks followed by top 10 bids
+func NewKrakenChecksum(priceDecimals, volumeDecimals int) BookChecksum {
+	return BookChecksum{
+		depth: 10,
+		layout: checksumAsksThenBids,
+		priceDecimals: priceDecimals,
+		volumeDecimals: volumeDecimals,
+	}
+}
+
+// top depth levels interleaved as bid:ask pairs
+func NewInterleavedChecksum(depth, priceDecimals, volumeDecimals int) BookChecksum {
+	return BookChecksum{
+		depth: depth,
+		layout: checksumInterleaved,
+		priceDecimals: priceDecimals,
+		volumeDecimals: volumeDecimals,
+	}
+}
+
+func (this *BookChecksum) Sum(book *Orderbook) uint32 {
+	buf := this.buf[:0]
+	if this.layout == checksumInterleaved {
+		buf = this.appendInterleaved(buf, book)
+	} else {
+		buf = this.appendAsksThenBids(buf, book)
+	}
+	this.buf = buf
+	return crc32.ChecksumIEEE(buf)
+}
+
+// compares the book with the checksum from the feed, a mismatch means the
+// book has diverged and should be re-synchronized
+func (this *BookChecksum) Verify(book *Orderbook, expected uint32) error {
+	if sum := this.Sum(book); sum != expected {
+		return fmt.Errorf("%w: expected %d, got %d", ErrChecksumMismatch, expected, sum)
+	}
+	return nil
+}
+
+func (this *BookChecksum) appendInterleaved(buf []byte, book *Orderbook) []byte {
+	this.bids = book.topLimits(this.bids[:0], book.Bids, true, this.depth)
+	this.asks = book.topLimits(this.asks[:0], book.Asks, false, this.depth)
+	for i := 0; i < len(this.bids) || i < len(this.asks); i += 1 {
+		if i < len(this.bids) {
+			buf = this.appendLevel(buf, this.bids[i])
+		}
+		if i < len(this.asks) {
+			buf = this.appendLevel(buf, this.asks[i])
+		}
+	}
+
+	if len(buf) > 0 {
+		// trailing separator
+		buf = buf[:len(buf)-1]
+	}
+	return buf
+}
+
+func (this *BookChecksum) appendLevel(buf []byte, limit *LimitOrder) []byte {
+	buf = appendDecimal(buf, limit.Price, this.priceDecimals)
+	buf = append(buf, ':')
+	buf = appendDecimal(buf, limit.TotalVolume(), this.volumeDecimals)
+	return append(buf, ':')
+}
+
+func (this *BookChecksum) appendAsksThenBids(buf []byte, book *Orderbook) []byte {
+	this.asks = book.topLimits(this.asks[:0], book.Asks, false, this.depth)
+	for _, l := range this.asks {
+		buf = this.appendDigits(buf, l.Price, this.priceDecimals)
+		buf = this.appendDigits(buf, l.TotalVolume(), this.volumeDecimals)
+	}
+
+	this.bids = book.topLimits(this.bids[:0], book.Bids, true, this.depth)
+	for _, l := range this.bids {
+		buf = this.appendDigits(buf, l.Price, this.priceDecimals)
+		buf = this.appendDigits(buf, l.TotalVolume(), this.volumeDecimals)
+	}
+	return buf
+}
+
+// decimal without the decimal point and leading zeros, "0.00500" -> "500"
+func (this *BookChecksum) appendDigits(buf []byte, v float64, decimals int) []byte {
+	start := len(buf)
+	buf = appendDecimal(buf, v, decimals)
+
+	digits := buf[start:start]
+	for _, c := range buf[start:] {
+		if c == '.' || (c == '0' && len(digits) == 0) {
+			continue
+		}
+		digits = append(digits, c)
+	}
+	return buf[:start + len(digits)]
+}
+
+func appendDecimal(buf []byte, v float64, decimals int) []byte {
+	return strconv.AppendFloat(buf, v, 'f', decimals, 64)
+}
@@ -0,0 +1,70 @@
+package hftorderbook
+
+import (
+	"errors"
+	"testing"
+)
+
+func checksumTestBook() Orderbook {
+	book := NewOrderbook()
+	book.Add(100.5, &Order{Id: 1, Volume: 1, BidOrAsk: true})
+	book.Add(100.5, &Order{Id: 2, Volume: 0.25, BidOrAsk: true})
+	book.Add(100.0, &Order{Id: 3, Volume: 2, BidOrAsk: true})
+	book.Add(101.0, &Order{Id: 4, Volume: 0.5, BidOrAsk: false})
+	book.Add(101.5, &Order{Id: 5, Volume: 3, BidOrAsk: false})
+	return book
+}
+
+func TestChecksumInterleaved(t *testing.T) {
+	book := checksumTestBook()
+
+	// crc32 of "100.5:1.25:101:0.5:100:2:101.5:3"
+	okx := NewOKXChecksum(-1, -1)
+	if sum := okx.Sum(&book); sum != 2611912216 {
+		t.Errorf("invalid checksum %d", sum)
+	}
+
+	// crc32 of "100.50:1.250:101.00:0.500"
+	top1 := NewInterleavedChecksum(1, 2, 3)
+	if sum := top1.Sum(&book); sum != 2996581759 {
+		t.Errorf("invalid checksum %d", sum)
+	}
+}
+
+func TestChecksumKraken(t *testing.T) {
+	book := checksumTestBook()
+
+	// crc32 of asks "1010" "50000000" "1015" "300000000" and bids "1005" "125000000" "1000" "200000000"
+	kraken := NewKrakenChecksum(1, 8)
+	if sum := kraken.Sum(&book); sum != 114806206 {
+		t.Errorf("invalid checksum %d", sum)
+	}
+}
+
+func TestChecksumVerify(t *testing.T) {
+	book := checksumTestBook()
+	okx := NewOKXChecksum(-1, -1)
+	if err := okx.Verify(&book, 2611912216); err != nil {
+		t.Errorf("checksum should match: %v", err)
+	}
+
+	// a diverged book is detected
+	book.Add(101.0, &Order{Id: 6, Volume: 0.1, BidOrAsk: false})
+	if err := okx.Verify(&book, 2611912216); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("checksum mismatch should be detected, got %v", err)
+	}
+
+	empty := NewOrderbook()
+	if sum := okx.Sum(&empty); sum != 0 {
+		t.Errorf("checksum of an empty book should be crc32 of an empty string, got %d", sum)
+	}
+}
+
+func BenchmarkChecksumOKX(b *testing.B) {
+	book := randomOrderbook(10000, 100000)
+	okx := NewOKXChecksum(8, 8)
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		okx.Sum(&book)
+	}
+}
@@ -0,0 +1,223 @@
+package hftorderbook
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+var ErrSequenceGap = errors.New("sequence gap")
+
+// Single message of the Coinbase full channel (level 3), numeric fields are
+// kept as strings exactly as they come from the feed
+type CoinbaseMessage struct {
+	Type string `json:"type"`
+	ProductId string `json:"product_id"`
+	Sequence int64 `json:"sequence"`
+	Time string `json:"time"`
+	OrderId string `json:"order_id"`
+	OrderType string `json:"order_type"`
+	Side string `json:"side"`
+	Price string `json:"price"`
+	Size string `json:"size"`
+	RemainingSize string `json:"remaining_size"`
+	Reason string `json:"reason"`
+	NewSize string `json:"new_size"`
+	OldSize string `json:"old_size"`
+	TradeId int64 `json:"trade_id"`
+	MakerOrderId string `json:"maker_order_id"`
+	TakerOrderId string `json:"taker_order_id"`
+}
+
+// Order level book maintained from the Coinbase full channel messages
+type CoinbaseFeed struct {
+	Book *Orderbook
+
+	orders map[string]*Order
+	sequence int64
+	nextId int
+}
+
+func NewCoinbaseFeed() CoinbaseFeed {
+	return NewCoinbaseFeedWithConfig(OrderbookConfig{})
+}
+
+// the book is created with the config, e.g. a depth window with MaxLevels
+func NewCoinbaseFeedWithConfig(config OrderbookConfig) CoinbaseFeed {
+	book := NewOrderbookWithConfig(config)
+	return CoinbaseFeed{
+		Book: &book,
+		orders: make(map[string]*Order),
+	}
+}
+
+// last applied sequence number
+func (this *CoinbaseFeed) Sequence() int64 {
+	return this.sequence
+}
+
+// sets the sequence the feed continues from, e.g. the one of a level 3 snapshot
+func (this *CoinbaseFeed) SetSequence(sequence int64) {
+	this.sequence = sequence
+}
+
+// resting order by the exchange order id, nil if there is no such order on the book
+func (this *CoinbaseFeed) Order(id string) *Order {
+	return this.orders[id]
+}
+
+func (this *CoinbaseFeed) Len() int {
+	return len(this.orders)
+}
+
+// reads new line delimited JSON messages and applies them until EOF
+func (this *CoinbaseFeed) Replay(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var m CoinbaseMessage
+		if err := json.Unmarshal(line, &m); err != nil {
+			return err
+		}
+		if err := this.Process(&m); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (this *CoinbaseFeed) Process(m *CoinbaseMessage) error {
+	if this.sequence > 0 {
+		if m.Sequence <= this.sequence {
+			// already applied
+			return nil
+		}
+		if m.Sequence != this.sequence + 1 {
+			return fmt.Errorf("%w: expected %d, got %d", ErrSequenceGap, this.sequence + 1, m.Sequence)
+		}
+	}
+
+	var err error
+	switch m.Type {
+	case "open":
+		err = this.open(m)
+	case "done":
+		this.done(m)
+	case "match":
+		err = this.match(m)
+	case "change":
+		err = this.change(m)
+	}
+	// received and any other messages do not touch the book
+
+	if err != nil {
+		return err
+	}
+
+	this.sequence = m.Sequence
+	return nil
+}
+
+func (this *CoinbaseFeed) open(m *CoinbaseMessage) error {
+	if this.orders[m.OrderId] != nil {
+		return fmt.Errorf("order %s is already open", m.OrderId)
+	}
+
+	price, err := strconv.ParseFloat(m.Price, 64)
+	if err != nil {
+		return err
+	}
+	size, err := strconv.ParseFloat(m.RemainingSize, 64)
+	if err != nil {
+		return err
+	}
+
+	var bidOrAsk bool
+	switch m.Side {
+	case "buy":
+		bidOrAsk = true
+	case "sell":
+		bidOrAsk = false
+	default:
+		return fmt.Errorf("invalid side %q", m.Side)
+	}
+
+	this.nextId++
+	o := &Order{
+		Id: this.nextId,
+		Volume: size,
+		BidOrAsk: bidOrAsk,
+	}
+	if err := this.Book.TryAdd(price, o); err != nil {
+		if errors.Is(err, ErrOutOfWindow) {
+			// not tracked, the later messages of the order are skipped
+			return nil
+		}
+		return err
+	}
+	this.orders[m.OrderId] = o
+	return nil
+}
+
+func (this *CoinbaseFeed) done(m *CoinbaseMessage) {
+	o := this.orders[m.OrderId]
+	if o == nil {
+		// orders filled on receive never get to the book
+		return
+	}
+
+	delete(this.orders, m.OrderId)
+	if o.Live() {
+		this.Book.Cancel(o)
+	}
+}
+
+func (this *CoinbaseFeed) match(m *CoinbaseMessage) error {
+	o := this.orders[m.MakerOrderId]
+	if o == nil {
+		return nil
+	}
+
+	size, err := strconv.ParseFloat(m.Size, 64)
+	if err != nil {
+		return err
+	}
+
+	// fully matched maker is removed here, the following done is a no-op
+	this.Book.Reduce(o, size)
+	if !o.Live() {
+		delete(this.orders, m.MakerOrderId)
+	}
+	return nil
+}
+
+func (this *CoinbaseFeed) change(m *CoinbaseMessage) error {
+	o := this.orders[m.OrderId]
+	if o == nil || m.NewSize == "" {
+		// not on the book yet or a market order funds change
+		return nil
+	}
+
+	size, err := strconv.ParseFloat(m.NewSize, 64)
+	if err != nil {
+		return err
+	}
+	if size > o.Volume {
+		return fmt.Errorf("order %s size can only decrease: %s -> %s", m.OrderId, m.OldSize, m.NewSize)
+	}
+
+	this.Book.Reduce(o, o.Volume - size)
+	if !o.Live() {
+		delete(this.orders, m.OrderId)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package hftorderbook
+
+import (
+	"errors"
+	"math"
+	"os"
+	"strings"
+	"testing"
+)
+
+func replayCoinbaseFixture(t *testing.T, name string) (CoinbaseFeed, error) {
+	f, err := os.Open("testdata/coinbase/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	feed := NewCoinbaseFeed()
+	return feed, feed.Replay(f)
+}
+
+func TestCoinbaseReplay(t *testing.T) {
+	feed, err := replayCoinbaseFixture(t, "btc-usd.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if feed.Sequence() != 1025 {
+		t.Errorf("last sequence should be 1025, got %d", feed.Sequence())
+	}
+	if feed.Len() != 4 {
+		t.Errorf("there should be 4 resting orders, got %d", feed.Len())
+	}
+
+	book := feed.Book
+	if book.BLength() != 2 || book.ALength() != 1 {
+		t.Errorf("book should have 2 bid and 1 ask limits, got %d and %d", book.BLength(), book.ALength())
+	}
+	if book.GetBestBid() != 3849.50 {
+		t.Errorf("best bid should be 3849.50, got %0.8f", book.GetBestBid())
+	}
+	if book.GetBestOffer() != 3852.25 {
+		t.Errorf("best offer should be 3852.25, got %0.8f", book.GetBestOffer())
+	}
+	if math.Abs(book.GetVolumeAtAskLimit(3852.25) - 3.7) > 0.0000001 {
+		t.Errorf("invalid volume at ask limit: %0.8f", book.GetVolumeAtAskLimit(3852.25))
+	}
+	if book.GetVolumeAtBidLimit(3850.00) != 0 {
+		t.Errorf("matched limit should be removed")
+	}
+}
+
+func TestCoinbaseChangeKeepsPriority(t *testing.T) {
+	feed, err := replayCoinbaseFixture(t, "btc-usd.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := feed.Order("5c6a3b1e-0005")
+	if changed == nil {
+		t.Fatal("changed order should stay on the book")
+	}
+	if math.Abs(changed.Volume - 0.7) > 0.0000001 {
+		t.Errorf("changed order volume should be 0.7, got %0.8f", changed.Volume)
+	}
+	if changed.Limit.Front() != changed || changed.Next != feed.Order("5c6a3b1e-0008") {
+		t.Errorf("changed order should keep its place in the queue")
+	}
+}
+
+func TestCoinbaseSequenceGap(t *testing.T) {
+	feed, err := replayCoinbaseFixture(t, "gap.jsonl")
+	if !errors.Is(err, ErrSequenceGap) {
+		t.Fatalf("sequence gap should be detected, got %v", err)
+	}
+	if feed.Sequence() != 2002 {
+		t.Errorf("messages after the gap should not be applied")
+	}
+	if feed.Book.ALength() != 0 {
+		t.Errorf("book should not have asks")
+	}
+}
+
+func TestCoinbaseChangeIncrease(t *testing.T) {
+	feed := NewCoinbaseFeed()
+	err := feed.Replay(strings.NewReader(`
+{"type":"open","sequence":1,"order_id":"a","price":"10.0","remaining_size":"1.0","side":"sell"}
+{"type":"change","sequence":2,"order_id":"a","new_size":"2.0","old_size":"1.0","price":"10.0","side":"sell"}
+`))
+	if err == nil {
+		t.Errorf("size increase should be rejected")
+	}
+}
+
+func BenchmarkCoinbaseReplay(b *testing.B) {
+	data, err := os.ReadFile("testdata/coinbase/btc-usd.jsonl")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		feed := NewCoinbaseFeed()
+		if err := feed.Replay(strings.NewReader(string(data))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
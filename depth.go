@@ -0,0 +1,49 @@
+package hftorderbook
+
+// Cumulative depth queries over the subtree volumes of the red-black sides,
+// available with OrderbookConfig.Depth
+
+func limitSums(limit *LimitOrder) (float64, int) {
+	return limit.TotalVolume(), limit.Size()
+}
+
+// recomputes the subtree volumes above the limit after its volume changed
+func (this *Orderbook) refreshDepth(limit *LimitOrder, bidOrAsk bool) {
+	if bidOrAsk {
+		this.Bids.(*redBlackBST).Refresh(limit.Price)
+	} else {
+		this.Asks.(*redBlackBST).Refresh(limit.Price)
+	}
+}
+
+func (this *Orderbook) depthSide(bidOrAsk bool) *redBlackBST {
+	if !this.config.Depth {
+		panic("depth queries need OrderbookConfig.Depth")
+	}
+	if bidOrAsk {
+		return this.Bids.(*redBlackBST)
+	}
+	return this.Asks.(*redBlackBST)
+}
+
+// total volume and number of orders of the bid limits between lo and hi
+func (this *Orderbook) GetBidVolumeBetween(lo, hi float64) (float64, int) {
+	return this.depthSide(true).RangeVolume(lo, hi)
+}
+
+// total volume and number of orders of the ask limits between lo and hi
+func (this *Orderbook) GetAskVolumeBetween(lo, hi float64) (float64, int) {
+	return this.depthSide(false).RangeVolume(lo, hi)
+}
+
+// bid price at which the bids from the best one down to it add up to volume,
+// false if the bid side has less volume
+func (this *Orderbook) GetBidDepthPrice(volume float64) (float64, bool) {
+	return this.depthSide(true).VolumeKey(volume, true)
+}
+
+// ask price at which the asks from the best one up to it add up to volume,
+// false if the ask side has less volume
+func (this *Orderbook) GetAskDepthPrice(volume float64) (float64, bool) {
+	return this.depthSide(false).VolumeKey(volume, false)
+}
@@ -0,0 +1,169 @@
+package hftorderbook
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func randomDepthOrderbook(r *rand.Rand, levels, orders int) (Orderbook, []*Order) {
+	book := NewOrderbookWithConfig(OrderbookConfig{Depth: true})
+	resting := make([]*Order, 0, orders)
+	for i := 0; i < orders; i += 1 {
+		switch {
+		case r.Intn(5) == 0 && len(resting) > 0:
+			j := r.Intn(len(resting))
+			book.Cancel(resting[j])
+			resting[j] = resting[len(resting) - 1]
+			resting = resting[:len(resting) - 1]
+		case r.Intn(5) == 0 && len(resting) > 0:
+			j := r.Intn(len(resting))
+			o := resting[j]
+			book.Reduce(o, 0.5)
+			if o.Volume <= 0 {
+				resting[j] = resting[len(resting) - 1]
+				resting = resting[:len(resting) - 1]
+			}
+		default:
+			price := float64(r.Intn(2 * levels)) / 100
+			o := &Order{Id: i, Volume: float64(1 + r.Intn(4)) / 2, BidOrAsk: price < float64(levels) / 100}
+			book.Add(price, o)
+			resting = append(resting, o)
+		}
+	}
+	return book, resting
+}
+
+// linear walk over the levels between lo and hi
+func walkVolumeBetween(side Side, lo, hi float64) (float64, int) {
+	volume, count := 0.0, 0
+	side.Range(lo, hi, func(l *LimitOrder) bool {
+		volume += l.TotalVolume()
+		count += l.Size()
+		return true
+	})
+	return volume, count
+}
+
+func TestOrderbookDepth(t *testing.T) {
+	r := rand.New(rand.NewSource(rand.Int63()))
+	book, _ := randomDepthOrderbook(r, 500, 20000)
+
+	for i := 0; i < 200; i += 1 {
+		lo := float64(r.Intn(1000)) / 100
+		hi := lo + float64(r.Intn(200)) / 100
+		v, c := walkVolumeBetween(book.Bids, lo, hi)
+		if bv, bc := book.GetBidVolumeBetween(lo, hi); bv != v || bc != c {
+			t.Fatalf("bids [%0.2f, %0.2f]: expected %0.1f in %d orders, got %0.1f in %d", lo, hi, v, c, bv, bc)
+		}
+		v, c = walkVolumeBetween(book.Asks, lo, hi)
+		if av, ac := book.GetAskVolumeBetween(lo, hi); av != v || ac != c {
+			t.Fatalf("asks [%0.2f, %0.2f]: expected %0.1f in %d orders, got %0.1f in %d", lo, hi, v, c, av, ac)
+		}
+	}
+
+	// depth prices against walks from the best prices
+	for i := 0; i < 200; i += 1 {
+		volume := float64(1 + r.Intn(1000)) / 2
+
+		expected, cumulative := -1.0, 0.0
+		for l := book.Bids.MaxValue(); l != nil; l = book.Bids.Prev(l.Price) {
+			if cumulative += l.TotalVolume(); cumulative >= volume {
+				expected = l.Price
+				break
+			}
+		}
+		if price, ok := book.GetBidDepthPrice(volume); ok != (expected >= 0) || ok && price != expected {
+			t.Fatalf("bids depth %0.1f: expected price %0.2f, got %0.2f", volume, expected, price)
+		}
+
+		expected, cumulative = -1.0, 0.0
+		for l := book.Asks.MinValue(); l != nil; l = book.Asks.Next(l.Price) {
+			if cumulative += l.TotalVolume(); cumulative >= volume {
+				expected = l.Price
+				break
+			}
+		}
+		if price, ok := book.GetAskDepthPrice(volume); ok != (expected >= 0) || ok && price != expected {
+			t.Fatalf("asks depth %0.1f: expected price %0.2f, got %0.2f", volume, expected, price)
+		}
+	}
+
+	// snapshots restore the sums
+	data, _ := book.MarshalBinary()
+	var restored Orderbook
+	restored.config = book.config
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	v, c := book.GetAskVolumeBetween(0, 100)
+	if rv, rc := restored.GetAskVolumeBetween(0, 100); rv != v || rc != c {
+		t.Errorf("restored book should have the same sums")
+	}
+
+	// cleared limits stay in the tree with no volume
+	price := book.GetBestBid()
+	book.ClearBidLimit(price)
+	if v, c := book.GetBidVolumeBetween(price, price); v != 0 || c != 0 {
+		t.Errorf("cleared limit volume should be removed from the sums")
+	}
+}
+
+func TestOrderbookDepthDisabled(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("depth queries should panic without OrderbookConfig.Depth")
+		}
+	}()
+	book := NewOrderbook()
+	book.GetBidVolumeBetween(0, 1)
+}
+
+func benchmarkDepthQuery(walk bool, n int, b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	book, _ := randomDepthOrderbook(r, n, 10 * n)
+	lo := book.GetBestBid() - float64(n) / 100
+	hi := book.GetBestBid()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		if walk {
+			walkVolumeBetween(book.Bids, lo, hi)
+		} else {
+			book.GetBidVolumeBetween(lo, hi)
+		}
+	}
+}
+
+func BenchmarkOrderbook10kLevelsVolumeBetweenWalk(b *testing.B) {
+	benchmarkDepthQuery(true, 10000, b)
+}
+
+func BenchmarkOrderbook10kLevelsVolumeBetweenDepth(b *testing.B) {
+	benchmarkDepthQuery(false, 10000, b)
+}
+
+// price of every order change with and without the subtree volumes
+func benchmarkDepthAddCancel(depth bool, n int, b *testing.B) {
+	book := NewOrderbookWithConfig(OrderbookConfig{Depth: depth})
+	for i := 0; i < n; i += 1 {
+		book.Add(float64(i) / 100, &Order{Id: i, Volume: 1, BidOrAsk: true})
+	}
+	orders := make([]Order, 1024)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		o := &orders[i % len(orders)]
+		o.Volume = 1
+		o.BidOrAsk = true
+		book.Add(float64(rand.Intn(n)) / 100, o)
+		book.Cancel(o)
+	}
+}
+
+func BenchmarkOrderbook10kLevelsAddCancel(b *testing.B) {
+	benchmarkDepthAddCancel(false, 10000, b)
+}
+
+func BenchmarkOrderbook10kLevelsAddCancelDepth(b *testing.B) {
+	benchmarkDepthAddCancel(true, 10000, b)
+}
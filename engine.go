@@ -0,0 +1,208 @@
+package hftorderbook
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+type CommandType uint8
+
+const (
+	CommandAdd CommandType = iota + 1
+	CommandCancel
+	CommandAmend // new price and volume, priority is kept for a volume decrease only
+)
+
+// Request to change the book, orders are referred to by ids
+type Command struct {
+	Type CommandType
+	OrderId int
+	BidOrAsk bool
+	Price float64
+	Volume float64
+}
+
+type ResultStatus uint8
+
+const (
+	ResultAccepted ResultStatus = iota + 1
+	ResultRejected // unknown or duplicate order id, invalid volume or no free limits
+)
+
+type Result struct {
+	Type CommandType
+	OrderId int
+	Status ResultStatus
+}
+
+// number of empty polls before the engine goroutine yields the processor
+const engineSpins = 64
+
+// Single-writer engine: producers submit commands through a lock-free ring,
+// one goroutine owns the book and applies them in order, results are
+// published through the second ring
+type Engine struct {
+	commands *ring[Command]
+	results *ring[Result]
+
+	engineBook
+	top TopOfBookSeqlock
+
+	stopping atomic.Bool
+	done chan struct{}
+}
+
+// resultsCapacity 0 disables results, otherwise the engine waits for consumers
+// when the results ring is full
+func NewEngine(commandsCapacity, resultsCapacity int) *Engine {
+	e := &Engine{
+		commands: newRing[Command](commandsCapacity),
+		engineBook: newEngineBook(),
+		done: make(chan struct{}),
+	}
+	if resultsCapacity > 0 {
+		e.results = newRing[Result](resultsCapacity)
+	}
+	return e
+}
+
+func (this *Engine) Start() {
+	go this.run()
+}
+
+// processes all commands submitted so far and stops the engine goroutine,
+// commands submitted after Stop are not processed
+func (this *Engine) Stop() {
+	this.stopping.Store(true)
+	<-this.done
+}
+
+// the book owned by the engine, safe to use only before Start or after Stop
+func (this *Engine) Book() *Orderbook {
+	return &this.book
+}
+
+// false if the commands ring is full
+func (this *Engine) TrySubmit(c Command) bool {
+	return this.commands.offer(c)
+}
+
+// waits for a free slot in the commands ring
+func (this *Engine) Submit(c Command) {
+	for !this.commands.offer(c) {
+		runtime.Gosched()
+	}
+}
+
+// best bid and offer after the last applied command, lock-free
+func (this *Engine) TopOfBook() TopOfBook {
+	return this.top.Load()
+}
+
+// next result, false if there are none yet or results are disabled
+func (this *Engine) Poll() (Result, bool) {
+	if this.results == nil {
+		return Result{}, false
+	}
+	return this.results.poll()
+}
+
+func (this *Engine) run() {
+	defer close(this.done)
+
+	idle := 0
+	for {
+		c, ok := this.commands.poll()
+		if !ok {
+			if this.stopping.Load() {
+				// last check, the ring could be filled before the stop flag was seen
+				if c, ok = this.commands.poll(); !ok {
+					return
+				}
+			} else {
+				idle++
+				if idle > engineSpins {
+					idle = 0
+					runtime.Gosched()
+				}
+				continue
+			}
+		}
+		idle = 0
+
+		status := this.apply(&c)
+		if status == ResultAccepted {
+			this.top.Publish(&this.book)
+		}
+		if this.results != nil {
+			r := Result{c.Type, c.OrderId, status}
+			for !this.results.offer(r) {
+				runtime.Gosched()
+			}
+		}
+	}
+}
+
+// book with its orders by id, owned by a single goroutine
+type engineBook struct {
+	book Orderbook
+	orders map[int]*Order
+}
+
+func newEngineBook() engineBook {
+	return engineBook{
+		book: NewOrderbook(),
+		orders: make(map[int]*Order),
+	}
+}
+
+func (this *engineBook) apply(c *Command) ResultStatus {
+	switch c.Type {
+	case CommandAdd:
+		if this.orders[c.OrderId] != nil || c.Volume <= 0 {
+			return ResultRejected
+		}
+		o := this.book.NewOrder(c.OrderId, c.Volume, c.BidOrAsk)
+		if this.book.TryAdd(c.Price, o) != nil {
+			this.book.Release(o)
+			return ResultRejected
+		}
+		this.orders[c.OrderId] = o
+
+	case CommandCancel:
+		o := this.orders[c.OrderId]
+		if o == nil {
+			return ResultRejected
+		}
+		this.book.Cancel(o)
+		delete(this.orders, c.OrderId)
+
+	case CommandAmend:
+		o := this.orders[c.OrderId]
+		if o == nil || c.Volume <= 0 {
+			return ResultRejected
+		}
+		if c.Price == o.Limit.Price && c.Volume <= o.Volume {
+			this.book.Reduce(o, o.Volume - c.Volume)
+			return ResultAccepted
+		}
+
+		// price change or volume increase loses the priority,
+		// the canceled order is back in the free list
+		bidOrAsk := o.BidOrAsk
+		this.book.Cancel(o)
+		o = this.book.NewOrder(c.OrderId, c.Volume, bidOrAsk)
+		if this.book.TryAdd(c.Price, o) != nil {
+			// the order is gone from the book
+			this.book.Release(o)
+			delete(this.orders, c.OrderId)
+			return ResultRejected
+		}
+		this.orders[c.OrderId] = o
+
+	default:
+		return ResultRejected
+	}
+
+	return ResultAccepted
+}
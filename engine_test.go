@@ -0,0 +1,116 @@
+package hftorderbook
+
+import (
+	"math/rand"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestEngineCommands(t *testing.T) {
+	e := NewEngine(16, 16)
+	e.Start()
+
+	commands := []Command{
+		{CommandAdd, 1, true, 1.0, 2},
+		{CommandAdd, 2, true, 1.0, 3},
+		{CommandAdd, 1, true, 1.0, 1}, // duplicate id
+		{CommandAmend, 1, true, 1.0, 1}, // keeps the priority
+		{CommandAdd, 3, false, 2.0, 1},
+		{CommandAmend, 3, false, 2.5, 1}, // moves to a new price
+		{CommandCancel, 2, true, 0, 0},
+		{CommandCancel, 2, true, 0, 0}, // unknown id
+	}
+	for _, c := range commands {
+		e.Submit(c)
+	}
+
+	expected := []ResultStatus{
+		ResultAccepted, ResultAccepted, ResultRejected, ResultAccepted,
+		ResultAccepted, ResultAccepted, ResultAccepted, ResultRejected,
+	}
+	for i := 0; i < len(expected); {
+		r, ok := e.Poll()
+		if !ok {
+			runtime.Gosched()
+			continue
+		}
+		if r.OrderId != commands[i].OrderId || r.Type != commands[i].Type || r.Status != expected[i] {
+			t.Errorf("result %d: expected %v, got %+v", i, expected[i], r)
+		}
+		i += 1
+	}
+	e.Stop()
+
+	book := e.Book()
+	if book.GetVolumeAtBidLimit(1.0) != 1 || book.GetBestOffer() != 2.5 || book.ALength() != 1 {
+		t.Errorf("invalid book state after commands")
+	}
+}
+
+func TestEngineConcurrentProducers(t *testing.T) {
+	e := NewEngine(64, 0)
+	e.Start()
+
+	producers, n := 4, 5000
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p += 1 {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < n; i += 1 {
+				id := p * n + i
+				e.Submit(Command{CommandAdd, id, p % 2 == 0, float64(p), 1})
+				if i % 2 == 1 {
+					// per producer order is kept, the previous add is already queued
+					e.Submit(Command{CommandCancel, id - 1, false, 0, 0})
+				}
+			}
+		}(p)
+	}
+	wg.Wait()
+	e.Stop()
+
+	book := e.Book()
+	total := 0.0
+	for p := 0; p < producers; p += 1 {
+		total += book.GetVolumeAtBidLimit(float64(p)) + book.GetVolumeAtAskLimit(float64(p))
+	}
+	if total != float64(producers * n / 2) {
+		t.Errorf("expected volume %d, got %0.1f", producers * n / 2, total)
+	}
+}
+
+func benchmarkEngineLimitedRandomInsert(n int, b *testing.B) {
+	limitslist := make([]float64, n)
+	for i := range limitslist {
+		limitslist[i] = rand.Float64()
+	}
+	commands := make([]Command, b.N)
+	for i := range commands {
+		price := limitslist[rand.Intn(len(limitslist))]
+		commands[i] = Command{CommandAdd, i, price < 0.5, price, rand.Float64()}
+	}
+
+	e := NewEngine(4096, 0)
+	e.Start()
+
+	// measure submission and processing time
+	b.ResetTimer()
+	for i := range commands {
+		e.Submit(commands[i])
+	}
+	e.Stop()
+}
+
+func BenchmarkEngine5kLevelsRandomInsert(b *testing.B) {
+	benchmarkEngineLimitedRandomInsert(5000, b)
+}
+
+func BenchmarkEngine10kLevelsRandomInsert(b *testing.B) {
+	benchmarkEngineLimitedRandomInsert(10000, b)
+}
+
+func BenchmarkEngine20kLevelsRandomInsert(b *testing.B) {
+	benchmarkEngineLimitedRandomInsert(20000, b)
+}
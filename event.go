@@ -0,0 +1,100 @@
+package hftorderbook
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Book mutation kinds, one per mutating Orderbook call
+type EventType int
+
+const (
+	EventAdd EventType = iota + 1
+	EventCancel
+	EventReduce
+	EventClearLimit
+	EventDeleteLimit
+)
+
+var eventTypeNames = map[EventType]string{
+	EventAdd: "add",
+	EventCancel: "cancel",
+	EventReduce: "reduce",
+	EventClearLimit: "clear_limit",
+	EventDeleteLimit: "delete_limit",
+}
+
+func (t EventType) String() string {
+	if name, ok := eventTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+func (t EventType) MarshalText() ([]byte, error) {
+	if _, ok := eventTypeNames[t]; !ok {
+		return nil, fmt.Errorf("unknown event type %d", int(t))
+	}
+	return []byte(t.String()), nil
+}
+
+func (t *EventType) UnmarshalText(text []byte) error {
+	for k, name := range eventTypeNames {
+		if name == string(text) {
+			*t = k
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown event type %q", text)
+}
+
+// Single change of the book: the order volume for add, cancel and reduce,
+// the whole level volume for clear and delete of a limit
+type Event struct {
+	Type EventType
+	BidOrAsk bool
+	Price float64
+	OrderId int
+	Volume float64
+}
+
+type jsonEvent struct {
+	Type EventType `json:"type"`
+	Side string `json:"side"`
+	Price jsonDecimal `json:"price"`
+	OrderId int `json:"order_id,omitempty"`
+	Volume jsonDecimal `json:"volume"`
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	side := "ask"
+	if e.BidOrAsk {
+		side = "bid"
+	}
+	return json.Marshal(jsonEvent{
+		Type: e.Type,
+		Side: side,
+		Price: jsonDecimal(e.Price),
+		OrderId: e.OrderId,
+		Volume: jsonDecimal(e.Volume),
+	})
+}
+
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var j jsonEvent
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.Side != "bid" && j.Side != "ask" {
+		return fmt.Errorf("invalid side %q", j.Side)
+	}
+
+	*e = Event{
+		Type: j.Type,
+		BidOrAsk: j.Side == "bid",
+		Price: float64(j.Price),
+		OrderId: j.OrderId,
+		Volume: float64(j.Volume),
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package hftorderbook
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEventJSON(t *testing.T) {
+	e := Event{
+		Type: EventReduce,
+		BidOrAsk: true,
+		Price: 3850.25,
+		OrderId: 42,
+		Volume: 0.5,
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"type":"reduce","side":"bid","price":"3850.25","order_id":42,"volume":"0.5"}`
+	if string(data) != expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+
+	var decoded Event
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != e {
+		t.Errorf("expected %+v, got %+v", e, decoded)
+	}
+}
+
+func TestEventJSONInvalid(t *testing.T) {
+	var e Event
+	for _, data := range []string{
+		`{"type":"unknown","side":"bid","price":"1","volume":"1"}`,
+		`{"type":"add","side":"buy","price":"1","volume":"1"}`,
+		`{"type":"add","side":"bid","price":1,"volume":"1"}`,
+	} {
+		if err := json.Unmarshal([]byte(data), &e); err == nil {
+			t.Errorf("%s should be rejected", data)
+		}
+	}
+
+	if _, err := json.Marshal(Event{}); err == nil {
+		t.Errorf("event without a type should be rejected")
+	}
+}
+
+func TestOrderbookEvents(t *testing.T) {
+	book := NewOrderbook()
+	events := make([]Event, 0)
+	book.Subscribe(func(e Event) {
+		events = append(events, e)
+	})
+
+	bid := &Order{Id: 1, Volume: 2, BidOrAsk: true}
+	ask := &Order{Id: 2, Volume: 1, BidOrAsk: false}
+	book.Add(1.0, bid)
+	book.Add(2.0, ask)
+	book.Reduce(bid, 0.5)
+	book.Reduce(bid, 5)
+	book.Cancel(ask)
+	book.Add(3.0, &Order{Id: 3, Volume: 4, BidOrAsk: false})
+	book.ClearAskLimit(3.0)
+	book.DeleteAskLimit(3.0)
+
+	expected := []Event{
+		{EventAdd, true, 1.0, 1, 2},
+		{EventAdd, false, 2.0, 2, 1},
+		{EventReduce, true, 1.0, 1, 0.5},
+		{EventReduce, true, 1.0, 1, 1.5},
+		{EventCancel, false, 2.0, 2, 1},
+		{EventAdd, false, 3.0, 3, 4},
+		{EventClearLimit, false, 3.0, 0, 4},
+		{EventDeleteLimit, false, 3.0, 0, 0},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(events))
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("event %d: expected %+v, got %+v", i, expected[i], events[i])
+		}
+	}
+}
@@ -0,0 +1,578 @@
+package hftorderbook
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// FIX 4.4 tags used by the order entry codec
+const (
+	FixTagAvgPx = 6
+	FixTagBeginString = 8
+	FixTagBodyLength = 9
+	FixTagCheckSum = 10
+	FixTagClOrdId = 11
+	FixTagCumQty = 14
+	FixTagExecId = 17
+	FixTagLastPx = 31
+	FixTagLastQty = 32
+	FixTagMsgSeqNum = 34
+	FixTagMsgType = 35
+	FixTagOrderId = 37
+	FixTagOrderQty = 38
+	FixTagOrdStatus = 39
+	FixTagOrdType = 40
+	FixTagOrigClOrdId = 41
+	FixTagPrice = 44
+	FixTagSenderCompId = 49
+	FixTagSendingTime = 52
+	FixTagSide = 54
+	FixTagSymbol = 55
+	FixTagTargetCompId = 56
+	FixTagText = 58
+	FixTagExecType = 150
+	FixTagLeavesQty = 151
+	FixTagCxlRejResponseTo = 434
+)
+
+const (
+	FixMsgTypeExecutionReport = "8"
+	FixMsgTypeOrderCancelReject = "9"
+	FixMsgTypeNewOrderSingle = "D"
+	FixMsgTypeOrderCancelRequest = "F"
+	FixMsgTypeOrderCancelReplaceRequest = "G"
+
+	FixExecTypeNew = "0"
+	FixExecTypeCanceled = "4"
+	FixExecTypeReplaced = "5"
+	FixExecTypeRejected = "8"
+	FixExecTypeTrade = "F"
+
+	FixOrdStatusNew = "0"
+	FixOrdStatusPartiallyFilled = "1"
+	FixOrdStatusFilled = "2"
+	FixOrdStatusCanceled = "4"
+	FixOrdStatusRejected = "8"
+
+	fixBeginString = "FIX.4.4"
+	fixSOH = '\x01'
+	fixTimeFormat = "20060102-15:04:05.000"
+)
+
+var ErrFixChecksum = errors.New("fix checksum mismatch")
+
+type FixField struct {
+	Tag int
+	Value string
+}
+
+// FIX message body, from MsgType(35) up to, but not including, CheckSum(10)
+type FixMessage struct {
+	Fields []FixField
+}
+
+func NewFixMessage(msgType string) FixMessage {
+	return FixMessage{
+		Fields: []FixField{{FixTagMsgType, msgType}},
+	}
+}
+
+func (this *FixMessage) Type() string {
+	return this.Get(FixTagMsgType)
+}
+
+// value of the first field with the tag, empty if there is no such field
+func (this *FixMessage) Get(tag int) string {
+	for _, f := range this.Fields {
+		if f.Tag == tag {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+func (this *FixMessage) Add(tag int, value string) {
+	this.Fields = append(this.Fields, FixField{tag, value})
+}
+
+// Reads tag=value FIX messages validating body length and checksum
+type FixReader struct {
+	r *bufio.Reader
+}
+
+func NewFixReader(r io.Reader) FixReader {
+	return FixReader{
+		r: bufio.NewReader(r),
+	}
+}
+
+func (this *FixReader) ReadMessage() (FixMessage, error) {
+	var m FixMessage
+
+	begin, err := this.r.ReadBytes(fixSOH)
+	if err != nil {
+		if err == io.EOF && len(begin) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return m, err
+	}
+	if !bytes.Equal(begin, []byte("8=" + fixBeginString + "\x01")) {
+		return m, fmt.Errorf("invalid begin string %q", begin)
+	}
+
+	length, err := this.r.ReadBytes(fixSOH)
+	if err != nil {
+		return m, noEOF(err)
+	}
+	if !bytes.HasPrefix(length, []byte("9=")) {
+		return m, fmt.Errorf("body length is expected, got %q", length)
+	}
+	n, err := strconv.Atoi(string(length[2:len(length)-1]))
+	if err != nil || n <= 0 {
+		return m, fmt.Errorf("invalid body length %q", length)
+	}
+
+	body := make([]byte, n)
+	if _, err := io.ReadFull(this.r, body); err != nil {
+		return m, noEOF(err)
+	}
+	if body[n-1] != fixSOH {
+		return m, fmt.Errorf("body length %d does not match the message", n)
+	}
+
+	trailer, err := this.r.ReadBytes(fixSOH)
+	if err != nil {
+		return m, noEOF(err)
+	}
+	if !bytes.HasPrefix(trailer, []byte("10=")) {
+		return m, fmt.Errorf("checksum is expected, got %q", trailer)
+	}
+	checksum, err := strconv.Atoi(string(trailer[3:len(trailer)-1]))
+	if err != nil {
+		return m, fmt.Errorf("invalid checksum %q", trailer)
+	}
+	if expected := fixChecksum(begin, length, body); checksum != expected {
+		return m, fmt.Errorf("%w: expected %03d, got %03d", ErrFixChecksum, expected, checksum)
+	}
+
+	m.Fields = make([]FixField, 0, 16)
+	for _, field := range bytes.Split(body[:n-1], []byte{fixSOH}) {
+		eq := bytes.IndexByte(field, '=')
+		if eq <= 0 {
+			return m, fmt.Errorf("invalid field %q", field)
+		}
+		tag, err := strconv.Atoi(string(field[:eq]))
+		if err != nil {
+			return m, fmt.Errorf("invalid tag %q", field)
+		}
+		m.Add(tag, string(field[eq+1:]))
+	}
+	if len(m.Fields) == 0 || m.Fields[0].Tag != FixTagMsgType {
+		return m, fmt.Errorf("message type should be the first field of the body")
+	}
+
+	return m, nil
+}
+
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func fixChecksum(parts ...[]byte) int {
+	sum := 0
+	for _, p := range parts {
+		for _, b := range p {
+			sum += int(b)
+		}
+	}
+	return sum % 256
+}
+
+// Writes FIX messages adding begin string, body length and checksum
+type FixWriter struct {
+	w io.Writer
+	buf []byte
+}
+
+func NewFixWriter(w io.Writer) FixWriter {
+	return FixWriter{
+		w: w,
+	}
+}
+
+func (this *FixWriter) WriteMessage(m *FixMessage) error {
+	var body []byte
+	for _, f := range m.Fields {
+		body = strconv.AppendInt(body, int64(f.Tag), 10)
+		body = append(body, '=')
+		body = append(body, f.Value...)
+		body = append(body, fixSOH)
+	}
+
+	buf := this.buf[:0]
+	buf = append(buf, "8=" + fixBeginString + "\x019="...)
+	buf = strconv.AppendInt(buf, int64(len(body)), 10)
+	buf = append(buf, fixSOH)
+	buf = append(buf, body...)
+	buf = append(buf, fmt.Sprintf("10=%03d\x01", fixChecksum(buf))...)
+	this.buf = buf
+
+	_, err := this.w.Write(buf)
+	return err
+}
+
+// an order accepted by the venue
+type fixOrder struct {
+	order *Order
+	clOrdId string
+	symbol string
+	side string
+	price float64
+	qty float64
+	cumQty float64
+	cumValue float64
+}
+
+func (this *fixOrder) leavesQty() float64 {
+	return this.qty - this.cumQty
+}
+
+// Simulated venue matching FIX order entry messages against a book per symbol
+type FixVenue struct {
+	SenderCompId string
+	Now func() time.Time
+
+	books map[string]*Orderbook
+	config OrderbookConfig // of the books
+	orders map[string]*fixOrder // by ClOrdID
+	resting map[int]*fixOrder // by Order.Id
+	targetCompId string
+	seqNum int
+	nextOrderId int
+	nextExecId int
+}
+
+func NewFixVenue(senderCompId string) FixVenue {
+	return NewFixVenueWithConfig(senderCompId, OrderbookConfig{})
+}
+
+// books are created with the config, orders left out of a depth window
+// (MaxLevels) stay open but do not match until they are replaced
+func NewFixVenueWithConfig(senderCompId string, config OrderbookConfig) FixVenue {
+	return FixVenue{
+		SenderCompId: senderCompId,
+		config: config,
+		Now: time.Now,
+		books: make(map[string]*Orderbook),
+		orders: make(map[string]*fixOrder),
+		resting: make(map[int]*fixOrder),
+	}
+}
+
+// the book of the symbol, nil if no orders have been placed for it
+func (this *FixVenue) Book(symbol string) *Orderbook {
+	return this.books[symbol]
+}
+
+// reads order entry messages until EOF writing execution reports back
+func (this *FixVenue) Serve(r io.Reader, w io.Writer) error {
+	reader := NewFixReader(r)
+	writer := NewFixWriter(w)
+	for {
+		m, err := reader.ReadMessage()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		for _, report := range this.Handle(&m) {
+			if err := writer.WriteMessage(&report); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// applies a single message to the books and returns the resulting reports
+func (this *FixVenue) Handle(m *FixMessage) []FixMessage {
+	if sender := m.Get(FixTagSenderCompId); sender != "" {
+		this.targetCompId = sender
+	}
+
+	var reports []FixMessage
+	switch m.Type() {
+	case FixMsgTypeNewOrderSingle:
+		reports = this.newOrder(m)
+	case FixMsgTypeOrderCancelRequest:
+		reports = this.cancel(m)
+	case FixMsgTypeOrderCancelReplaceRequest:
+		reports = this.replace(m)
+	}
+	// session level messages are not handled by the venue
+
+	for i := range reports {
+		this.header(&reports[i])
+	}
+	return reports
+}
+
+func (this *FixVenue) newOrder(m *FixMessage) []FixMessage {
+	o := &fixOrder{
+		clOrdId: m.Get(FixTagClOrdId),
+		symbol: m.Get(FixTagSymbol),
+		side: m.Get(FixTagSide),
+	}
+
+	var reason string
+	var err error
+	if o.qty, err = strconv.ParseFloat(m.Get(FixTagOrderQty), 64); err != nil || o.qty <= 0 {
+		reason = "invalid order quantity"
+	}
+	if o.price, err = strconv.ParseFloat(m.Get(FixTagPrice), 64); err != nil || o.price <= 0 {
+		reason = "invalid price"
+	}
+	if m.Get(FixTagOrdType) != "2" {
+		reason = "only limit orders are supported"
+	}
+	if o.side != "1" && o.side != "2" {
+		reason = "invalid side"
+	}
+	if o.symbol == "" {
+		reason = "symbol is required"
+	}
+	if o.clOrdId == "" || this.orders[o.clOrdId] != nil {
+		reason = "duplicate or missing ClOrdID"
+	}
+	if reason != "" {
+		return []FixMessage{this.report(o, FixExecTypeRejected, FixOrdStatusRejected, reason)}
+	}
+
+	this.nextOrderId++
+	o.order = &Order{
+		Id: this.nextOrderId,
+		BidOrAsk: o.side == "1",
+	}
+	this.orders[o.clOrdId] = o
+
+	reports := []FixMessage{this.report(o, FixExecTypeNew, FixOrdStatusNew, "")}
+	return this.match(o, reports)
+}
+
+// matches the order against the opposite side and rests what is left
+func (this *FixVenue) match(o *fixOrder, reports []FixMessage) []FixMessage {
+	book := this.books[o.symbol]
+	if book == nil {
+		b := NewOrderbookWithConfig(this.config)
+		book = &b
+		this.books[o.symbol] = book
+	}
+
+	for o.leavesQty() > 0 {
+		var limit *LimitOrder
+		if o.order.BidOrAsk && !book.Asks.IsEmpty() && book.Asks.Min() <= o.price {
+			limit = book.Asks.MinValue()
+		} else if !o.order.BidOrAsk && !book.Bids.IsEmpty() && book.Bids.Max() >= o.price {
+			limit = book.Bids.MaxValue()
+		} else {
+			break
+		}
+
+		// the first order in the queue has the time priority
+		maker := this.resting[limit.Front().Id]
+		qty := o.leavesQty()
+		if maker.order.Volume < qty {
+			qty = maker.order.Volume
+		}
+		price := limit.Price
+
+		book.Reduce(maker.order, qty)
+		if !maker.order.Live() {
+			delete(this.resting, maker.order.Id)
+		}
+
+		reports = append(reports, this.fill(maker, qty, price), this.fill(o, qty, price))
+	}
+
+	if o.leavesQty() > 0 {
+		o.order.Volume = o.leavesQty()
+		if err := book.TryAdd(o.price, o.order); err != nil {
+			// the rest can not be put on the book, e.g. out of the depth window
+			delete(this.orders, o.clOrdId)
+			return append(reports, this.report(o, FixExecTypeCanceled, FixOrdStatusCanceled, err.Error()))
+		}
+		this.resting[o.order.Id] = o
+	}
+	return reports
+}
+
+func (this *FixVenue) fill(o *fixOrder, qty, price float64) FixMessage {
+	o.cumQty += qty
+	o.cumValue += qty * price
+
+	status := FixOrdStatusPartiallyFilled
+	if o.leavesQty() <= 0 {
+		status = FixOrdStatusFilled
+		delete(this.orders, o.clOrdId)
+	}
+
+	report := this.report(o, FixExecTypeTrade, status, "")
+	report.Add(FixTagLastQty, formatFixFloat(qty))
+	report.Add(FixTagLastPx, formatFixFloat(price))
+	return report
+}
+
+func (this *FixVenue) cancel(m *FixMessage) []FixMessage {
+	o := this.orders[m.Get(FixTagOrigClOrdId)]
+	if o == nil || !o.order.Live() {
+		return []FixMessage{this.cancelReject(m, "1")}
+	}
+
+	this.books[o.symbol].Cancel(o.order)
+	delete(this.resting, o.order.Id)
+	delete(this.orders, o.clOrdId)
+
+	// canceled order is reported under the ClOrdID of the cancel request
+	origClOrdId := o.clOrdId
+	o.clOrdId = m.Get(FixTagClOrdId)
+	report := this.report(o, FixExecTypeCanceled, FixOrdStatusCanceled, "")
+	report.Add(FixTagOrigClOrdId, origClOrdId)
+	return []FixMessage{report}
+}
+
+func (this *FixVenue) replace(m *FixMessage) []FixMessage {
+	o := this.orders[m.Get(FixTagOrigClOrdId)]
+	if o == nil || !o.order.Live() {
+		return []FixMessage{this.cancelReject(m, "2")}
+	}
+
+	clOrdId := m.Get(FixTagClOrdId)
+	qty, err := strconv.ParseFloat(m.Get(FixTagOrderQty), 64)
+	if err != nil || qty <= o.cumQty {
+		return []FixMessage{this.cancelReject(m, "2")}
+	}
+	price, err := strconv.ParseFloat(m.Get(FixTagPrice), 64)
+	if err != nil || price <= 0 {
+		return []FixMessage{this.cancelReject(m, "2")}
+	}
+	if clOrdId == "" || this.orders[clOrdId] != nil {
+		return []FixMessage{this.cancelReject(m, "2")}
+	}
+
+	book := this.books[o.symbol]
+	delete(this.orders, o.clOrdId)
+	origClOrdId := o.clOrdId
+	o.clOrdId = clOrdId
+	this.orders[clOrdId] = o
+
+	if price == o.price && qty <= o.qty {
+		// quantity decrease keeps the time priority
+		book.Reduce(o.order, o.qty - qty)
+		o.qty = qty
+
+		report := this.report(o, FixExecTypeReplaced, this.status(o), "")
+		report.Add(FixTagOrigClOrdId, origClOrdId)
+		return []FixMessage{report}
+	}
+
+	// any other change loses the priority and may cross the book
+	book.Cancel(o.order)
+	delete(this.resting, o.order.Id)
+	o.price = price
+	o.qty = qty
+
+	report := this.report(o, FixExecTypeReplaced, this.status(o), "")
+	report.Add(FixTagOrigClOrdId, origClOrdId)
+	return this.match(o, []FixMessage{report})
+}
+
+func (this *FixVenue) status(o *fixOrder) string {
+	if o.cumQty > 0 {
+		return FixOrdStatusPartiallyFilled
+	}
+	return FixOrdStatusNew
+}
+
+func (this *FixVenue) report(o *fixOrder, execType, status, text string) FixMessage {
+	this.nextExecId++
+
+	orderId := "NONE"
+	if o.order != nil {
+		orderId = strconv.Itoa(o.order.Id)
+	}
+
+	avgPx := 0.0
+	if o.cumQty > 0 {
+		avgPx = o.cumValue / o.cumQty
+	}
+
+	leavesQty := o.leavesQty()
+	if execType == FixExecTypeRejected || execType == FixExecTypeCanceled || leavesQty < 0 {
+		leavesQty = 0
+	}
+
+	m := NewFixMessage(FixMsgTypeExecutionReport)
+	m.Add(FixTagClOrdId, o.clOrdId)
+	m.Add(FixTagOrderId, orderId)
+	m.Add(FixTagExecId, strconv.Itoa(this.nextExecId))
+	m.Add(FixTagExecType, execType)
+	m.Add(FixTagOrdStatus, status)
+	m.Add(FixTagSymbol, o.symbol)
+	m.Add(FixTagSide, o.side)
+	m.Add(FixTagOrderQty, formatFixFloat(o.qty))
+	m.Add(FixTagPrice, formatFixFloat(o.price))
+	m.Add(FixTagLeavesQty, formatFixFloat(leavesQty))
+	m.Add(FixTagCumQty, formatFixFloat(o.cumQty))
+	m.Add(FixTagAvgPx, formatFixFloat(avgPx))
+	if text != "" {
+		m.Add(FixTagText, text)
+	}
+	return m
+}
+
+// responseTo is 1 for cancel and 2 for cancel/replace requests
+func (this *FixVenue) cancelReject(m *FixMessage, responseTo string) FixMessage {
+	orderId := "NONE"
+	status := FixOrdStatusRejected
+	if o := this.orders[m.Get(FixTagOrigClOrdId)]; o != nil {
+		orderId = strconv.Itoa(o.order.Id)
+		status = this.status(o)
+	}
+
+	r := NewFixMessage(FixMsgTypeOrderCancelReject)
+	r.Add(FixTagClOrdId, m.Get(FixTagClOrdId))
+	r.Add(FixTagOrigClOrdId, m.Get(FixTagOrigClOrdId))
+	r.Add(FixTagOrderId, orderId)
+	r.Add(FixTagOrdStatus, status)
+	r.Add(FixTagCxlRejResponseTo, responseTo)
+	return r
+}
+
+// inserts the standard header right after the message type
+func (this *FixVenue) header(m *FixMessage) {
+	this.seqNum++
+	header := []FixField{
+		{FixTagSenderCompId, this.SenderCompId},
+		{FixTagTargetCompId, this.targetCompId},
+		{FixTagMsgSeqNum, strconv.Itoa(this.seqNum)},
+		{FixTagSendingTime, this.Now().UTC().Format(fixTimeFormat)},
+	}
+
+	fields := make([]FixField, 0, len(m.Fields) + len(header))
+	fields = append(fields, m.Fields[0])
+	fields = append(fields, header...)
+	m.Fields = append(fields, m.Fields[1:]...)
+}
+
+func formatFixFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
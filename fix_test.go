@@ -0,0 +1,193 @@
+package hftorderbook
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fixOrderMessage(msgType string, fields ...string) FixMessage {
+	m := NewFixMessage(msgType)
+	m.Add(FixTagSenderCompId, "CLIENT")
+	m.Add(FixTagTargetCompId, "VENUE")
+	for _, f := range fields {
+		kv := strings.SplitN(f, "=", 2)
+		tag, _ := strconv.Atoi(kv[0])
+		m.Add(tag, kv[1])
+	}
+	return m
+}
+
+func TestFixCodecRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewFixWriter(&buf)
+	m := fixOrderMessage(FixMsgTypeNewOrderSingle, "11=a1", "55=BTC-USD", "54=1", "38=1.5", "40=2", "44=3850.25")
+	if err := w.WriteMessage(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	wire := buf.String()
+	if !strings.HasPrefix(wire, "8=FIX.4.4\x019=") || !strings.HasSuffix(wire, "\x01") {
+		t.Errorf("invalid message framing %q", wire)
+	}
+
+	r := NewFixReader(&buf)
+	got, err := r.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Type() != FixMsgTypeNewOrderSingle || got.Get(FixTagPrice) != "3850.25" || len(got.Fields) != len(m.Fields) {
+		t.Errorf("invalid decoded message %v", got.Fields)
+	}
+
+	if _, err := r.ReadMessage(); err != io.EOF {
+		t.Errorf("EOF is expected, got %v", err)
+	}
+}
+
+func TestFixReaderValidation(t *testing.T) {
+	valid := "8=FIX.4.4\x019=5\x0135=0\x0110=163\x01"
+	r := NewFixReader(strings.NewReader(valid))
+	if _, err := r.ReadMessage(); err != nil {
+		t.Errorf("valid message failed: %v", err)
+	}
+
+	r = NewFixReader(strings.NewReader("8=FIX.4.4\x019=5\x0135=0\x0110=164\x01"))
+	if _, err := r.ReadMessage(); !errors.Is(err, ErrFixChecksum) {
+		t.Errorf("checksum mismatch should be detected, got %v", err)
+	}
+
+	r = NewFixReader(strings.NewReader("8=FIX.4.4\x019=4\x0135=0\x0110=163\x01"))
+	if _, err := r.ReadMessage(); err == nil {
+		t.Errorf("body length mismatch should be detected")
+	}
+
+	r = NewFixReader(strings.NewReader("8=FIX.4.4\x019=5\x0135=0"))
+	if _, err := r.ReadMessage(); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated message should fail, got %v", err)
+	}
+}
+
+func newTestFixVenue() FixVenue {
+	v := NewFixVenue("VENUE")
+	v.Now = func() time.Time {
+		return time.Date(2019, 3, 7, 10, 0, 0, 0, time.UTC)
+	}
+	return v
+}
+
+func TestFixVenueNewOrderAndFills(t *testing.T) {
+	v := newTestFixVenue()
+
+	sell1 := fixOrderMessage(FixMsgTypeNewOrderSingle, "11=s1", "55=BTC-USD", "54=2", "38=1", "40=2", "44=100")
+	sell2 := fixOrderMessage(FixMsgTypeNewOrderSingle, "11=s2", "55=BTC-USD", "54=2", "38=2", "40=2", "44=101")
+	v.Handle(&sell1)
+	reports := v.Handle(&sell2)
+	if len(reports) != 1 || reports[0].Get(FixTagExecType) != FixExecTypeNew {
+		t.Fatalf("new order should be acknowledged")
+	}
+	if reports[0].Get(FixTagTargetCompId) != "CLIENT" || reports[0].Get(FixTagSendingTime) != "20190307-10:00:00.000" {
+		t.Errorf("invalid header %v", reports[0].Fields)
+	}
+
+	// crossing buy takes s1 fully and s2 partially
+	buy := fixOrderMessage(FixMsgTypeNewOrderSingle, "11=b1", "55=BTC-USD", "54=1", "38=2", "40=2", "44=101")
+	reports = v.Handle(&buy)
+	if len(reports) != 5 {
+		t.Fatalf("ack and 2 fills for both sides are expected, got %d reports", len(reports))
+	}
+
+	last := reports[4]
+	if last.Get(FixTagClOrdId) != "b1" || last.Get(FixTagOrdStatus) != FixOrdStatusFilled || last.Get(FixTagAvgPx) != "100.5" {
+		t.Errorf("taker should be filled at 100.5 average, got %v", last.Fields)
+	}
+	maker := reports[3]
+	if maker.Get(FixTagClOrdId) != "s2" || maker.Get(FixTagOrdStatus) != FixOrdStatusPartiallyFilled || maker.Get(FixTagLeavesQty) != "1" {
+		t.Errorf("s2 should be partially filled, got %v", maker.Fields)
+	}
+
+	book := v.Book("BTC-USD")
+	if book.BLength() != 0 || book.GetVolumeAtAskLimit(101) != 1 {
+		t.Errorf("only the rest of s2 should be on the book")
+	}
+}
+
+func TestFixVenueCancelReplaceReject(t *testing.T) {
+	v := newTestFixVenue()
+
+	buy := fixOrderMessage(FixMsgTypeNewOrderSingle, "11=b1", "55=ETH-USD", "54=1", "38=5", "40=2", "44=10")
+	v.Handle(&buy)
+	buy2 := fixOrderMessage(FixMsgTypeNewOrderSingle, "11=b2", "55=ETH-USD", "54=1", "38=5", "40=2", "44=10")
+	v.Handle(&buy2)
+
+	// quantity decrease keeps the priority
+	replace := fixOrderMessage(FixMsgTypeOrderCancelReplaceRequest, "11=b1r", "41=b1", "55=ETH-USD", "54=1", "38=3", "40=2", "44=10")
+	reports := v.Handle(&replace)
+	if len(reports) != 1 || reports[0].Get(FixTagExecType) != FixExecTypeReplaced || reports[0].Get(FixTagOrigClOrdId) != "b1" {
+		t.Fatalf("replace should be acknowledged, got %v", reports)
+	}
+	book := v.Book("ETH-USD")
+	if book.GetVolumeAtBidLimit(10) != 8 || book.Bids.MaxValue().Front().Id != 1 {
+		t.Errorf("replaced order should keep its priority")
+	}
+
+	cancel := fixOrderMessage(FixMsgTypeOrderCancelRequest, "11=c1", "41=b1r", "55=ETH-USD", "54=1")
+	reports = v.Handle(&cancel)
+	if len(reports) != 1 || reports[0].Get(FixTagOrdStatus) != FixOrdStatusCanceled || reports[0].Get(FixTagClOrdId) != "c1" {
+		t.Fatalf("cancel should be acknowledged, got %v", reports)
+	}
+	if book.GetVolumeAtBidLimit(10) != 5 {
+		t.Errorf("canceled order should be removed")
+	}
+
+	reports = v.Handle(&cancel)
+	if len(reports) != 1 || reports[0].Type() != FixMsgTypeOrderCancelReject {
+		t.Errorf("unknown order cancel should be rejected")
+	}
+
+	market := fixOrderMessage(FixMsgTypeNewOrderSingle, "11=m1", "55=ETH-USD", "54=2", "38=5", "40=1")
+	reports = v.Handle(&market)
+	if len(reports) != 1 || reports[0].Get(FixTagExecType) != FixExecTypeRejected || reports[0].Get(FixTagText) == "" {
+		t.Errorf("unsupported order should be rejected")
+	}
+}
+
+func TestFixVenueServe(t *testing.T) {
+	v := newTestFixVenue()
+
+	var in bytes.Buffer
+	w := NewFixWriter(&in)
+	for _, m := range []FixMessage{
+		fixOrderMessage(FixMsgTypeNewOrderSingle, "11=s1", "55=BTC-USD", "54=2", "38=1", "40=2", "44=100"),
+		fixOrderMessage(FixMsgTypeNewOrderSingle, "11=b1", "55=BTC-USD", "54=1", "38=1", "40=2", "44=100"),
+	} {
+		w.WriteMessage(&m)
+	}
+
+	clientIn, venueOut := io.Pipe()
+	go func() {
+		venueOut.CloseWithError(v.Serve(&in, venueOut))
+	}()
+
+	r := NewFixReader(clientIn)
+	statuses := make([]string, 0)
+	for {
+		m, err := r.ReadMessage()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		statuses = append(statuses, m.Get(FixTagClOrdId) + ":" + m.Get(FixTagOrdStatus))
+	}
+
+	expected := "s1:0 b1:0 s1:2 b1:2"
+	if strings.Join(statuses, " ") != expected {
+		t.Errorf("expected %s, got %s", expected, strings.Join(statuses, " "))
+	}
+}
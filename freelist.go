@@ -0,0 +1,66 @@
+package hftorderbook
+
+// Free list usage counters
+type PoolStats struct {
+	Hits uint64 // taken from the free list
+	Misses uint64 // the free list was empty
+	InUse int
+	HighWater int // maximum of InUse
+}
+
+// Stack of free values preallocated at once, unlike sync.Pool it is never
+// emptied by the garbage collector, so taking a value costs the same every time
+type freeList[T any] struct {
+	free []*T
+	grow bool // allocate on a miss or fail
+	init func(x *T)
+	stats PoolStats
+}
+
+func newFreeList[T any](capacity int, grow bool, init func(x *T)) *freeList[T] {
+	l := &freeList[T]{
+		free: make([]*T, capacity),
+		grow: grow,
+		init: init,
+	}
+
+	values := make([]T, capacity)
+	for i := range values {
+		if init != nil {
+			init(&values[i])
+		}
+		// taking from the tail, so the first values go first
+		l.free[capacity - 1 - i] = &values[i]
+	}
+	return l
+}
+
+// nil if the list is empty and does not grow
+func (l *freeList[T]) get() *T {
+	var x *T
+	if n := len(l.free); n > 0 {
+		x = l.free[n - 1]
+		l.free = l.free[:n - 1]
+		l.stats.Hits++
+	} else {
+		l.stats.Misses++
+		if !l.grow {
+			return nil
+		}
+		x = new(T)
+		if l.init != nil {
+			l.init(x)
+		}
+	}
+
+	l.stats.InUse++
+	if l.stats.InUse > l.stats.HighWater {
+		l.stats.HighWater = l.stats.InUse
+	}
+	return x
+}
+
+func (l *freeList[T]) put(x *T) {
+	l.free = append(l.free, x)
+	l.stats.InUse--
+}
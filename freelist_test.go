@@ -0,0 +1,124 @@
+package hftorderbook
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestFreeList(t *testing.T) {
+	l := newFreeList(2, false, func(x *int) {
+		*x = 7
+	})
+
+	a, b := l.get(), l.get()
+	if a == nil || b == nil || *a != 7 || *b != 7 {
+		t.Errorf("preallocated values should be initialized")
+	}
+	if l.get() != nil {
+		t.Errorf("exhausted free list should not grow")
+	}
+	l.put(a)
+	if l.get() != a {
+		t.Errorf("released value should be reused")
+	}
+	l.put(a)
+	l.put(b)
+
+	expected := PoolStats{Hits: 3, Misses: 1, InUse: 0, HighWater: 2}
+	if l.stats != expected {
+		t.Errorf("expected %+v, got %+v", expected, l.stats)
+	}
+
+	l = newFreeList[int](0, true, nil)
+	if l.get() == nil || l.stats.Misses != 1 {
+		t.Errorf("growing free list should allocate on a miss")
+	}
+}
+
+func TestOrderbookLimitsExhausted(t *testing.T) {
+	b := NewOrderbookWithConfig(OrderbookConfig{Limits: 2, Exhaustion: PoolFail})
+
+	bid := &Order{Id: 1, Volume: 1, BidOrAsk: true}
+	b.Add(1.0, bid)
+	b.Add(2.0, &Order{Id: 2, Volume: 1, BidOrAsk: false})
+	// existing limit does not need a new one
+	b.Add(1.0, &Order{Id: 3, Volume: 1, BidOrAsk: true})
+
+	if err := b.TryAdd(3.0, &Order{Id: 4, Volume: 1, BidOrAsk: false}); err != ErrLimitsExhausted {
+		t.Errorf("expected exhausted limits, got %v", err)
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != ErrLimitsExhausted {
+				t.Errorf("add should panic with exhausted limits, got %v", r)
+			}
+		}()
+		b.Add(3.0, &Order{Id: 4, Volume: 1, BidOrAsk: false})
+	}()
+
+	b.DeleteAskLimit(2.0)
+	if err := b.TryAdd(3.0, &Order{Id: 4, Volume: 1, BidOrAsk: false}); err != nil {
+		t.Errorf("deleted limit should be reused, got %v", err)
+	}
+
+	limits, nodes := b.PoolStats()
+	if limits.Hits != 3 || limits.Misses != 2 || limits.InUse != 2 || limits.HighWater != 2 {
+		t.Errorf("invalid limits stats %+v", limits)
+	}
+	if nodes.Hits != 3 || nodes.Misses != 0 || nodes.InUse != 2 {
+		t.Errorf("invalid nodes stats %+v", nodes)
+	}
+}
+
+func TestOrderbookLimitsGrow(t *testing.T) {
+	b := NewOrderbookWithConfig(OrderbookConfig{Limits: 1})
+	for i := 0; i < 10; i += 1 {
+		b.Add(float64(i), &Order{Id: i, Volume: 1, BidOrAsk: true})
+	}
+
+	limits, _ := b.PoolStats()
+	if limits.Hits != 1 || limits.Misses != 9 || limits.HighWater != 10 {
+		t.Errorf("invalid limits stats %+v", limits)
+	}
+	if b.BLength() != 10 || b.GetBestBid() != 9 {
+		t.Errorf("invalid book after growing")
+	}
+}
+
+// levels come and go, with enough preallocated limits nothing is allocated
+func benchmarkOrderbookPreallocatedLevelsChurn(n int, b *testing.B) {
+	book := NewOrderbookWithConfig(OrderbookConfig{Limits: 2 * n})
+
+	limitslist := make([]float64, 2 * n)
+	for i := range limitslist {
+		limitslist[i] = rand.Float64()
+	}
+	// half of the levels are resting, others are added and removed
+	for i := 0; i < n; i += 1 {
+		book.Add(limitslist[i], book.NewOrder(-i - 1, 1, limitslist[i] < 0.5))
+	}
+	prices := make([]float64, b.N)
+	for i := range prices {
+		prices[i] = limitslist[n + rand.Intn(n)]
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		o := book.NewOrder(i, 1, prices[i] < 0.5)
+		book.Add(prices[i], o)
+		book.Cancel(o)
+	}
+}
+
+func BenchmarkOrderbook5kLevelsPreallocatedChurn(b *testing.B) {
+	benchmarkOrderbookPreallocatedLevelsChurn(5000, b)
+}
+
+func BenchmarkOrderbook10kLevelsPreallocatedChurn(b *testing.B) {
+	benchmarkOrderbookPreallocatedLevelsChurn(10000, b)
+}
+
+func BenchmarkOrderbook20kLevelsPreallocatedChurn(b *testing.B) {
+	benchmarkOrderbookPreallocatedLevelsChurn(20000, b)
+}
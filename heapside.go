@@ -0,0 +1,265 @@
+package hftorderbook
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Binary heap of price levels with the best price on top, levels are found
+// by price through the positions map, so put and delete are O(logN),
+// best price is O(1), while the worst price and the navigation between
+// levels are O(N), good for books touched at the top only
+type heapSide struct {
+	levels []*LimitOrder // 1-based heap
+	positions map[float64]int
+	max bool // bids keep the max price on top
+
+	frontier []int // heap positions of the best-first walk, kept to avoid allocations
+}
+
+func NewHeapSide(bidOrAsk bool) heapSide {
+	return heapSide{
+		levels: make([]*LimitOrder, 1, MaxLimitsNum + 1),
+		positions: make(map[float64]int, MaxLimitsNum),
+		max: bidOrAsk,
+	}
+}
+
+func (pq *heapSide) Size() int {
+	return len(pq.levels) - 1
+}
+
+func (pq *heapSide) IsEmpty() bool {
+	return pq.Size() == 0
+}
+
+func (pq *heapSide) panicIfEmpty() {
+	if pq.IsEmpty() {
+		panic("heap is empty")
+	}
+}
+
+func (pq *heapSide) Contains(key float64) bool {
+	return pq.positions[key] > 0
+}
+
+func (pq *heapSide) Get(key float64) *LimitOrder {
+	k := pq.positions[key]
+	if k == 0 {
+		panic(fmt.Sprintf("key %0.8f does not exist", key))
+	}
+	return pq.levels[k]
+}
+
+func (pq *heapSide) Put(key float64, value *LimitOrder) {
+	if k := pq.positions[key]; k > 0 {
+		pq.levels[k] = value
+		return
+	}
+
+	pq.levels = append(pq.levels, value)
+	pq.positions[key] = pq.Size()
+	pq.swim(pq.Size())
+}
+
+func (pq *heapSide) Delete(key float64) {
+	pq.panicIfEmpty()
+
+	k := pq.positions[key]
+	if k == 0 {
+		// search miss
+		return
+	}
+	delete(pq.positions, key)
+
+	// replacing with the last level
+	n := pq.Size()
+	last := pq.levels[n]
+	pq.levels[n] = nil
+	pq.levels = pq.levels[:n]
+	if k == n {
+		return
+	}
+	pq.levels[k] = last
+	pq.positions[last.Price] = k
+
+	// restore order, the last level can go either way
+	pq.swim(k)
+	pq.sink(pq.positions[last.Price])
+}
+
+func (pq *heapSide) Min() float64 {
+	return pq.MinValue().Price
+}
+
+func (pq *heapSide) Max() float64 {
+	return pq.MaxValue().Price
+}
+
+func (pq *heapSide) MinValue() *LimitOrder {
+	pq.panicIfEmpty()
+	if !pq.max {
+		return pq.levels[1]
+	}
+	return pq.scan(func(l, best *LimitOrder) bool { return best == nil || l.Price < best.Price })
+}
+
+func (pq *heapSide) MaxValue() *LimitOrder {
+	pq.panicIfEmpty()
+	if pq.max {
+		return pq.levels[1]
+	}
+	return pq.scan(func(l, best *LimitOrder) bool { return best == nil || l.Price > best.Price })
+}
+
+func (pq *heapSide) Next(key float64) *LimitOrder {
+	return pq.scan(func(l, best *LimitOrder) bool {
+		return l.Price > key && (best == nil || l.Price < best.Price)
+	})
+}
+
+func (pq *heapSide) Prev(key float64) *LimitOrder {
+	return pq.scan(func(l, best *LimitOrder) bool {
+		return l.Price < key && (best == nil || l.Price > best.Price)
+	})
+}
+
+// collects and sorts the levels in range
+func (pq *heapSide) Range(lo, hi float64, fn func(value *LimitOrder) bool) {
+	levels := make([]*LimitOrder, 0)
+	for _, l := range pq.levels[1:] {
+		if l.Price >= lo && l.Price <= hi {
+			levels = append(levels, l)
+		}
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		return levels[i].Price < levels[j].Price
+	})
+
+	for _, l := range levels {
+		if !fn(l) {
+			return
+		}
+	}
+}
+
+// best-first walks pop a frontier of heap positions, the next best level is
+// a child of the levels already visited, so k levels cost O(klgk);
+// worst-first walks sort all levels
+func (pq *heapSide) Walk(descending bool, fn func(value *LimitOrder) bool) {
+	if descending != pq.max {
+		pq.walkSorted(descending, fn)
+		return
+	}
+
+	frontier := pq.frontier[:0]
+	if !pq.IsEmpty() {
+		frontier = append(frontier, 1)
+	}
+	for len(frontier) > 0 {
+		// popping the best position
+		k := frontier[0]
+		last := len(frontier) - 1
+		frontier[0] = frontier[last]
+		frontier = frontier[:last]
+		pq.sinkFrontier(frontier, 0)
+
+		if !fn(pq.levels[k]) {
+			break
+		}
+		// children of the visited level
+		for c := 2*k; c <= 2*k + 1 && c <= pq.Size(); c++ {
+			frontier = append(frontier, c)
+			pq.swimFrontier(frontier, len(frontier) - 1)
+		}
+	}
+	pq.frontier = frontier[:0]
+}
+
+func (pq *heapSide) swimFrontier(frontier []int, i int) {
+	for i > 0 && pq.above(frontier[i], frontier[(i-1)/2]) {
+		frontier[i], frontier[(i-1)/2] = frontier[(i-1)/2], frontier[i]
+		i = (i-1)/2
+	}
+}
+
+func (pq *heapSide) sinkFrontier(frontier []int, i int) {
+	for 2*i + 1 < len(frontier) {
+		c := 2*i + 1
+		if c + 1 < len(frontier) && pq.above(frontier[c+1], frontier[c]) {
+			c++
+		}
+		if !pq.above(frontier[c], frontier[i]) {
+			break
+		}
+		frontier[i], frontier[c] = frontier[c], frontier[i]
+		i = c
+	}
+}
+
+func (pq *heapSide) walkSorted(descending bool, fn func(value *LimitOrder) bool) {
+	levels := make([]*LimitOrder, len(pq.levels) - 1)
+	copy(levels, pq.levels[1:])
+	sort.Slice(levels, func(i, j int) bool {
+		if descending {
+			return levels[i].Price > levels[j].Price
+		}
+		return levels[i].Price < levels[j].Price
+	})
+
+	for _, l := range levels {
+		if !fn(l) {
+			return
+		}
+	}
+}
+
+// level preferred by better over all others, nil if there is none,
+// best is nil until the first level is chosen
+func (pq *heapSide) scan(better func(l, best *LimitOrder) bool) *LimitOrder {
+	var best *LimitOrder
+	for _, l := range pq.levels[1:] {
+		if better(l, best) {
+			best = l
+		}
+	}
+	return best
+}
+
+// true if level i should be above level j
+func (pq *heapSide) above(i, j int) bool {
+	if pq.max {
+		return pq.levels[i].Price > pq.levels[j].Price
+	}
+	return pq.levels[i].Price < pq.levels[j].Price
+}
+
+func (pq *heapSide) swap(i, j int) {
+	pq.levels[i], pq.levels[j] = pq.levels[j], pq.levels[i]
+	pq.positions[pq.levels[i].Price] = i
+	pq.positions[pq.levels[j].Price] = j
+}
+
+func (pq *heapSide) swim(k int) {
+	for k > 1 && pq.above(k, k/2) {
+		pq.swap(k, k/2)
+		k = k/2
+	}
+}
+
+func (pq *heapSide) sink(k int) {
+	n := pq.Size()
+	for 2*k <= n {
+		c := 2*k
+		// select the better of two children
+		if c < n && pq.above(c+1, c) {
+			c++
+		}
+
+		if !pq.above(c, k) {
+			break
+		}
+		pq.swap(c, k)
+		k = c
+	}
+}
@@ -1,30 +1,41 @@
 package hftorderbook
 
+import (
+	"cmp"
+)
+
 // Indexed mininum oriented Priority Queue
-type indexMinPQ struct {
-	keys []float64
+type IndexMinPQ[K cmp.Ordered] struct {
+	keys []K
 	index2offset []int
 	offset2index []int
 	n int
 }
 
+// float64 keys
+type indexMinPQ = IndexMinPQ[float64]
+
 func NewIndexMinPQ(size int) indexMinPQ {
-	return indexMinPQ {
-		keys: make([]float64, size + 1),
+	return NewIndexMinPQOf[float64](size)
+}
+
+func NewIndexMinPQOf[K cmp.Ordered](size int) IndexMinPQ[K] {
+	return IndexMinPQ[K] {
+		keys: make([]K, size + 1),
 		index2offset: make([]int, size + 1),
 		offset2index: make([]int, size + 1),
 	}
 }
 
-func (pq *indexMinPQ) Size() int {
+func (pq *IndexMinPQ[K]) Size() int {
 	return pq.n
 }
 
-func (pq *indexMinPQ) IsEmpty() bool {
+func (pq *IndexMinPQ[K]) IsEmpty() bool {
 	return pq.n == 0
 }
 
-func (pq *indexMinPQ) Insert(i int, key float64) {
+func (pq *IndexMinPQ[K]) Insert(i int, key K) {
 	pq.checkIndex(i)
 
 	if pq.index2offset[i] > 0 {
@@ -43,7 +54,7 @@ func (pq *indexMinPQ) Insert(i int, key float64) {
 	pq.swim(i)
 }
 
-func (pq *indexMinPQ) Change(i int, key float64) {
+func (pq *IndexMinPQ[K]) Change(i int, key K) {
 	pq.checkIndex(i)
 
 	offset := pq.index2offset[i]
@@ -63,13 +74,13 @@ func (pq *indexMinPQ) Change(i int, key float64) {
 	}
 }
 
-func (pq *indexMinPQ) Contains(i int) bool {
+func (pq *IndexMinPQ[K]) Contains(i int) bool {
 	pq.checkIndex(i)
 
 	return pq.index2offset[i] > 0
 }
 
-func (pq *indexMinPQ) Delete(i int) {
+func (pq *IndexMinPQ[K]) Delete(i int) {
 	pq.checkIndex(i)
 
 	offset := pq.index2offset[i]
@@ -95,7 +106,7 @@ func (pq *indexMinPQ) Delete(i int) {
 	pq.sink(lastkeyindex)
 }
 
-func (pq *indexMinPQ) Top() float64 {
+func (pq *IndexMinPQ[K]) Top() K {
 	if pq.IsEmpty() {
 		panic("pq is empty")
 	}
@@ -103,7 +114,7 @@ func (pq *indexMinPQ) Top() float64 {
 	return pq.keys[1]
 }
 
-func (pq *indexMinPQ) TopIndex() int {
+func (pq *IndexMinPQ[K]) TopIndex() int {
 	if pq.IsEmpty() {
 		panic("pq is empty")
 	}
@@ -112,7 +123,7 @@ func (pq *indexMinPQ) TopIndex() int {
 }
 
 // removes minimal element and returns it's index
-func (pq *indexMinPQ) DelTop() int {
+func (pq *IndexMinPQ[K]) DelTop() int {
 	minindex := pq.TopIndex()
 	pq.Delete(minindex)
 	return minindex
@@ -121,13 +132,13 @@ func (pq *indexMinPQ) DelTop() int {
 
 // helpers
 
-func (pq *indexMinPQ) checkIndex(i int) {
+func (pq *IndexMinPQ[K]) checkIndex(i int) {
 	if i < 0 || i + 1 >= cap(pq.keys) {
 		panic("invalid index")
 	}
 }
 
-func (pq *indexMinPQ) swim(i int) {
+func (pq *IndexMinPQ[K]) swim(i int) {
 	k := pq.index2offset[i]
 	for k > 1 && pq.keys[k] < pq.keys[k/2] {
 		// swap keys
@@ -143,7 +154,7 @@ func (pq *indexMinPQ) swim(i int) {
 	}
 }
 
-func (pq *indexMinPQ) sink(i int) {
+func (pq *IndexMinPQ[K]) sink(i int) {
 	k := pq.index2offset[i]
 	for 2*k <= pq.n {
 		c := 2*k
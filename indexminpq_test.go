@@ -3,6 +3,7 @@ package hftorderbook
 import (
 	"testing"
 	"math/rand"
+	"cmp"
 	//"fmt"
 )
 
@@ -132,4 +133,40 @@ func BenchmarkIndexMinPQLimitedRandomInsertWithCaching(b *testing.B) {
 			pq.Insert(len(limitscache)-1, price)
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestIndexMinPQGenericKeys(t *testing.T) {
+	pq := NewIndexMinPQOf[int64](3)
+	pq.Insert(0, 30)
+	pq.Insert(1, 10)
+	pq.Insert(2, 20)
+	pq.Change(1, 40)
+	if pq.Top() != 20 || pq.DelTop() != 2 || pq.DelTop() != 0 {
+		t.Errorf("invalid generic pq")
+	}
+}
+
+func benchmarkIndexMinPQInsertDelete[K cmp.Ordered](key func(x float64) K, b *testing.B) {
+	pq := NewIndexMinPQOf[K](10001)
+	for i := 0; i < 10000; i += 1 {
+		pq.Insert(i, key(rand.Float64()))
+	}
+	keys := make([]K, 1024)
+	for i := range keys {
+		keys[i] = key(rand.Float64())
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		pq.Insert(10000, keys[i & 1023])
+		pq.Delete(10000)
+	}
+}
+
+func BenchmarkIndexMinPQInsertDelete(b *testing.B) {
+	benchmarkIndexMinPQInsertDelete(func(x float64) float64 { return x }, b)
+}
+
+func BenchmarkIndexMinPQInt64InsertDelete(b *testing.B) {
+	benchmarkIndexMinPQInsertDelete(func(x float64) int64 { return int64(x * 1e8) }, b)
+}
@@ -0,0 +1,200 @@
+package hftorderbook
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// NASDAQ TotalView-ITCH 5.0 order messages, all integers are big endian,
+// prices have 4 implied decimal places
+const (
+	itchAddOrder byte = 'A'
+	itchAddOrderMPID byte = 'F'
+	itchOrderExecuted byte = 'E'
+	itchOrderExecutedWithPrice byte = 'C'
+	itchOrderCancel byte = 'X'
+	itchOrderDelete byte = 'D'
+	itchOrderReplace byte = 'U'
+
+	itchPriceScale = 10000.0
+)
+
+// message lengths including the type byte
+var itchMessageLength = map[byte]int{
+	itchAddOrder: 36,
+	itchAddOrderMPID: 40,
+	itchOrderExecuted: 31,
+	itchOrderExecutedWithPrice: 36,
+	itchOrderCancel: 23,
+	itchOrderDelete: 19,
+	itchOrderReplace: 35,
+}
+
+// an order on the book remembering the stock it belongs to
+type itchOrder struct {
+	Order
+	locate uint16
+}
+
+// Order level books built from an ITCH 5.0 feed, one book per stock locate
+type ItchFeed struct {
+	books map[uint16]*Orderbook
+	orders map[uint64]*itchOrder
+	config OrderbookConfig // of the books
+}
+
+func NewItchFeed() ItchFeed {
+	return NewItchFeedWithConfig(OrderbookConfig{})
+}
+
+// books are created with the config, e.g. a depth window with MaxLevels
+func NewItchFeedWithConfig(config OrderbookConfig) ItchFeed {
+	return ItchFeed{
+		config: config,
+		books: make(map[uint16]*Orderbook),
+		orders: make(map[uint64]*itchOrder),
+	}
+}
+
+// the book of the stock locate, nil if no orders have been seen for it
+func (this *ItchFeed) Book(locate uint16) *Orderbook {
+	return this.books[locate]
+}
+
+// resting order by the order reference number, nil if there is no such order
+func (this *ItchFeed) Order(ref uint64) *Order {
+	o := this.orders[ref]
+	if o == nil {
+		return nil
+	}
+	return &o.Order
+}
+
+func (this *ItchFeed) Len() int {
+	return len(this.orders)
+}
+
+// reads 2-byte length prefixed messages, as in NASDAQ BinaryFILE samples, until EOF
+func (this *ItchFeed) Replay(r io.Reader) error {
+	br := bufio.NewReaderSize(r, 64*1024)
+	buf := make([]byte, 0xffff)
+	var prefix [2]byte
+	for {
+		if _, err := io.ReadFull(br, prefix[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		msg := buf[:binary.BigEndian.Uint16(prefix[:])]
+		if _, err := io.ReadFull(br, msg); err != nil {
+			return err
+		}
+		if err := this.Process(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// applies a single message, messages not affecting the book are skipped
+func (this *ItchFeed) Process(msg []byte) error {
+	if len(msg) == 0 {
+		return fmt.Errorf("empty itch message")
+	}
+
+	n, ok := itchMessageLength[msg[0]]
+	if !ok {
+		return nil
+	}
+	if len(msg) < n {
+		return fmt.Errorf("itch message %q is too short: %d < %d", msg[0], len(msg), n)
+	}
+
+	locate := binary.BigEndian.Uint16(msg[1:])
+	ref := binary.BigEndian.Uint64(msg[11:])
+
+	switch msg[0] {
+	case itchAddOrder, itchAddOrderMPID:
+		shares := binary.BigEndian.Uint32(msg[20:])
+		price := binary.BigEndian.Uint32(msg[32:])
+		return this.add(locate, ref, msg[19] == 'B', shares, price)
+
+	case itchOrderExecuted, itchOrderExecutedWithPrice, itchOrderCancel:
+		// executions and partial cancels take shares from the order keeping its priority
+		o := this.orders[ref]
+		if o == nil {
+			return this.unknown(ref)
+		}
+		this.books[o.locate].Reduce(&o.Order, float64(binary.BigEndian.Uint32(msg[19:])))
+		if !o.Live() {
+			delete(this.orders, ref)
+		}
+
+	case itchOrderDelete:
+		o := this.orders[ref]
+		if o == nil {
+			return this.unknown(ref)
+		}
+		this.books[o.locate].Cancel(&o.Order)
+		delete(this.orders, ref)
+
+	case itchOrderReplace:
+		// the new order loses time priority and inherits the side of the original one
+		o := this.orders[ref]
+		if o == nil {
+			return this.unknown(ref)
+		}
+		this.books[o.locate].Cancel(&o.Order)
+		delete(this.orders, ref)
+
+		newRef := binary.BigEndian.Uint64(msg[19:])
+		shares := binary.BigEndian.Uint32(msg[27:])
+		price := binary.BigEndian.Uint32(msg[31:])
+		return this.add(o.locate, newRef, o.BidOrAsk, shares, price)
+	}
+
+	return nil
+}
+
+func (this *ItchFeed) add(locate uint16, ref uint64, bidOrAsk bool, shares, price uint32) error {
+	if this.orders[ref] != nil {
+		return fmt.Errorf("order reference %d is already used", ref)
+	}
+
+	book := this.books[locate]
+	if book == nil {
+		b := NewOrderbookWithConfig(this.config)
+		book = &b
+		this.books[locate] = book
+	}
+
+	o := &itchOrder{
+		Order: Order{
+			Id: int(ref),
+			Volume: float64(shares),
+			BidOrAsk: bidOrAsk,
+		},
+		locate: locate,
+	}
+	if err := book.TryAdd(float64(price) / itchPriceScale, &o.Order); err != nil {
+		if errors.Is(err, ErrOutOfWindow) {
+			// not tracked, the later messages of the order are skipped
+			return nil
+		}
+		return err
+	}
+	this.orders[ref] = o
+	return nil
+}
+
+// nil for references of orders rejected by the depth window, an error otherwise
+func (this *ItchFeed) unknown(ref uint64) error {
+	if this.config.MaxLevels > 0 && this.config.OutOfWindow == WindowReject {
+		return nil
+	}
+	return fmt.Errorf("unknown order reference %d", ref)
+}
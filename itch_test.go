@@ -0,0 +1,230 @@
+package hftorderbook
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func itchHeader(t byte, n int, locate uint16) []byte {
+	msg := make([]byte, n)
+	msg[0] = t
+	binary.BigEndian.PutUint16(msg[1:], locate)
+	return msg
+}
+
+func itchAdd(locate uint16, ref uint64, side byte, shares, price uint32) []byte {
+	msg := itchHeader('A', 36, locate)
+	binary.BigEndian.PutUint64(msg[11:], ref)
+	msg[19] = side
+	binary.BigEndian.PutUint32(msg[20:], shares)
+	copy(msg[24:32], "AAPL    ")
+	binary.BigEndian.PutUint32(msg[32:], price)
+	return msg
+}
+
+func itchExecuted(locate uint16, ref uint64, shares uint32) []byte {
+	msg := itchHeader('E', 31, locate)
+	binary.BigEndian.PutUint64(msg[11:], ref)
+	binary.BigEndian.PutUint32(msg[19:], shares)
+	return msg
+}
+
+func itchCancel(locate uint16, ref uint64, shares uint32) []byte {
+	msg := itchHeader('X', 23, locate)
+	binary.BigEndian.PutUint64(msg[11:], ref)
+	binary.BigEndian.PutUint32(msg[19:], shares)
+	return msg
+}
+
+func itchDelete(locate uint16, ref uint64) []byte {
+	msg := itchHeader('D', 19, locate)
+	binary.BigEndian.PutUint64(msg[11:], ref)
+	return msg
+}
+
+func itchReplace(locate uint16, ref, newRef uint64, shares, price uint32) []byte {
+	msg := itchHeader('U', 35, locate)
+	binary.BigEndian.PutUint64(msg[11:], ref)
+	binary.BigEndian.PutUint64(msg[19:], newRef)
+	binary.BigEndian.PutUint32(msg[27:], shares)
+	binary.BigEndian.PutUint32(msg[31:], price)
+	return msg
+}
+
+// BinaryFILE framing: 2-byte length prefix per message
+func itchStream(msgs ...[]byte) []byte {
+	var buf bytes.Buffer
+	for _, m := range msgs {
+		binary.Write(&buf, binary.BigEndian, uint16(len(m)))
+		buf.Write(m)
+	}
+	return buf.Bytes()
+}
+
+func TestItchAddAndDelete(t *testing.T) {
+	feed := NewItchFeed()
+	stream := itchStream(
+		itchAdd(1, 100, 'B', 300, 1500000),
+		itchAdd(1, 101, 'S', 200, 1501000),
+		itchAdd(2, 102, 'B', 50, 990000),
+		itchDelete(1, 101),
+	)
+	if err := feed.Replay(bytes.NewReader(stream)); err != nil {
+		t.Fatal(err)
+	}
+
+	book := feed.Book(1)
+	if book.GetBestBid() != 150.0 {
+		t.Errorf("best bid should be 150.0, got %0.8f", book.GetBestBid())
+	}
+	if book.ALength() != 0 {
+		t.Errorf("deleted ask should be removed")
+	}
+	if feed.Book(2).GetVolumeAtBidLimit(99.0) != 50 {
+		t.Errorf("each stock locate should have its own book")
+	}
+	if feed.Len() != 2 {
+		t.Errorf("there should be 2 orders, got %d", feed.Len())
+	}
+}
+
+func TestItchExecuteAndCancel(t *testing.T) {
+	feed := NewItchFeed()
+	stream := itchStream(
+		itchAdd(1, 100, 'S', 300, 1500000),
+		itchAdd(1, 101, 'S', 200, 1500000),
+		itchExecuted(1, 100, 100),
+		itchCancel(1, 101, 50),
+	)
+	if err := feed.Replay(bytes.NewReader(stream)); err != nil {
+		t.Fatal(err)
+	}
+
+	book := feed.Book(1)
+	if book.GetVolumeAtAskLimit(150.0) != 350 {
+		t.Errorf("invalid volume at limit: %0.8f", book.GetVolumeAtAskLimit(150.0))
+	}
+	if feed.Order(100).Volume != 200 || feed.Order(100).Next != feed.Order(101) {
+		t.Errorf("executed order should keep its priority")
+	}
+
+	if err := feed.Process(itchExecuted(1, 100, 200)); err != nil {
+		t.Fatal(err)
+	}
+	if feed.Order(100) != nil {
+		t.Errorf("fully executed order should be removed")
+	}
+}
+
+func TestItchReplaceLosesPriority(t *testing.T) {
+	feed := NewItchFeed()
+	stream := itchStream(
+		itchAdd(1, 100, 'B', 100, 1500000),
+		itchAdd(1, 101, 'B', 100, 1500000),
+		itchReplace(1, 100, 102, 150, 1500000),
+	)
+	if err := feed.Replay(bytes.NewReader(stream)); err != nil {
+		t.Fatal(err)
+	}
+
+	if feed.Order(100) != nil {
+		t.Errorf("original order should be removed")
+	}
+	replaced := feed.Order(102)
+	if replaced == nil || !replaced.BidOrAsk {
+		t.Fatal("replaced order should inherit the side")
+	}
+	if replaced.Limit.Front() != feed.Order(101) || replaced.Prev != feed.Order(101) {
+		t.Errorf("replaced order should go to the end of the queue")
+	}
+	if feed.Book(1).GetVolumeAtBidLimit(150.0) != 250 {
+		t.Errorf("invalid volume at limit: %0.8f", feed.Book(1).GetVolumeAtBidLimit(150.0))
+	}
+}
+
+func TestItchErrors(t *testing.T) {
+	feed := NewItchFeed()
+	if err := feed.Process(itchDelete(1, 1)); err == nil {
+		t.Errorf("unknown order reference should fail")
+	}
+	if err := feed.Process(itchAdd(1, 1, 'B', 1, 1)[:20]); err == nil {
+		t.Errorf("short message should fail")
+	}
+	if err := feed.Process([]byte{'S', 0, 0}); err != nil {
+		t.Errorf("messages not affecting the book should be skipped")
+	}
+}
+
+func BenchmarkItchSyntheticReplay(b *testing.B) {
+	// adds and deletes over 10k price levels
+	msgs := make([][]byte, 0, b.N)
+	live := make([]uint64, 0)
+	for i := 0; i < b.N; i += 1 {
+		if len(live) > 0 && rand.Intn(3) == 0 {
+			k := rand.Intn(len(live))
+			ref := live[k]
+			live[k] = live[len(live)-1]
+			live = live[:len(live)-1]
+			msgs = append(msgs, itchDelete(1, ref))
+			continue
+		}
+
+		ref := uint64(i + 1)
+		side := byte('B')
+		price := uint32(1000000 + rand.Intn(10000))
+		if price >= 1005000 {
+			side = 'S'
+		}
+		live = append(live, ref)
+		msgs = append(msgs, itchAdd(1, ref, side, uint32(1 + rand.Intn(1000)), price))
+	}
+
+	feed := NewItchFeed()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		if err := feed.Process(msgs[i]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// set ITCH50_FILE to a NASDAQ TotalView-ITCH 5.0 sample, e.g. 01302019.NASDAQ_ITCH50
+func BenchmarkItchFileReplay(b *testing.B) {
+	path := os.Getenv("ITCH50_FILE")
+	if path == "" {
+		b.Skip("ITCH50_FILE is not set")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// split the file once to measure decoding and book updates only
+	msgs := make([][]byte, 0)
+	for p := 0; p + 2 <= len(data); {
+		n := int(binary.BigEndian.Uint16(data[p:]))
+		msgs = append(msgs, data[p+2:p+2+n])
+		p += 2 + n
+	}
+	if len(msgs) == 0 {
+		b.Fatal("no messages in the file")
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; {
+		feed := NewItchFeed()
+		for _, m := range msgs {
+			if err := feed.Process(m); err != nil {
+				b.Fatal(err)
+			}
+			i += 1
+			if i == b.N {
+				break
+			}
+		}
+	}
+}
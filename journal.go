@@ -0,0 +1,410 @@
+package hftorderbook
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+)
+
+// Journal record layout, fixed size, little endian:
+//
+//	seq uint64, type byte, side byte, price float64, order id int64,
+//	volume float64, crc32 of the preceding bytes
+//
+// checkpoint records keep the book hash in place of the order id.
+// Cancels and reduces find their order by side, price and id, so the ids of
+// the orders resting at a price level have to be unique.
+const (
+	journalRecordSize = 38
+	journalCheckpoint EventType = 0xff
+)
+
+var (
+	ErrJournalCorrupted = errors.New("journal is corrupted")
+	ErrJournalSequence = errors.New("journal sequence gap")
+	ErrHashMismatch = errors.New("book hash mismatch")
+	ErrDuplicateOrder = errors.New("duplicate order id at the price level")
+)
+
+// price level of the journaled orders
+type journalLevel struct {
+	bidOrAsk bool
+	price float64
+}
+
+// 64-bit FNV-1a hash of the binary snapshot, equal books have equal hashes
+func (this *Orderbook) Hash() uint64 {
+	data, _ := this.MarshalBinary()
+	h := fnv.New64a()
+	h.Write(data)
+	return h.Sum64()
+}
+
+// Append-only log of book events with sequence numbers. Records are written
+// by a book subscriber, so a change is in the book before its record is
+// durable: records are buffered until Sync, and a crash loses the changes
+// recorded after the last Sync. Call Sync before acknowledging the changes
+// that have to survive a crash.
+type Journal struct {
+	file *os.File
+	w *bufio.Writer
+	seq uint64
+	err error
+	buf [journalRecordSize]byte
+
+	// remaining volume of the recorded orders by level and id
+	levels map[journalLevel]map[int]float64
+}
+
+// opens or creates the journal file, a torn record at the end of the file left
+// by a crash is truncated
+func OpenJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR | os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	r := NewJournalReader(bufio.NewReader(f))
+	var last uint64
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		last = rec.Seq
+	}
+
+	if err := f.Truncate(r.Offset()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(r.Offset(), io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &Journal{
+		file: f,
+		w: bufio.NewWriterSize(f, 64*1024),
+		seq: last,
+		levels: make(map[journalLevel]map[int]float64),
+	}, nil
+}
+
+// sequence number of the last record
+func (this *Journal) Seq() uint64 {
+	return this.seq
+}
+
+// records every change of the book from now on
+func (this *Journal) Attach(book *Orderbook) {
+	for _, side := range []Side{book.Bids, book.Asks} {
+		book.ascend(side, func(limit *LimitOrder) {
+			for o := limit.Front(); o != nil; o = limit.Next(o) {
+				this.track(Event{EventAdd, o.BidOrAsk, limit.Price, o.Id, o.Volume})
+			}
+		})
+	}
+	book.Subscribe(this.Record)
+}
+
+// an order added with the id of a live order at the same level can not be
+// replayed, the journal fails with ErrDuplicateOrder
+func (this *Journal) Record(e Event) {
+	this.track(e)
+	this.write(e.Type, e.BidOrAsk, e.Price, int64(e.OrderId), e.Volume)
+}
+
+func (this *Journal) track(e Event) {
+	level := journalLevel{e.BidOrAsk, e.Price}
+	orders := this.levels[level]
+
+	switch e.Type {
+	case EventAdd:
+		if orders == nil {
+			orders = make(map[int]float64)
+			this.levels[level] = orders
+		}
+		if _, ok := orders[e.OrderId]; ok && this.err == nil {
+			this.err = fmt.Errorf("%w: %d at %0.8f", ErrDuplicateOrder, e.OrderId, e.Price)
+		}
+		orders[e.OrderId] = e.Volume
+
+	case EventCancel, EventReduce:
+		volume, ok := orders[e.OrderId]
+		if !ok {
+			// recorded before the journal was attached
+			return
+		}
+		if volume -= e.Volume; e.Type == EventCancel || volume <= 0 {
+			delete(orders, e.OrderId)
+			if len(orders) == 0 {
+				delete(this.levels, level)
+			}
+		} else {
+			orders[e.OrderId] = volume
+		}
+
+	case EventClearLimit, EventDeleteLimit:
+		delete(this.levels, level)
+	}
+}
+
+// records the book hash, replay fails if the replayed book differs at this point
+func (this *Journal) Checkpoint(book *Orderbook) {
+	this.write(journalCheckpoint, false, 0, int64(book.Hash()), 0)
+}
+
+func (this *Journal) write(t EventType, bidOrAsk bool, price float64, id int64, volume float64) {
+	if this.err != nil {
+		return
+	}
+
+	this.seq++
+	b := this.buf[:]
+	binary.LittleEndian.PutUint64(b[0:], this.seq)
+	b[8] = byte(t)
+	b[9] = 0
+	if bidOrAsk {
+		b[9] = 1
+	}
+	binary.LittleEndian.PutUint64(b[10:], math.Float64bits(price))
+	binary.LittleEndian.PutUint64(b[18:], uint64(id))
+	binary.LittleEndian.PutUint64(b[26:], math.Float64bits(volume))
+	binary.LittleEndian.PutUint32(b[34:], crc32.ChecksumIEEE(b[:34]))
+
+	_, this.err = this.w.Write(b)
+}
+
+// first write error, records after it are dropped
+func (this *Journal) Err() error {
+	return this.err
+}
+
+// flushes buffered records and commits them to the disk
+func (this *Journal) Sync() error {
+	if this.err != nil {
+		return this.err
+	}
+	if err := this.w.Flush(); err != nil {
+		return err
+	}
+	return this.file.Sync()
+}
+
+func (this *Journal) Close() error {
+	err := this.Sync()
+	if cerr := this.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Single journal record
+type JournalRecord struct {
+	Seq uint64
+	Event Event
+	hash uint64
+}
+
+// Sequential reader of journal records
+type JournalReader struct {
+	r io.Reader
+	offset int64
+	buf [journalRecordSize]byte
+}
+
+func NewJournalReader(r io.Reader) JournalReader {
+	return JournalReader{r: r}
+}
+
+// size of the valid records read so far
+func (this *JournalReader) Offset() int64 {
+	return this.offset
+}
+
+// next record, io.EOF at the end of the journal including a torn last record
+func (this *JournalReader) Next() (JournalRecord, error) {
+	var rec JournalRecord
+	b := this.buf[:]
+	n, err := io.ReadFull(this.r, b)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		// partially written record was never committed
+		return rec, io.EOF
+	}
+	if err != nil {
+		return rec, err
+	}
+
+	if crc32.ChecksumIEEE(b[:34]) != binary.LittleEndian.Uint32(b[34:]) {
+		// a torn write of the last record looks like a checksum mismatch too
+		if _, err := io.ReadFull(this.r, b[:1]); err == io.EOF {
+			return rec, io.EOF
+		}
+		return rec, fmt.Errorf("%w: checksum mismatch at offset %d", ErrJournalCorrupted, this.offset)
+	}
+	this.offset += int64(n)
+
+	rec.Seq = binary.LittleEndian.Uint64(b[0:])
+	rec.Event = Event{
+		Type: EventType(b[8]),
+		BidOrAsk: b[9] == 1,
+		Price: math.Float64frombits(binary.LittleEndian.Uint64(b[10:])),
+		OrderId: int(int64(binary.LittleEndian.Uint64(b[18:]))),
+		Volume: math.Float64frombits(binary.LittleEndian.Uint64(b[26:])),
+	}
+	if rec.Event.Type == journalCheckpoint {
+		rec.hash = binary.LittleEndian.Uint64(b[18:])
+		rec.Event.OrderId = 0
+	}
+	return rec, nil
+}
+
+// Rebuilds a book from a snapshot taken at the snapshot sequence number and
+// the journal records that follow it, returns the sequence of the last applied record.
+// The config should be the one of the journaled book, its sides, tick ladders
+// and depth window decide the levels the records apply to.
+func ReplayJournal(snapshot []byte, seq uint64, journal io.Reader, config OrderbookConfig) (Orderbook, uint64, error) {
+	book := Orderbook{config: config}
+	if err := book.UnmarshalBinary(snapshot); err != nil {
+		return book, seq, err
+	}
+
+	replayer, err := newJournalReplayer(&book)
+	if err != nil {
+		return book, seq, err
+	}
+	r := NewJournalReader(journal)
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return book, seq, nil
+		}
+		if err != nil {
+			return book, seq, err
+		}
+		if rec.Seq <= seq {
+			// already in the snapshot
+			continue
+		}
+		if rec.Seq != seq + 1 {
+			return book, seq, fmt.Errorf("%w: expected %d, got %d", ErrJournalSequence, seq + 1, rec.Seq)
+		}
+
+		if rec.Event.Type == journalCheckpoint {
+			if h := book.Hash(); h != rec.hash {
+				return book, seq, fmt.Errorf("%w at %d: %x != %x", ErrHashMismatch, rec.Seq, h, rec.hash)
+			}
+		} else if err := replayer.apply(rec.Event); err != nil {
+			return book, seq, fmt.Errorf("record %d: %w", rec.Seq, err)
+		}
+		seq = rec.Seq
+	}
+}
+
+// applies events to the book tracking orders by level and id
+type journalReplayer struct {
+	book *Orderbook
+	levels map[journalLevel]map[int]*Order
+}
+
+func newJournalReplayer(book *Orderbook) (journalReplayer, error) {
+	r := journalReplayer{
+		book: book,
+		levels: make(map[journalLevel]map[int]*Order),
+	}
+
+	var err error
+	for _, side := range []Side{book.Bids, book.Asks} {
+		book.ascend(side, func(limit *LimitOrder) {
+			for o := limit.Front(); o != nil && err == nil; o = limit.Next(o) {
+				err = r.track(limit.Price, o)
+			}
+		})
+	}
+	return r, err
+}
+
+func (r *journalReplayer) track(price float64, o *Order) error {
+	level := journalLevel{o.BidOrAsk, price}
+	orders := r.levels[level]
+	if orders == nil {
+		orders = make(map[int]*Order)
+		r.levels[level] = orders
+	}
+	if orders[o.Id] != nil {
+		return fmt.Errorf("%w: %d at %0.8f", ErrDuplicateOrder, o.Id, price)
+	}
+	orders[o.Id] = o
+	return nil
+}
+
+func (r *journalReplayer) apply(e Event) error {
+	level := journalLevel{e.BidOrAsk, e.Price}
+
+	switch e.Type {
+	case EventAdd:
+		o := &Order{
+			Id: e.OrderId,
+			Volume: e.Volume,
+			BidOrAsk: e.BidOrAsk,
+		}
+		if err := r.track(e.Price, o); err != nil {
+			return err
+		}
+		if err := r.book.TryAdd(e.Price, o); err != nil {
+			return err
+		}
+
+	case EventCancel, EventReduce:
+		orders := r.levels[level]
+		o := orders[e.OrderId]
+		if o == nil {
+			return fmt.Errorf("unknown order %d at %0.8f", e.OrderId, e.Price)
+		}
+		if e.Type == EventCancel {
+			r.book.Cancel(o)
+		} else {
+			r.book.Reduce(o, e.Volume)
+		}
+		if !o.Live() {
+			delete(orders, e.OrderId)
+			if len(orders) == 0 {
+				delete(r.levels, level)
+			}
+		}
+
+	case EventClearLimit, EventDeleteLimit:
+		cache := r.book.askLimitsCache
+		if e.BidOrAsk {
+			cache = r.book.bidLimitsCache
+		}
+		if cache[e.Price] == nil {
+			return fmt.Errorf("unknown limit %0.8f", e.Price)
+		}
+		delete(r.levels, level)
+
+		if e.Type == EventClearLimit {
+			r.book.clearLimit(e.Price, e.BidOrAsk)
+		} else if e.BidOrAsk {
+			r.book.DeleteBidLimit(e.Price)
+		} else {
+			r.book.DeleteAskLimit(e.Price)
+		}
+
+	default:
+		return fmt.Errorf("unknown event type %d", int(e.Type))
+	}
+
+	return nil
+}
@@ -0,0 +1,245 @@
+package hftorderbook
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// random adds, cancels, reduces and limit deletes
+func randomBookActivity(book *Orderbook, orders map[int]*Order, nextId *int, n int) {
+	for i := 0; i < n; i += 1 {
+		switch r := rand.Intn(10); {
+		case r < 6 || len(orders) == 0:
+			*nextId += 1
+			price := float64(rand.Intn(100)) / 100
+			o := &Order{Id: *nextId, Volume: float64(1 + rand.Intn(10)), BidOrAsk: price < 0.5}
+			book.Add(price, o)
+			orders[o.Id] = o
+		case r < 9:
+			for id, o := range orders {
+				if r == 8 {
+					book.Reduce(o, float64(rand.Intn(5)))
+				} else {
+					book.Cancel(o)
+				}
+				if o.Limit == nil {
+					delete(orders, id)
+				}
+				break
+			}
+		default:
+			for id, o := range orders {
+				price := o.Limit.Price
+				for id2, o2 := range orders {
+					if o2.Limit == o.Limit {
+						delete(orders, id2)
+					}
+				}
+				delete(orders, id)
+				if o.BidOrAsk {
+					book.DeleteBidLimit(price)
+				} else {
+					book.DeleteAskLimit(price)
+				}
+				break
+			}
+		}
+	}
+}
+
+func TestJournalReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.journal")
+	journal, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	book := NewOrderbook()
+	journal.Attach(&book)
+	orders := make(map[int]*Order)
+	nextId := 0
+
+	randomBookActivity(&book, orders, &nextId, 1000)
+	snapshot, _ := book.MarshalBinary()
+	snapshotSeq := journal.Seq()
+
+	randomBookActivity(&book, orders, &nextId, 1000)
+	journal.Checkpoint(&book)
+	randomBookActivity(&book, orders, &nextId, 100)
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, start := range []struct{
+		snapshot []byte
+		seq uint64
+	}{{snapshot, snapshotSeq}, {[]byte("HFOB\x01\x00\x00"), 0}} {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		replayed, seq, err := ReplayJournal(start.snapshot, start.seq, f, OrderbookConfig{})
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if seq != journal.Seq() {
+			t.Errorf("replay should reach %d, got %d", journal.Seq(), seq)
+		}
+		if replayed.Hash() != book.Hash() {
+			t.Errorf("replayed book differs from the original one")
+		}
+		sameOrderbooks(t, &book, &replayed)
+	}
+}
+
+func TestJournalTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.journal")
+	journal, _ := OpenJournal(path)
+	book := NewOrderbook()
+	journal.Attach(&book)
+	book.Add(1.0, &Order{Id: 1, Volume: 1, BidOrAsk: true})
+	book.Add(2.0, &Order{Id: 2, Volume: 1, BidOrAsk: false})
+	journal.Close()
+
+	// crash in the middle of the third record
+	f, _ := os.OpenFile(path, os.O_WRONLY | os.O_APPEND, 0644)
+	f.Write(make([]byte, journalRecordSize / 2))
+	f.Close()
+
+	journal, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if journal.Seq() != 2 {
+		t.Errorf("journal should continue from 2, got %d", journal.Seq())
+	}
+	journal.Record(Event{EventCancel, false, 2.0, 2, 1})
+	journal.Close()
+
+	data, _ := os.ReadFile(path)
+	if len(data) != 3 * journalRecordSize {
+		t.Errorf("torn record should be truncated, journal size %d", len(data))
+	}
+	replayed, seq, err := ReplayJournal([]byte("HFOB\x01\x00\x00"), 0, bytes.NewReader(data), OrderbookConfig{})
+	if err != nil || seq != 3 || replayed.ALength() != 0 || replayed.BLength() != 1 {
+		t.Errorf("invalid replay after recovery: %v", err)
+	}
+}
+
+func TestJournalReplayErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.journal")
+	journal, _ := OpenJournal(path)
+	book := NewOrderbook()
+	journal.Attach(&book)
+	book.Add(1.0, &Order{Id: 1, Volume: 1, BidOrAsk: true})
+
+	// checkpoint of a different book
+	other := NewOrderbook()
+	journal.Checkpoint(&other)
+	journal.Close()
+
+	empty := []byte("HFOB\x01\x00\x00")
+	data, _ := os.ReadFile(path)
+	if _, _, err := ReplayJournal(empty, 0, bytes.NewReader(data), OrderbookConfig{}); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("hash mismatch should be detected, got %v", err)
+	}
+
+	// the first record is missing
+	if _, _, err := ReplayJournal(empty, 0, bytes.NewReader(data[journalRecordSize:]), OrderbookConfig{}); !errors.Is(err, ErrJournalSequence) {
+		t.Errorf("sequence gap should be detected, got %v", err)
+	}
+
+	data[3] ^= 0xff
+	if _, _, err := ReplayJournal(empty, 0, bytes.NewReader(data), OrderbookConfig{}); !errors.Is(err, ErrJournalCorrupted) {
+		t.Errorf("corrupted record should be detected, got %v", err)
+	}
+}
+
+// the same id at different levels, a cleared level in the snapshot
+func TestJournalOrderLevels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.journal")
+	journal, _ := OpenJournal(path)
+	book := NewOrderbook()
+	book.Add(1.0, &Order{Id: 0, Volume: 1, BidOrAsk: true})
+	book.Add(1.1, &Order{Id: 0, Volume: 1, BidOrAsk: true})
+	book.ClearBidLimit(1.1)
+	snapshot, _ := book.MarshalBinary()
+
+	journal.Attach(&book)
+	a := &Order{Id: 0, Volume: 2, BidOrAsk: true}
+	b := &Order{Id: 0, Volume: 3}
+	book.Add(0.9, a)
+	book.Add(2.0, b)
+	book.Reduce(b, 1)
+	book.Cancel(a)
+	journal.Close()
+	if journal.Err() != nil {
+		t.Fatal(journal.Err())
+	}
+
+	data, _ := os.ReadFile(path)
+	replayed, _, err := ReplayJournal(snapshot, 0, bytes.NewReader(data), OrderbookConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameOrderbooks(t, &book, &replayed)
+
+	// the same id twice at one level can not be replayed
+	journal, _ = OpenJournal(filepath.Join(t.TempDir(), "book.journal"))
+	journal.Attach(&book)
+	book.Add(1.0, &Order{Id: 0, Volume: 1, BidOrAsk: true})
+	if err := journal.Close(); !errors.Is(err, ErrDuplicateOrder) {
+		t.Errorf("duplicate order id should fail the journal, got %v", err)
+	}
+}
+
+// replay builds the book with the config of the journaled one
+func TestJournalReplayConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.journal")
+	journal, _ := OpenJournal(path)
+	config := OrderbookConfig{
+		NewSide: func(bidOrAsk bool) Side {
+			l := NewTickLadder(0.01, 64)
+			return &l
+		},
+	}
+	book := NewOrderbookWithConfig(config)
+	journal.Attach(&book)
+	orders := make(map[int]*Order)
+	nextId := 0
+	randomBookActivity(&book, orders, &nextId, 1000)
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	replayed, _, err := ReplayJournal([]byte("HFOB\x01\x00\x00"), 0, bytes.NewReader(data), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := replayed.Bids.(*tickLadder); !ok {
+		t.Errorf("replayed book should have the tick ladder sides")
+	}
+	sameOrderbooks(t, &book, &replayed)
+}
+
+func BenchmarkJournalRecord(b *testing.B) {
+	journal, err := OpenJournal(filepath.Join(b.TempDir(), "book.journal"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer journal.Close()
+
+	e := Event{EventAdd, true, 1.0, 1, 1}
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		e.OrderId = i
+		journal.Record(e)
+	}
+}
@@ -0,0 +1,189 @@
+package hftorderbook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Decimal string in JSON, e.g. "3850.25", never in exponent notation
+type jsonDecimal float64
+
+func (d jsonDecimal) MarshalJSON() ([]byte, error) {
+	buf := make([]byte, 0, 24)
+	buf = append(buf, '"')
+	buf = strconv.AppendFloat(buf, float64(d), 'f', -1, 64)
+	return append(buf, '"'), nil
+}
+
+func (d *jsonDecimal) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("decimal should be a string: %w", err)
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*d = jsonDecimal(v)
+	return nil
+}
+
+// Aggregated price level
+type Level struct {
+	Price float64
+	Volume float64
+	Orders int
+}
+
+type jsonLevel struct {
+	Price jsonDecimal `json:"price"`
+	Volume jsonDecimal `json:"volume"`
+	Count int `json:"count"`
+}
+
+// Aggregated (L2) view of the book, best levels first
+type L2Book struct {
+	Bids []Level
+	Asks []Level
+}
+
+type jsonL2Book struct {
+	Bids []jsonLevel `json:"bids"`
+	Asks []jsonLevel `json:"asks"`
+}
+
+// top depth levels of each side, all of them if depth <= 0
+func (this *Orderbook) L2(depth int) L2Book {
+	return L2Book{
+		Bids: this.levels(this.Bids, true, depth),
+		Asks: this.levels(this.Asks, false, depth),
+	}
+}
+
+func (this *Orderbook) levels(side Side, bidOrAsk bool, depth int) []Level {
+	if depth <= 0 || depth > side.Size() {
+		depth = side.Size()
+	}
+
+	levels := make([]Level, 0, depth)
+	if depth == 0 {
+		return levels
+	}
+	this.walk(side, bidOrAsk, func(l *LimitOrder) bool {
+		levels = append(levels, Level{
+			Price: l.Price,
+			Volume: l.TotalVolume(),
+			Orders: l.Size(),
+		})
+		return len(levels) < depth
+	})
+	return levels
+}
+
+func (this L2Book) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonL2Book{
+		Bids: toJSONLevels(this.Bids),
+		Asks: toJSONLevels(this.Asks),
+	})
+}
+
+func (this *L2Book) UnmarshalJSON(data []byte) error {
+	var b jsonL2Book
+	if err := json.Unmarshal(data, &b); err != nil {
+		return err
+	}
+	this.Bids = fromJSONLevels(b.Bids)
+	this.Asks = fromJSONLevels(b.Asks)
+	return nil
+}
+
+func toJSONLevels(levels []Level) []jsonLevel {
+	r := make([]jsonLevel, len(levels))
+	for i, l := range levels {
+		r[i] = jsonLevel{jsonDecimal(l.Price), jsonDecimal(l.Volume), l.Orders}
+	}
+	return r
+}
+
+func fromJSONLevels(levels []jsonLevel) []Level {
+	r := make([]Level, len(levels))
+	for i, l := range levels {
+		r[i] = Level{float64(l.Price), float64(l.Volume), l.Count}
+	}
+	return r
+}
+
+// Full (L3) view of the book: every order of every level in FIFO order,
+// best levels first
+type jsonOrder struct {
+	Id int `json:"id"`
+	Volume jsonDecimal `json:"volume"`
+}
+
+type jsonL3Level struct {
+	Price jsonDecimal `json:"price"`
+	Volume jsonDecimal `json:"volume"`
+	Orders []jsonOrder `json:"orders"`
+}
+
+type jsonL3Book struct {
+	Bids []jsonL3Level `json:"bids"`
+	Asks []jsonL3Level `json:"asks"`
+}
+
+// value receiver, so books held by value marshal to the L3 view as well
+func (this Orderbook) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonL3Book{
+		Bids: this.jsonL3Levels(this.Bids, true),
+		Asks: this.jsonL3Levels(this.Asks, false),
+	})
+}
+
+func (this *Orderbook) jsonL3Levels(side Side, bidOrAsk bool) []jsonL3Level {
+	levels := make([]jsonL3Level, 0, side.Size())
+	this.walk(side, bidOrAsk, func(limit *LimitOrder) bool {
+		orders := make([]jsonOrder, 0, limit.Size())
+		for o := limit.Front(); o != nil; o = limit.Next(o) {
+			orders = append(orders, jsonOrder{o.Id, jsonDecimal(o.Volume)})
+		}
+
+		levels = append(levels, jsonL3Level{
+			Price: jsonDecimal(limit.Price),
+			Volume: jsonDecimal(limit.TotalVolume()),
+			Orders: orders,
+		})
+		return true
+	})
+	return levels
+}
+
+func levelOrders(levels []jsonL3Level) []LevelOrders {
+	result := make([]LevelOrders, len(levels))
+	for i, l := range levels {
+		// orders are allocated once per level
+		orders := make([]Order, len(l.Orders))
+		result[i] = LevelOrders{float64(l.Price), make([]*Order, len(l.Orders))}
+		for j, o := range l.Orders {
+			orders[j] = Order{Id: o.Id, Volume: float64(o.Volume)}
+			result[i].Orders[j] = &orders[j]
+		}
+	}
+	return result
+}
+
+// replaces the book content with the L3 view, levels are expected best first
+// as MarshalJSON lists them, level volumes are recalculated from the orders
+func (this *Orderbook) UnmarshalJSON(data []byte) error {
+	var b jsonL3Book
+	if err := json.Unmarshal(data, &b); err != nil {
+		return err
+	}
+
+	book, err := NewOrderbookFromLevels(this.config, levelOrders(b.Bids), levelOrders(b.Asks))
+	if err != nil {
+		return err
+	}
+	this.restore(book)
+	return nil
+}
@@ -0,0 +1,95 @@
+package hftorderbook
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestJSONL2(t *testing.T) {
+	book := NewOrderbook()
+	book.Add(100.5, &Order{Id: 1, Volume: 0.1, BidOrAsk: true})
+	book.Add(100.5, &Order{Id: 2, Volume: 0.2, BidOrAsk: true})
+	book.Add(100.25, &Order{Id: 3, Volume: 1, BidOrAsk: true})
+	book.Add(0.00000001, &Order{Id: 4, Volume: 5, BidOrAsk: true})
+	book.Add(101, &Order{Id: 5, Volume: 2, BidOrAsk: false})
+
+	data, err := json.Marshal(book.L2(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"bids":[{"price":"100.5","volume":"0.30000000000000004","count":2},{"price":"100.25","volume":"1","count":1}],"asks":[{"price":"101","volume":"2","count":1}]}`
+	if string(data) != expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+
+	var l2 L2Book
+	if err := json.Unmarshal(data, &l2); err != nil {
+		t.Fatal(err)
+	}
+	if len(l2.Bids) != 2 || l2.Bids[1].Price != 100.25 || l2.Asks[0].Orders != 1 {
+		t.Errorf("invalid decoded levels %+v", l2)
+	}
+
+	// prices are never written in exponent notation
+	data, _ = json.Marshal(book.L2(0))
+	if !strings.Contains(string(data), `"price":"0.00000001"`) {
+		t.Errorf("small prices should be decimal strings, got %s", data)
+	}
+}
+
+func TestJSONL3RoundTrip(t *testing.T) {
+	book := randomOrderbook(100, 1000)
+
+	// plain marshalling of the book produces the full view, by value or pointer
+	data, err := json.Marshal(book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPointer, _ := json.Marshal(&book)
+	held, _ := json.Marshal(struct{ Book Orderbook }{book})
+	if string(byPointer) != string(data) || string(held) != `{"Book":` + string(data) + `}` {
+		t.Errorf("books by value and by pointer should marshal the same")
+	}
+
+	var restored Orderbook
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatal(err)
+	}
+	sameOrderbooks(t, &book, &restored)
+}
+
+func TestJSONL3Schema(t *testing.T) {
+	data := `{"bids":[{"price":"10.5","volume":"3","orders":[{"id":7,"volume":"1"},{"id":8,"volume":"2"}]}],"asks":[]}`
+
+	var book Orderbook
+	if err := json.Unmarshal([]byte(data), &book); err != nil {
+		t.Fatal(err)
+	}
+	if book.GetBestBid() != 10.5 || book.GetVolumeAtBidLimit(10.5) != 3 || book.ALength() != 0 {
+		t.Errorf("invalid book from json")
+	}
+
+	out, _ := json.Marshal(&book)
+	if string(out) != data {
+		t.Errorf("expected %s, got %s", data, out)
+	}
+
+	if err := json.Unmarshal([]byte(`{"bids":[{"price":10.5}]}`), &book); err == nil {
+		t.Errorf("numeric prices should be rejected")
+	}
+
+	// cleared levels come back empty, bids are listed best first
+	data = `{"bids":[{"price":"11","volume":"0","orders":[]},{"price":"10.5","volume":"1","orders":[{"id":7,"volume":"1"}]}],"asks":[]}`
+	if err := json.Unmarshal([]byte(data), &book); err != nil {
+		t.Fatal(err)
+	}
+	if book.BLength() != 2 || book.GetBestBid() != 11 || book.GetVolumeAtBidLimit(11) != 0 {
+		t.Errorf("empty level should be restored")
+	}
+	unsorted := `{"bids":[{"price":"10.5","volume":"0","orders":[]},{"price":"11","volume":"0","orders":[]}],"asks":[]}`
+	if err := json.Unmarshal([]byte(unsorted), &book); !errors.Is(err, ErrUnsortedLevels) {
+		t.Errorf("expected unsorted levels error, got %v", err)
+	}
+}
@@ -4,15 +4,19 @@ package hftorderbook
 type LimitOrder struct {
 	Price float64
 	
-	orders *ordersQueue
+	orders Queue
 	totalVolume float64
 }
 
 func NewLimitOrder(price float64) LimitOrder {
 	q := NewOrdersQueue()
+	return NewLimitOrderWithQueue(price, &q)
+}
+
+func NewLimitOrderWithQueue(price float64, q Queue) LimitOrder {
 	return LimitOrder{
 		Price: price,
-		orders: &q,
+		orders: q,
 	}
 }
 
@@ -50,8 +54,27 @@ func (this *LimitOrder) Delete(o *Order) {
 	this.totalVolume -= o.Volume
 }
 
+// reduces the order volume in place, keeping its position in the queue
+func (this *LimitOrder) Reduce(o *Order, volume float64) {
+	if o.Limit != this {
+		panic("order does not belong to the limit")
+	}
+
+	o.Volume -= volume
+	this.totalVolume -= volume
+}
+
 func (this *LimitOrder) Clear() {
-	q := NewOrdersQueue()
-	this.orders = &q
+	this.orders.Clear()
 	this.totalVolume = 0
-}
\ No newline at end of file
+}
+
+// oldest order at the limit, nil if there are no orders
+func (this *LimitOrder) Front() *Order {
+	return this.orders.Front()
+}
+
+// order queued after o, nil if o is the newest
+func (this *LimitOrder) Next(o *Order) *Order {
+	return this.orders.Next(o)
+}
@@ -0,0 +1,297 @@
+package hftorderbook
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// LOBSTER message event types
+const (
+	LobsterSubmission = 1
+	LobsterCancellation = 2 // partial deletion
+	LobsterDeletion = 3
+	LobsterExecution = 4
+	LobsterHiddenExecution = 5
+	LobsterCrossTrade = 6
+	LobsterHalt = 7
+)
+
+const (
+	// LOBSTER prices are dollar prices times 10000
+	lobsterPriceScale = 10000.0
+
+	// dummy prices of empty levels in the orderbook file
+	lobsterEmptyAsk int64 = 9999999999
+	lobsterEmptyBid int64 = -9999999999
+)
+
+// Single row of a LOBSTER message file
+type LobsterMessage struct {
+	Time float64 // seconds after midnight
+	Type int
+	OrderId int
+	Size float64
+	Price int64
+	Direction int // 1 buy, -1 sell limit order
+}
+
+func ParseLobsterMessage(line string) (LobsterMessage, error) {
+	var m LobsterMessage
+	fields := strings.Split(strings.TrimSpace(line), ",")
+	if len(fields) < 6 {
+		return m, fmt.Errorf("lobster message should have 6 fields, got %d", len(fields))
+	}
+
+	var err error
+	if m.Time, err = strconv.ParseFloat(fields[0], 64); err != nil {
+		return m, err
+	}
+	if m.Type, err = strconv.Atoi(fields[1]); err != nil {
+		return m, err
+	}
+	if m.OrderId, err = strconv.Atoi(fields[2]); err != nil {
+		return m, err
+	}
+	if m.Size, err = strconv.ParseFloat(fields[3], 64); err != nil {
+		return m, err
+	}
+	if m.Price, err = strconv.ParseInt(fields[4], 10, 64); err != nil {
+		return m, err
+	}
+	if m.Direction, err = strconv.Atoi(fields[5]); err != nil {
+		return m, err
+	}
+
+	return m, nil
+}
+
+// Replays LOBSTER message files into an order level book
+type LobsterReplayer struct {
+	Book *Orderbook
+	Halted bool
+
+	orders map[int]*Order
+
+	// orders submitted before the start of the file, one per level
+	seeded map[lobsterLevel]*Order
+}
+
+type lobsterLevel struct {
+	price float64
+	bidOrAsk bool
+}
+
+func NewLobsterReplayer() LobsterReplayer {
+	return NewLobsterReplayerWithConfig(OrderbookConfig{})
+}
+
+// the book is created with the config, e.g. a depth window with MaxLevels
+func NewLobsterReplayerWithConfig(config OrderbookConfig) LobsterReplayer {
+	book := NewOrderbookWithConfig(config)
+	return LobsterReplayer{
+		Book: &book,
+		orders: make(map[int]*Order),
+		seeded: make(map[lobsterLevel]*Order),
+	}
+}
+
+// initializes the book from an orderbook file row, the volume of every level is
+// kept as a single anonymous order which absorbs events of unknown order ids
+func (this *LobsterReplayer) Seed(row string) error {
+	fields := strings.Split(strings.TrimSpace(row), ",")
+	if len(fields) % 4 != 0 {
+		return fmt.Errorf("lobster orderbook row should have 4 fields per level, got %d", len(fields))
+	}
+
+	for i := 0; i < len(fields); i += 2 {
+		price, err := strconv.ParseInt(fields[i], 10, 64)
+		if err != nil {
+			return err
+		}
+		size, err := strconv.ParseFloat(fields[i+1], 64)
+		if err != nil {
+			return err
+		}
+		if size == 0 || price == lobsterEmptyAsk || price == lobsterEmptyBid {
+			continue
+		}
+
+		// ask and bid columns interleave
+		bidOrAsk := (i / 2) % 2 == 1
+		o := &Order{
+			Volume: size,
+			BidOrAsk: bidOrAsk,
+		}
+		p := float64(price) / lobsterPriceScale
+		if err := this.Book.TryAdd(p, o); err != nil {
+			if errors.Is(err, ErrOutOfWindow) {
+				continue
+			}
+			return err
+		}
+		this.seeded[lobsterLevel{p, bidOrAsk}] = o
+	}
+
+	return nil
+}
+
+// reads the message file and applies every message, fn is called after each
+// of them and may be nil
+func (this *LobsterReplayer) Replay(r io.Reader, fn func(m LobsterMessage) error) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		m, err := ParseLobsterMessage(line)
+		if err != nil {
+			return err
+		}
+		if err := this.Apply(m); err != nil {
+			return err
+		}
+		if fn != nil {
+			if err := fn(m); err != nil {
+				return err
+			}
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (this *LobsterReplayer) Apply(m LobsterMessage) error {
+	price := float64(m.Price) / lobsterPriceScale
+	bidOrAsk := m.Direction == 1
+
+	switch m.Type {
+	case LobsterSubmission:
+		if this.orders[m.OrderId] != nil {
+			return fmt.Errorf("order %d is already submitted", m.OrderId)
+		}
+		o := &Order{
+			Id: m.OrderId,
+			Volume: m.Size,
+			BidOrAsk: bidOrAsk,
+		}
+		if err := this.Book.TryAdd(price, o); err != nil {
+			if errors.Is(err, ErrOutOfWindow) {
+				// not tracked, the later messages of the order are skipped
+				return nil
+			}
+			return err
+		}
+		this.orders[m.OrderId] = o
+
+	case LobsterCancellation, LobsterDeletion, LobsterExecution:
+		o := this.orders[m.OrderId]
+		seeded := o == nil
+		if seeded {
+			// the order was submitted before the start of the file
+			o = this.seeded[lobsterLevel{price, bidOrAsk}]
+			if o == nil {
+				if c := this.Book.config; c.MaxLevels > 0 && c.OutOfWindow == WindowReject {
+					// rejected by the depth window
+					return nil
+				}
+				return fmt.Errorf("unknown order %d at %d", m.OrderId, m.Price)
+			}
+		}
+
+		if m.Type == LobsterDeletion && !seeded {
+			this.Book.Cancel(o)
+		} else {
+			this.Book.Reduce(o, m.Size)
+		}
+
+		if !o.Live() {
+			if seeded {
+				delete(this.seeded, lobsterLevel{price, bidOrAsk})
+			} else {
+				delete(this.orders, m.OrderId)
+			}
+		}
+
+	case LobsterHalt:
+		// price -1 halts, 0 resumes quoting, 1 resumes trading
+		this.Halted = m.Price == -1
+
+	case LobsterHiddenExecution, LobsterCrossTrade:
+		// hidden liquidity and auctions are not visible in the book
+
+	default:
+		return fmt.Errorf("unknown lobster event type %d", m.Type)
+	}
+
+	return nil
+}
+
+// Writes LOBSTER orderbook file rows with top N levels of a book
+type LobsterWriter struct {
+	levels int
+	w *bufio.Writer
+	buf []byte
+	asks []*LimitOrder // top levels, kept to avoid allocations
+	bids []*LimitOrder
+}
+
+func NewLobsterWriter(w io.Writer, levels int) LobsterWriter {
+	return LobsterWriter{
+		levels: levels,
+		w: bufio.NewWriter(w),
+	}
+}
+
+// appends a row with the current state of the book:
+// ask price 1, ask size 1, bid price 1, bid size 1, ask price 2, ...
+func (this *LobsterWriter) Write(book *Orderbook) error {
+	this.asks = book.topLimits(this.asks[:0], book.Asks, false, this.levels)
+	this.bids = book.topLimits(this.bids[:0], book.Bids, true, this.levels)
+
+	buf := this.buf[:0]
+	for i := 0; i < this.levels; i += 1 {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+
+		if i < len(this.asks) {
+			buf = this.appendLevel(buf, this.asks[i].Price, this.asks[i].TotalVolume())
+		} else {
+			buf = this.appendEmptyLevel(buf, lobsterEmptyAsk)
+		}
+
+		buf = append(buf, ',')
+		if i < len(this.bids) {
+			buf = this.appendLevel(buf, this.bids[i].Price, this.bids[i].TotalVolume())
+		} else {
+			buf = this.appendEmptyLevel(buf, lobsterEmptyBid)
+		}
+	}
+	buf = append(buf, '\n')
+	this.buf = buf
+
+	_, err := this.w.Write(buf)
+	return err
+}
+
+func (this *LobsterWriter) appendLevel(buf []byte, price, volume float64) []byte {
+	buf = strconv.AppendInt(buf, int64(math.Round(price * lobsterPriceScale)), 10)
+	buf = append(buf, ',')
+	return strconv.AppendFloat(buf, volume, 'f', -1, 64)
+}
+
+func (this *LobsterWriter) appendEmptyLevel(buf []byte, price int64) []byte {
+	buf = strconv.AppendInt(buf, price, 10)
+	return append(buf, ",0"...)
+}
+
+func (this *LobsterWriter) Flush() error {
+	return this.w.Flush()
+}
@@ -0,0 +1,97 @@
+package hftorderbook
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+const lobsterFixture = "testdata/lobster/AAPL_2012-06-21_34200000_34201000_"
+
+func TestLobsterReplayMatchesReferenceOrderbook(t *testing.T) {
+	messages, err := os.Open(lobsterFixture + "message_2.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer messages.Close()
+
+	reference, err := os.ReadFile(lobsterFixture + "orderbook_2.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	r := NewLobsterReplayer()
+	w := NewLobsterWriter(&out, 2)
+	err = r.Replay(messages, func(m LobsterMessage) error {
+		return w.Write(r.Book)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+
+	got := strings.Split(out.String(), "\n")
+	expected := strings.Split(string(reference), "\n")
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d rows, got %d", len(expected), len(got))
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("row %d: expected %s, got %s", i + 1, expected[i], got[i])
+		}
+	}
+}
+
+func TestLobsterHalt(t *testing.T) {
+	r := NewLobsterReplayer()
+	r.Apply(LobsterMessage{Type: LobsterHalt, Price: -1})
+	if !r.Halted {
+		t.Errorf("trading should be halted")
+	}
+	r.Apply(LobsterMessage{Type: LobsterHalt, Price: 0})
+	if r.Halted {
+		t.Errorf("quoting should be resumed")
+	}
+}
+
+func TestLobsterSeed(t *testing.T) {
+	r := NewLobsterReplayer()
+	err := r.Seed("5859000,50,5853400,70,9999999999,0,-9999999999,0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Book.GetBestOffer() != 585.9 || r.Book.GetBestBid() != 585.34 {
+		t.Errorf("invalid seeded book %0.8f / %0.8f", r.Book.GetBestBid(), r.Book.GetBestOffer())
+	}
+
+	// orders from before the file start are taken from the seeded levels
+	if err := r.Apply(LobsterMessage{Type: LobsterExecution, OrderId: 1, Size: 20, Price: 5859000, Direction: -1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Apply(LobsterMessage{Type: LobsterDeletion, OrderId: 2, Size: 70, Price: 5853400, Direction: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if r.Book.GetVolumeAtAskLimit(585.9) != 30 || r.Book.BLength() != 0 {
+		t.Errorf("seeded levels should absorb unknown orders")
+	}
+
+	if err := r.Apply(LobsterMessage{Type: LobsterDeletion, OrderId: 3, Size: 1, Price: 5853400, Direction: 1}); err == nil {
+		t.Errorf("unknown order outside of seeded levels should fail")
+	}
+}
+
+func TestParseLobsterMessage(t *testing.T) {
+	m, err := ParseLobsterMessage("34200.004241176,1,16113575,18,5853300,1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Type != LobsterSubmission || m.OrderId != 16113575 || m.Size != 18 || m.Price != 5853300 || m.Direction != 1 {
+		t.Errorf("invalid message %+v", m)
+	}
+
+	if _, err := ParseLobsterMessage("34200.004241176,1,16113575"); err == nil {
+		t.Errorf("short message should fail")
+	}
+}
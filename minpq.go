@@ -1,26 +1,37 @@
 package hftorderbook
 
+import (
+	"cmp"
+)
+
 // Mininum oriented Priority Queue
-type minPQ struct {
-	keys []float64
+type MinPQ[K cmp.Ordered] struct {
+	keys []K
 	n int
 }
 
+// float64 keys
+type minPQ = MinPQ[float64]
+
 func NewMinPQ(size int) minPQ {
-	return minPQ {
-		keys: make([]float64, size + 1),
+	return NewMinPQOf[float64](size)
+}
+
+func NewMinPQOf[K cmp.Ordered](size int) MinPQ[K] {
+	return MinPQ[K] {
+		keys: make([]K, size + 1),
 	}
 }
 
-func (pq *minPQ) Size() int {
+func (pq *MinPQ[K]) Size() int {
 	return pq.n
 }
 
-func (pq *minPQ) IsEmpty() bool {
+func (pq *MinPQ[K]) IsEmpty() bool {
 	return pq.n == 0
 }
 
-func (pq *minPQ) Insert(key float64) {
+func (pq *MinPQ[K]) Insert(key K) {
 	if pq.n + 1 == cap(pq.keys) {
 		panic("pq is full")
 	}
@@ -32,7 +43,7 @@ func (pq *minPQ) Insert(key float64) {
 	pq.swim(pq.n)
 }
 
-func (pq *minPQ) Top() float64 {
+func (pq *MinPQ[K]) Top() K {
 	if pq.IsEmpty() {
 		panic("pq is empty")
 	}
@@ -41,7 +52,7 @@ func (pq *minPQ) Top() float64 {
 }
 
 // removes minimal element and returns it
-func (pq *minPQ) DelTop() float64 {
+func (pq *MinPQ[K]) DelTop() K {
 	if pq.IsEmpty() {
 		panic("pq is empty")
 	}
@@ -56,7 +67,7 @@ func (pq *minPQ) DelTop() float64 {
 	return top
 }
 
-func (pq *minPQ) swim(k int) {
+func (pq *MinPQ[K]) swim(k int) {
 	for k > 1 && pq.keys[k] < pq.keys[k/2] {
 		// swap
 		pq.keys[k], pq.keys[k/2] = pq.keys[k/2], pq.keys[k]
@@ -64,7 +75,7 @@ func (pq *minPQ) swim(k int) {
 	}
 }
 
-func (pq *minPQ) sink(k int) {
+func (pq *MinPQ[K]) sink(k int) {
 	for 2*k <= pq.n {
 		c := 2*k
 		// select minimum of two children
@@ -3,6 +3,7 @@ package hftorderbook
 import (
 	"testing"
 	"math/rand"
+	"cmp"
 	//"fmt"
 )
 
@@ -146,3 +147,38 @@ func BenchmarkMinPQ10kLevelsRandomInsertWithCaching(b *testing.B) {
 func BenchmarkMinPQ20kLevelsRandomInsertWithCaching(b *testing.B) {
 	benchmarkMinPQLimitedRandomInsertWithCaching(20000, b)
 }
+
+func TestMinPQGenericKeys(t *testing.T) {
+	pq := NewMinPQOf[string](3)
+	pq.Insert("b")
+	pq.Insert("c")
+	pq.Insert("a")
+	if pq.DelTop() != "a" || pq.Top() != "b" {
+		t.Errorf("invalid generic pq")
+	}
+}
+
+func benchmarkMinPQInsertDelTop[K cmp.Ordered](key func(x float64) K, b *testing.B) {
+	pq := NewMinPQOf[K](10001)
+	for i := 0; i < 10000; i += 1 {
+		pq.Insert(key(rand.Float64()))
+	}
+	keys := make([]K, 1024)
+	for i := range keys {
+		keys[i] = key(rand.Float64())
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		pq.Insert(keys[i & 1023])
+		pq.DelTop()
+	}
+}
+
+func BenchmarkMinPQInsertDelTop(b *testing.B) {
+	benchmarkMinPQInsertDelTop(func(x float64) float64 { return x }, b)
+}
+
+func BenchmarkMinPQInt64InsertDelTop(b *testing.B) {
+	benchmarkMinPQInsertDelTop(func(x float64) int64 { return int64(x * 1e8) }, b)
+}
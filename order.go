@@ -8,4 +8,14 @@ type Order struct {
 	Prev *Order
 	Limit *LimitOrder
 	BidOrAsk bool
+
+	pooled bool // taken from the book free list
+	slot int // position in a ring queue
+	detached bool // left out of the book by the depth window, still live
+}
+
+// true while the order rests in the book or is detached from it by the depth
+// window (OrderbookConfig.MaxLevels), false once it is cancelled or filled
+func (o *Order) Live() bool {
+	return o.Limit != nil || o.detached
 }
\ No newline at end of file
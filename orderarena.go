@@ -0,0 +1,78 @@
+package hftorderbook
+
+// number of orders allocated at once when the free list is empty
+const orderArenaChunk = 1024
+
+// Free list of orders allocated in chunks, free orders are linked through Next
+type orderArena struct {
+	free *Order
+	size int // allocated orders
+	used int
+}
+
+func (this *orderArena) get() *Order {
+	if this.free == nil {
+		this.grow()
+	}
+	o := this.free
+	this.free = o.Next
+	o.Next = nil
+	this.used++
+	return o
+}
+
+func (this *orderArena) put(o *Order) {
+	*o = Order{
+		Next: this.free,
+		pooled: true,
+	}
+	this.free = o
+	this.used--
+}
+
+func (this *orderArena) grow() {
+	chunk := make([]Order, orderArenaChunk)
+	for i := range chunk {
+		chunk[i].pooled = true
+		chunk[i].Next = this.free
+		this.free = &chunk[i]
+	}
+	this.size += orderArenaChunk
+}
+
+// order from the book free list, it is handed back to the list by Cancel,
+// by Reduce of all its volume and by clearing or deleting its limit,
+// so it must not be used after that
+func (this *Orderbook) NewOrder(id int, volume float64, bidOrAsk bool) *Order {
+	o := this.orders.get()
+	o.Id = id
+	o.Volume = volume
+	o.BidOrAsk = bidOrAsk
+	return o
+}
+
+// hands an order from NewOrder that is not in the book back to the free list
+func (this *Orderbook) Release(o *Order) {
+	if !o.pooled {
+		panic("order is not from the book free list")
+	}
+	if o.Limit != nil {
+		panic("order is still in the book")
+	}
+	this.orders.put(o)
+}
+
+// number of orders taken from the free list and not released yet
+func (this *Orderbook) OrdersInUse() int {
+	return this.orders.used
+}
+
+// hands the pooled orders of the limit back to the free list, the orders
+// are dequeued first as the free list reuses their links
+func (this *Orderbook) releaseOrders(limit *LimitOrder) {
+	for o := limit.orders.Dequeue(); o != nil; o = limit.orders.Dequeue() {
+		if o.pooled {
+			this.orders.put(o)
+		}
+	}
+}
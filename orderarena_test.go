@@ -0,0 +1,109 @@
+package hftorderbook
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestOrderbookNewOrderRelease(t *testing.T) {
+	b := NewOrderbook()
+
+	o := b.NewOrder(1, 0.5, true)
+	if o.Id != 1 || o.Volume != 0.5 || !o.BidOrAsk || b.OrdersInUse() != 1 {
+		t.Errorf("invalid new order %+v", o)
+	}
+	b.Add(1.0, o)
+	b.Cancel(o)
+	if b.OrdersInUse() != 0 {
+		t.Errorf("canceled order should be released")
+	}
+
+	// the released order is reused
+	reused := b.NewOrder(2, 0.25, false)
+	if reused != o || reused.Id != 2 || reused.Limit != nil || reused.Next != nil {
+		t.Errorf("released order should be reused and reset, got %+v", reused)
+	}
+
+	b.Add(2.0, reused)
+	b.Reduce(reused, 0.1)
+	if b.OrdersInUse() != 1 {
+		t.Errorf("partially filled order should stay in use")
+	}
+	b.Reduce(reused, 0.15)
+	if b.OrdersInUse() != 0 {
+		t.Errorf("fully filled order should be released")
+	}
+
+	// orders of cleared and deleted limits are released, own orders are left alone
+	own := &Order{Id: 3, Volume: 1, BidOrAsk: true}
+	b.Add(1.0, b.NewOrder(4, 1, true))
+	b.Add(1.0, own)
+	b.Add(3.0, b.NewOrder(5, 1, false))
+	b.ClearBidLimit(1.0)
+	b.DeleteAskLimit(3.0)
+	if b.OrdersInUse() != 0 || own.Id != 3 {
+		t.Errorf("orders of cleared limits should be released")
+	}
+
+	b.Release(b.NewOrder(6, 1, true))
+	if b.OrdersInUse() != 0 {
+		t.Errorf("order should be released")
+	}
+}
+
+func TestOrderbookReleaseMisuse(t *testing.T) {
+	b := NewOrderbook()
+	o := b.NewOrder(1, 1, true)
+	b.Add(1.0, o)
+
+	for _, o := range []*Order{o, &Order{Id: 2}} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("release of order %d should panic", o.Id)
+				}
+			}()
+			b.Release(o)
+		}()
+	}
+}
+
+// add and cancel against resting levels, should not allocate
+func benchmarkOrderbookNewOrderAddCancel(n int, b *testing.B) {
+	book := NewOrderbook()
+
+	limitslist := make([]float64, n)
+	for i := range limitslist {
+		limitslist[i] = rand.Float64()
+		book.Add(limitslist[i], book.NewOrder(-i - 1, 1, limitslist[i] < 0.5))
+	}
+	prices := make([]float64, b.N)
+	for i := range prices {
+		prices[i] = limitslist[rand.Intn(len(limitslist))]
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		price := prices[i]
+		o := book.NewOrder(i, 1, price < 0.5)
+		book.Add(price, o)
+		if i % 2 == 0 {
+			book.Cancel(o)
+		} else {
+			book.Reduce(o, 1)
+		}
+	}
+}
+
+func BenchmarkOrderbook5kLevelsNewOrderAddCancel(b *testing.B) {
+	benchmarkOrderbookNewOrderAddCancel(5000, b)
+}
+
+func BenchmarkOrderbook10kLevelsNewOrderAddCancel(b *testing.B) {
+	benchmarkOrderbookNewOrderAddCancel(10000, b)
+}
+
+func BenchmarkOrderbook20kLevelsNewOrderAddCancel(b *testing.B) {
+	benchmarkOrderbookNewOrderAddCancel(20000, b)
+}
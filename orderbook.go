@@ -1,43 +1,168 @@
 package hftorderbook
 
 import (
+	"errors"
 	"fmt"
-	"sync"
 )
 
 // maximum limits per orderbook side to pre-allocate memory
 const MaxLimitsNum int = 10000
 
+var ErrLimitsExhausted = errors.New("limits free list is exhausted")
+
+// behaviour of the limits free list when it is empty
+type PoolPolicy uint8
+
+const (
+	PoolGrow PoolPolicy = iota // allocate a new limit
+	PoolFail // TryAdd returns ErrLimitsExhausted, Add panics
+)
+
+type OrderbookConfig struct {
+	// limits of both sides with their tree nodes allocated upfront
+	Limits int
+	Exhaustion PoolPolicy
+
+	// creates the structure of a side, red-black trees if nil
+	NewSide func(bidOrAsk bool) Side
+
+	// creates the orders queue of a limit, linked lists if nil
+	NewQueue func() Queue
+
+	// keeps subtree volumes in the red-black sides for depth queries,
+	// every change of a limit volume costs O(log M)
+	Depth bool
+
+	// keeps persistent L2 sides for O(1) Version snapshots,
+	// every change of a limit volume costs O(log M)
+	Versions bool
+
+	// price levels kept per side, 0 for no limit, the worst level is evicted
+	// for a better one and orders priced out of a full side follow OutOfWindow
+	MaxLevels int
+	OutOfWindow WindowPolicy
+	// called before the orders of an evicted limit are detached from it
+	OnEvict func(bidOrAsk bool, limit *LimitOrder)
+}
+
 type Orderbook struct {
-	Bids *redBlackBST
-	Asks *redBlackBST
+	Bids Side
+	Asks Side
 
 	bidLimitsCache map[float64]*LimitOrder
 	askLimitsCache map[float64]*LimitOrder
-	pool *sync.Pool
+	config OrderbookConfig
+	limits *freeList[LimitOrder]
+	nodes *freeList[nodeRedBlack]
+	orders *orderArena
+	bidLevels *persistentLevels // kept with OrderbookConfig.Versions
+	askLevels *persistentLevels
+
+	listeners []func(e Event)
 }
 
 func NewOrderbook() Orderbook {
-	bids := NewRedBlackBST()
-	asks := NewRedBlackBST()
-	return Orderbook{
-		Bids: &bids,
-		Asks: &asks,
+	return NewOrderbookWithConfig(OrderbookConfig{})
+}
+
+func NewOrderbookWithConfig(config OrderbookConfig) Orderbook {
+	cacheSize := MaxLimitsNum
+	if config.Limits > cacheSize {
+		cacheSize = config.Limits
+	}
+
+	// limits and nodes are taken and released together, so only limits fail
+	limits := newFreeList(config.Limits, config.Exhaustion == PoolGrow, func(limit *LimitOrder) {
+		if config.NewQueue != nil {
+			*limit = NewLimitOrderWithQueue(0.0, config.NewQueue())
+		} else {
+			*limit = NewLimitOrder(0.0)
+		}
+	})
+
+	var bids, asks Side
+	var nodes *freeList[nodeRedBlack]
+	if config.NewSide != nil {
+		if config.Depth {
+			panic("depth queries need the red-black sides")
+		}
+		bids, asks = config.NewSide(true), config.NewSide(false)
+		nodes = newFreeList[nodeRedBlack](0, true, nil)
+	} else {
+		nodes = newFreeList[nodeRedBlack](config.Limits, true, nil)
+		bidsTree, asksTree := NewRedBlackBST(), NewRedBlackBST()
+		bidsTree.nodes = nodes
+		asksTree.nodes = nodes
+		if config.Depth {
+			bidsTree.aggregate = limitSums
+			asksTree.aggregate = limitSums
+		}
+		bids, asks = &bidsTree, &asksTree
+	}
+
+	book := Orderbook{
+		Bids: bids,
+		Asks: asks,
+
+		bidLimitsCache: make(map[float64]*LimitOrder, cacheSize),
+		askLimitsCache: make(map[float64]*LimitOrder, cacheSize),
+		config: config,
+		limits: limits,
+		nodes: nodes,
+		orders: &orderArena{},
+	}
+	if config.Versions {
+		book.bidLevels = NewPersistentRedBlackBSTOf[float64, Level]()
+		book.askLevels = NewPersistentRedBlackBSTOf[float64, Level]()
+	}
+	return book
+}
+
+// free lists usage of price limits and red-black tree nodes
+func (this *Orderbook) PoolStats() (limits, nodes PoolStats) {
+	return this.limits.stats, this.nodes.stats
+}
+
+// registers a function called after every change of the book
+func (this *Orderbook) Subscribe(fn func(e Event)) {
+	this.listeners = append(this.listeners, fn)
+}
+
+// keeps the optional views in step after the volume of the limit changed
+func (this *Orderbook) refresh(limit *LimitOrder, bidOrAsk bool) {
+	if this.config.Depth {
+		this.refreshDepth(limit, bidOrAsk)
+	}
+	if this.config.Versions {
+		this.refreshVersion(limit, bidOrAsk)
+	}
+}
 
-		bidLimitsCache: make(map[float64]*LimitOrder, MaxLimitsNum),
-		askLimitsCache: make(map[float64]*LimitOrder, MaxLimitsNum),
-		pool: &sync.Pool {
-			New: func()interface{} {
-				limit := NewLimitOrder(0.0)
-				return &limit
-			},
-		},
+// drops the limit from the optional views after it left the book
+func (this *Orderbook) removed(price float64, bidOrAsk bool) {
+	if this.config.Versions {
+		this.removeVersion(price, bidOrAsk)
 	}
 }
 
+func (this *Orderbook) emit(t EventType, bidOrAsk bool, price float64, id int, volume float64) {
+	for _, fn := range this.listeners {
+		fn(Event{t, bidOrAsk, price, id, volume})
+	}
+}
+
+// panics with ErrLimitsExhausted if a new limit is required and
+// the limits free list is empty and does not grow
 func (this *Orderbook) Add(price float64, o *Order) {
+	if err := this.TryAdd(price, o); err != nil {
+		panic(err)
+	}
+}
+
+func (this *Orderbook) TryAdd(price float64, o *Order) error {
 	var limit *LimitOrder
 
+	price = this.snapPrice(price, o.BidOrAsk)
 	if o.BidOrAsk {
 		limit = this.bidLimitsCache[price]
 	} else {
@@ -45,8 +170,23 @@ func (this *Orderbook) Add(price float64, o *Order) {
 	}
 
 	if limit == nil {
-		// getting a new limit from pool
-		limit = this.pool.Get().(*LimitOrder)
+		if err := this.checkPrice(price, o.BidOrAsk); err != nil {
+			return err
+		}
+		if this.config.MaxLevels > 0 && !this.makeRoom(price, o.BidOrAsk) {
+			// out of the depth window
+			if this.config.OutOfWindow == WindowReject {
+				return ErrOutOfWindow
+			}
+			o.detached = true
+			return nil
+		}
+
+		// getting a new limit from the free list
+		limit = this.limits.get()
+		if limit == nil {
+			return ErrLimitsExhausted
+		}
 		limit.Price = price
 
 		// insert into the corresponding BST and cache
@@ -61,9 +201,27 @@ func (this *Orderbook) Add(price float64, o *Order) {
 
 	// add order to the limit
 	limit.Enqueue(o)
+	o.detached = false
+	this.refresh(limit, o.BidOrAsk)
+
+	if this.listeners != nil {
+		this.emit(EventAdd, o.BidOrAsk, price, o.Id, o.Volume)
+	}
+	return nil
 }
 
 func (this *Orderbook) Cancel(o *Order) {
+	if o.Limit == nil {
+		this.dropDetached(o)
+		return
+	}
+	if this.listeners != nil {
+		this.emit(EventCancel, o.BidOrAsk, o.Limit.Price, o.Id, o.Volume)
+	}
+	this.cancel(o)
+}
+
+func (this *Orderbook) cancel(o *Order) {
 	limit := o.Limit
 	limit.Delete(o)
 	
@@ -77,8 +235,42 @@ func (this *Orderbook) Cancel(o *Order) {
 			delete(this.askLimitsCache, limit.Price)
 		}
 
-		// put it back to the pool
-		this.pool.Put(limit)
+		this.removed(limit.Price, o.BidOrAsk)
+
+		// put it back to the free list
+		limit.totalVolume = 0
+		this.limits.put(limit)
+	} else {
+		this.refresh(limit, o.BidOrAsk)
+	}
+
+	if o.pooled {
+		this.orders.put(o)
+	}
+}
+
+// reduces the order volume keeping its time priority,
+// the order is removed from the book once nothing is left
+func (this *Orderbook) Reduce(o *Order, volume float64) {
+	if volume > o.Volume {
+		volume = o.Volume
+	}
+	if o.Limit == nil {
+		o.Volume -= volume
+		if o.Volume <= 0 {
+			this.dropDetached(o)
+		}
+		return
+	}
+	if this.listeners != nil {
+		this.emit(EventReduce, o.BidOrAsk, o.Limit.Price, o.Id, volume)
+	}
+
+	o.Limit.Reduce(o, volume)
+	if o.Volume <= 0 {
+		this.cancel(o)
+	} else {
+		this.refresh(o.Limit, o.BidOrAsk)
 	}
 }
 
@@ -92,6 +284,7 @@ func (this *Orderbook) ClearAskLimit(price float64) {
 
 func (this *Orderbook) clearLimit(price float64, bidOrAsk bool) {
 	var limit *LimitOrder
+	price = this.snapPrice(price, bidOrAsk)
 	if bidOrAsk {
 		limit = this.bidLimitsCache[price]
 	} else {
@@ -102,36 +295,51 @@ func (this *Orderbook) clearLimit(price float64, bidOrAsk bool) {
 		panic(fmt.Sprintf("there is no such price limit %0.8f", price))
 	}
 
+	if this.listeners != nil {
+		this.emit(EventClearLimit, bidOrAsk, price, 0, limit.TotalVolume())
+	}
+	this.releaseOrders(limit)
 	limit.Clear()
+	this.refresh(limit, bidOrAsk)
 }
 
 func (this *Orderbook) DeleteBidLimit(price float64) {
+	price = this.snapPrice(price, true)
 	limit := this.bidLimitsCache[price]
 	if limit == nil {
 		return
 	}
 
+	if this.listeners != nil {
+		this.emit(EventDeleteLimit, true, price, 0, limit.TotalVolume())
+	}
 	this.deleteLimit(price, true)
 	delete(this.bidLimitsCache, price)
 
-	// put limit back to the pool
+	// put limit back to the free list
+	this.releaseOrders(limit)
 	limit.Clear()
-	this.pool.Put(limit)
+	this.limits.put(limit)
 
 }
 
 func (this *Orderbook) DeleteAskLimit(price float64) {
+	price = this.snapPrice(price, false)
 	limit := this.askLimitsCache[price]
 	if limit == nil {
 		return
 	}
 
+	if this.listeners != nil {
+		this.emit(EventDeleteLimit, false, price, 0, limit.TotalVolume())
+	}
 	this.deleteLimit(price, false)
 	delete(this.askLimitsCache, price)
 
-	// put limit back to the pool
+	// put limit back to the free list
+	this.releaseOrders(limit)
 	limit.Clear()
-	this.pool.Put(limit)
+	this.limits.put(limit)
 }
 
 func (this *Orderbook) deleteLimit(price float64, bidOrAsk bool) {
@@ -140,10 +348,11 @@ func (this *Orderbook) deleteLimit(price float64, bidOrAsk bool) {
 	} else {
 		this.Asks.Delete(price)
 	}
+	this.removed(price, bidOrAsk)
 }
 
 func (this *Orderbook) GetVolumeAtBidLimit(price float64) float64 {
-	limit := this.bidLimitsCache[price]
+	limit := this.bidLimitsCache[this.snapPrice(price, true)]
 	if limit == nil {
 		return 0
 	}
@@ -151,7 +360,7 @@ func (this *Orderbook) GetVolumeAtBidLimit(price float64) float64 {
 }
 
 func (this *Orderbook) GetVolumeAtAskLimit(price float64) float64 {
-	limit := this.askLimitsCache[price]
+	limit := this.askLimitsCache[this.snapPrice(price, false)]
 	if limit == nil {
 		return 0
 	}
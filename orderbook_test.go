@@ -107,6 +107,36 @@ func TestGetVolumeAtLimit(t *testing.T) {
 	}
 }
 
+func TestOrderbookReduce(t *testing.T) {
+	b := NewOrderbook()
+	bid1 := &Order{
+		Id: 1,
+		BidOrAsk: true,
+		Volume: 0.5,
+	}
+	bid2 := &Order{
+		Id: 2,
+		BidOrAsk: true,
+		Volume: 0.25,
+	}
+	b.Add(1.0, bid1)
+	b.Add(1.0, bid2)
+
+	b.Reduce(bid1, 0.25)
+	if bid1.Volume != 0.25 || b.GetVolumeAtBidLimit(1.0) != 0.5 {
+		t.Errorf("invalid volume after reduce: %0.8f", b.GetVolumeAtBidLimit(1.0))
+	}
+	if bid1.Limit.Front() != bid1 {
+		t.Errorf("reduced order should keep its priority")
+	}
+
+	b.Reduce(bid1, 0.25)
+	b.Reduce(bid2, 1.0)
+	if b.BLength() != 0 {
+		t.Errorf("fully reduced orders should be removed from the book")
+	}
+}
+
 func benchmarkOrderbookLimitedRandomInsert(n int, b *testing.B) {
 	book := NewOrderbook()
 
@@ -1,7 +1,21 @@
 package hftorderbook
 
-// Doubly linked orders queue
-// TODO: this should be compared with ring buffer queue performance
+// FIFO queue of orders at a limit price
+type Queue interface {
+	Size() int
+	IsEmpty() bool
+	Enqueue(o *Order)
+	Dequeue() *Order
+	Delete(o *Order)
+	Clear()
+
+	// walking the queue from the oldest order, nil after the last one
+	Front() *Order
+	Next(o *Order) *Order
+}
+
+// Doubly linked orders queue, faster than ringQueue on fills, cancels and
+// walks (see the Queue1k benchmarks), so limits use it by default
 type ordersQueue struct {
 	head *Order
 	tail *Order
@@ -21,11 +35,13 @@ func (this *ordersQueue) IsEmpty() bool {
 }
 
 func (this *ordersQueue) Enqueue(o *Order) {
+	// links left from another queue would extend the walks past the tail
 	tail := this.tail
 	this.tail = o
+	o.Next = nil
+	o.Prev = tail
 	if tail != nil {
 		tail.Next = o
-		o.Prev = tail
 	}
 	if this.head == nil {
 		this.head = o
@@ -44,6 +60,11 @@ func (this *ordersQueue) Dequeue() *Order {
 	}
 
 	this.head = this.head.Next
+	if this.head != nil {
+		this.head.Prev = nil
+	}
+	head.Next = nil
+	head.Prev = nil
 	this.size--
 	return head
 }
@@ -69,3 +90,22 @@ func (this *ordersQueue) Delete(o *Order) {
 		this.tail = prev
 	}
 }
+
+// unlinks the orders still in the queue
+func (this *ordersQueue) Clear() {
+	for o := this.head; o != nil; {
+		next := o.Next
+		o.Next = nil
+		o.Prev = nil
+		o = next
+	}
+	*this = NewOrdersQueue()
+}
+
+func (this *ordersQueue) Front() *Order {
+	return this.head
+}
+
+func (this *ordersQueue) Next(o *Order) *Order {
+	return o.Next
+}
@@ -0,0 +1,497 @@
+package hftorderbook
+
+import (
+	"cmp"
+	"fmt"
+	"sync/atomic"
+)
+
+// Persistent left-leaning red-black tree. Updates copy the nodes on their
+// path instead of changing them, so a snapshot is a copy of the root pointer
+// taken in O(1) and stays valid while the tree keeps changing. Nodes created
+// since the last snapshot carry the current epoch of the tree and are changed
+// in place, so writes between snapshots allocate only for the frozen nodes.
+// Trees are used through the pointers returned by the constructor and
+// Snapshot: a copy of the struct value owns the same epoch and changes the
+// nodes it shares with the original in place.
+
+type persistentNode[K cmp.Ordered, V any] struct {
+	key K
+	value V
+	left *persistentNode[K, V]
+	right *persistentNode[K, V]
+	size int
+	isRed bool
+	epoch uint64 // owner tree epoch, frozen once it is snapshot
+}
+
+type PersistentRedBlackBST[K cmp.Ordered, V any] struct {
+	root *persistentNode[K, V]
+	epoch uint64 // 0 if all nodes are frozen
+}
+
+// source of the tree epochs, unique among all trees and their snapshots
+var persistentEpochs atomic.Uint64
+
+func NewPersistentRedBlackBSTOf[K cmp.Ordered, V any]() *PersistentRedBlackBST[K, V] {
+	return &PersistentRedBlackBST[K, V]{}
+}
+
+// immutable copy of the tree in O(1), safe to be read from other goroutines
+// once handed over, the next update of either tree copies the shared nodes
+func (t *PersistentRedBlackBST[K, V]) Snapshot() *PersistentRedBlackBST[K, V] {
+	t.epoch = 0
+	return &PersistentRedBlackBST[K, V]{root: t.root}
+}
+
+func (t *PersistentRedBlackBST[K, V]) Size() int {
+	return t.size(t.root)
+}
+
+func (t *PersistentRedBlackBST[K, V]) size(n *persistentNode[K, V]) int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (t *PersistentRedBlackBST[K, V]) IsEmpty() bool {
+	return t.root == nil
+}
+
+func (t *PersistentRedBlackBST[K, V]) panicIfEmpty() {
+	if t.IsEmpty() {
+		panic("Persistent Red Black BST is empty")
+	}
+}
+
+func (t *PersistentRedBlackBST[K, V]) get(key K) *persistentNode[K, V] {
+	n := t.root
+	for n != nil && n.key != key {
+		if n.key > key {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	return n
+}
+
+func (t *PersistentRedBlackBST[K, V]) Contains(key K) bool {
+	return t.get(key) != nil
+}
+
+func (t *PersistentRedBlackBST[K, V]) Get(key K) V {
+	t.panicIfEmpty()
+
+	n := t.get(key)
+	if n == nil {
+		panic(fmt.Sprintf("key %v does not exist", key))
+	}
+	return n.value
+}
+
+// node that can be changed in place, copied if it is frozen
+func (t *PersistentRedBlackBST[K, V]) mutable(n *persistentNode[K, V]) *persistentNode[K, V] {
+	if t.epoch == 0 {
+		t.epoch = persistentEpochs.Add(1)
+	}
+	if n.epoch == t.epoch {
+		return n
+	}
+
+	c := *n
+	c.epoch = t.epoch
+	return &c
+}
+
+func (t *PersistentRedBlackBST[K, V]) isRed(n *persistentNode[K, V]) bool {
+	return n != nil && n.isRed
+}
+
+// n must be mutable, the children are copied before their colors change
+func (t *PersistentRedBlackBST[K, V]) flipColors(n *persistentNode[K, V]) {
+	n.isRed = !n.isRed
+	if n.left != nil {
+		n.left = t.mutable(n.left)
+		n.left.isRed = !n.left.isRed
+	}
+	if n.right != nil {
+		n.right = t.mutable(n.right)
+		n.right.isRed = !n.right.isRed
+	}
+}
+
+// n must be mutable
+func (t *PersistentRedBlackBST[K, V]) rotateLeft(n *persistentNode[K, V]) *persistentNode[K, V] {
+	x := t.mutable(n.right)
+	n.right = x.left
+	x.left = n
+
+	x.isRed = n.isRed
+	n.isRed = true
+
+	n.size = t.size(n.left) + 1 + t.size(n.right)
+	x.size = t.size(x.left) + 1 + t.size(x.right)
+	return x
+}
+
+// n must be mutable
+func (t *PersistentRedBlackBST[K, V]) rotateRight(n *persistentNode[K, V]) *persistentNode[K, V] {
+	x := t.mutable(n.left)
+	n.left = x.right
+	x.right = n
+
+	x.isRed = n.isRed
+	n.isRed = true
+
+	n.size = t.size(n.left) + 1 + t.size(n.right)
+	x.size = t.size(x.left) + 1 + t.size(x.right)
+	return x
+}
+
+// restores the left-leaning invariants of the mutable n on the way up
+func (t *PersistentRedBlackBST[K, V]) balance(n *persistentNode[K, V]) *persistentNode[K, V] {
+	if t.isRed(n.right) && !t.isRed(n.left) {
+		n = t.rotateLeft(n)
+	}
+	if t.isRed(n.left) && t.isRed(n.left.left) {
+		n = t.rotateRight(n)
+	}
+	if t.isRed(n.left) && t.isRed(n.right) {
+		t.flipColors(n)
+	}
+
+	n.size = t.size(n.left) + 1 + t.size(n.right)
+	return n
+}
+
+func (t *PersistentRedBlackBST[K, V]) Put(key K, value V) {
+	t.root = t.put(t.root, key, value)
+	if t.root.isRed {
+		t.root = t.mutable(t.root)
+		t.root.isRed = false
+	}
+}
+
+func (t *PersistentRedBlackBST[K, V]) put(n *persistentNode[K, V], key K, value V) *persistentNode[K, V] {
+	if n == nil {
+		if t.epoch == 0 {
+			t.epoch = persistentEpochs.Add(1)
+		}
+		return &persistentNode[K, V]{
+			key: key,
+			value: value,
+			size: 1,
+			isRed: true,
+			epoch: t.epoch,
+		}
+	}
+
+	n = t.mutable(n)
+	if n.key == key {
+		// search hit, updating the value
+		n.value = value
+		return n
+	}
+	if n.key > key {
+		n.left = t.put(n.left, key, value)
+	} else {
+		n.right = t.put(n.right, key, value)
+	}
+	return t.balance(n)
+}
+
+// n must be mutable
+func (t *PersistentRedBlackBST[K, V]) moveRedLeft(n *persistentNode[K, V]) *persistentNode[K, V] {
+	t.flipColors(n)
+	if t.isRed(n.right.left) {
+		n.right = t.rotateRight(n.right)
+		n = t.rotateLeft(n)
+		t.flipColors(n)
+	}
+	return n
+}
+
+// n must be mutable
+func (t *PersistentRedBlackBST[K, V]) moveRedRight(n *persistentNode[K, V]) *persistentNode[K, V] {
+	t.flipColors(n)
+	if t.isRed(n.left.left) {
+		n = t.rotateRight(n)
+		t.flipColors(n)
+	}
+	return n
+}
+
+func (t *PersistentRedBlackBST[K, V]) deleteMin(n *persistentNode[K, V]) *persistentNode[K, V] {
+	if n.left == nil {
+		return nil
+	}
+
+	n = t.mutable(n)
+	if !t.isRed(n.left) && !t.isRed(n.left.left) {
+		n = t.moveRedLeft(n)
+	}
+	n.left = t.deleteMin(n.left)
+	return t.balance(n)
+}
+
+func (t *PersistentRedBlackBST[K, V]) Delete(key K) {
+	t.panicIfEmpty()
+
+	if !t.Contains(key) {
+		// search miss, nothing is copied
+		return
+	}
+
+	t.root = t.mutable(t.root)
+	if !t.isRed(t.root.left) && !t.isRed(t.root.right) {
+		t.root.isRed = true
+	}
+	t.root = t.delete(t.root, key)
+	if t.root != nil && t.root.isRed {
+		t.root = t.mutable(t.root)
+		t.root.isRed = false
+	}
+}
+
+// n must be mutable and the key must exist in its subtree
+func (t *PersistentRedBlackBST[K, V]) delete(n *persistentNode[K, V], key K) *persistentNode[K, V] {
+	if n.key > key {
+		if !t.isRed(n.left) && !t.isRed(n.left.left) {
+			n = t.moveRedLeft(n)
+		}
+		n.left = t.delete(t.mutable(n.left), key)
+	} else {
+		if t.isRed(n.left) {
+			n = t.rotateRight(n)
+		}
+		if n.key == key && n.right == nil {
+			return nil
+		}
+
+		if !t.isRed(n.right) && !t.isRed(n.right.left) {
+			n = t.moveRedRight(n)
+		}
+		if n.key == key {
+			// replacing with the successor
+			successor := n.right
+			for successor.left != nil {
+				successor = successor.left
+			}
+			n.key = successor.key
+			n.value = successor.value
+			n.right = t.deleteMin(n.right)
+		} else {
+			n.right = t.delete(t.mutable(n.right), key)
+		}
+	}
+	return t.balance(n)
+}
+
+func (t *PersistentRedBlackBST[K, V]) minNode() *persistentNode[K, V] {
+	t.panicIfEmpty()
+	n := t.root
+	for n.left != nil {
+		n = n.left
+	}
+	return n
+}
+
+func (t *PersistentRedBlackBST[K, V]) maxNode() *persistentNode[K, V] {
+	t.panicIfEmpty()
+	n := t.root
+	for n.right != nil {
+		n = n.right
+	}
+	return n
+}
+
+func (t *PersistentRedBlackBST[K, V]) Min() K {
+	return t.minNode().key
+}
+
+func (t *PersistentRedBlackBST[K, V]) MinValue() V {
+	return t.minNode().value
+}
+
+func (t *PersistentRedBlackBST[K, V]) Max() K {
+	return t.maxNode().key
+}
+
+func (t *PersistentRedBlackBST[K, V]) MaxValue() V {
+	return t.maxNode().value
+}
+
+// greatest node with the key <= key (or < key if strict)
+func (t *PersistentRedBlackBST[K, V]) floor(key K, strict bool) *persistentNode[K, V] {
+	var floor *persistentNode[K, V]
+	for n := t.root; n != nil; {
+		if n.key < key || !strict && n.key == key {
+			floor = n
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	return floor
+}
+
+// least node with the key >= key (or > key if strict)
+func (t *PersistentRedBlackBST[K, V]) ceiling(key K, strict bool) *persistentNode[K, V] {
+	var ceiling *persistentNode[K, V]
+	for n := t.root; n != nil; {
+		if n.key > key || !strict && n.key == key {
+			ceiling = n
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	return ceiling
+}
+
+func (t *PersistentRedBlackBST[K, V]) Floor(key K) K {
+	t.panicIfEmpty()
+
+	floor := t.floor(key, false)
+	if floor == nil {
+		panic(fmt.Sprintf("there are no keys <= %v", key))
+	}
+	return floor.key
+}
+
+func (t *PersistentRedBlackBST[K, V]) Ceiling(key K) K {
+	t.panicIfEmpty()
+
+	ceiling := t.ceiling(key, false)
+	if ceiling == nil {
+		panic(fmt.Sprintf("there are no keys >= %v", key))
+	}
+	return ceiling.key
+}
+
+// value of the least key > key, zero value if there is none
+func (t *PersistentRedBlackBST[K, V]) Next(key K) V {
+	if n := t.ceiling(key, true); n != nil {
+		return n.value
+	}
+	var zero V
+	return zero
+}
+
+// value of the greatest key < key, zero value if there is none
+func (t *PersistentRedBlackBST[K, V]) Prev(key K) V {
+	if n := t.floor(key, true); n != nil {
+		return n.value
+	}
+	var zero V
+	return zero
+}
+
+func (t *PersistentRedBlackBST[K, V]) Select(k int) K {
+	if k < 0 || k >= t.Size() {
+		panic("index out of range")
+	}
+
+	n := t.root
+	for t.size(n.left) != k {
+		if t.size(n.left) > k {
+			n = n.left
+		} else {
+			k -= t.size(n.left) + 1
+			n = n.right
+		}
+	}
+	return n.key
+}
+
+// number of keys < key
+func (t *PersistentRedBlackBST[K, V]) Rank(key K) int {
+	rank := 0
+	for n := t.root; n != nil; {
+		if n.key < key {
+			rank += t.size(n.left) + 1
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	return rank
+}
+
+func (t *PersistentRedBlackBST[K, V]) Range(lo, hi K, fn func(value V) bool) {
+	t.walk(t.root, lo, hi, false, func(key K, value V) bool {
+		return fn(value)
+	})
+}
+
+// all keys in ascending or descending order until fn returns false
+func (t *PersistentRedBlackBST[K, V]) Walk(descending bool, fn func(key K, value V) bool) {
+	if !t.IsEmpty() {
+		t.walk(t.root, t.Min(), t.Max(), descending, fn)
+	}
+}
+
+// in-order walk of the keys between lo and hi, false if fn stopped it
+func (t *PersistentRedBlackBST[K, V]) walk(n *persistentNode[K, V], lo, hi K, descending bool, fn func(key K, value V) bool) bool {
+	if n == nil {
+		return true
+	}
+
+	first, second := n.left, n.right
+	visitFirst, visitSecond := n.key > lo, n.key < hi
+	if descending {
+		first, second = second, first
+		visitFirst, visitSecond = visitSecond, visitFirst
+	}
+
+	if visitFirst && !t.walk(first, lo, hi, descending, fn) {
+		return false
+	}
+	if n.key >= lo && n.key <= hi && !fn(n.key, n.value) {
+		return false
+	}
+	return !visitSecond || t.walk(second, lo, hi, descending, fn)
+}
+
+func (t *PersistentRedBlackBST[K, V]) Keys(lo, hi K) []K {
+	if lo < t.Min() || hi > t.Max() {
+		panic("keys out of range")
+	}
+
+	keys := make([]K, 0)
+	t.walk(t.root, lo, hi, false, func(key K, value V) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+func (t *PersistentRedBlackBST[K, V]) IsRedBlack() bool {
+	_, balanced := t.blackHeight(t.root)
+	return balanced && !t.isRed(t.root)
+}
+
+// black height of the subtree, false if it breaks the invariants
+func (t *PersistentRedBlackBST[K, V]) blackHeight(n *persistentNode[K, V]) (int, bool) {
+	if n == nil {
+		return 1, true
+	}
+	if t.isRed(n.right) || t.isRed(n) && t.isRed(n.left) {
+		return 0, false
+	}
+	if n.size != t.size(n.left) + 1 + t.size(n.right) {
+		return 0, false
+	}
+
+	l, lok := t.blackHeight(n.left)
+	r, rok := t.blackHeight(n.right)
+	if !n.isRed {
+		l++
+	}
+	if !lok || !rok || l - r != 0 && n.isRed || l - r != 1 && !n.isRed {
+		return 0, false
+	}
+	return l, true
+}
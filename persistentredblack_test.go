@@ -0,0 +1,155 @@
+package hftorderbook
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestPersistentRedBlackEmpty(t *testing.T) {
+	st := NewPersistentRedBlackBSTOf[int, int]()
+	if !st.IsEmpty() || st.Size() != 0 || st.Contains(1) || st.Next(1) != 0 || st.Prev(1) != 0 {
+		t.Errorf("tree should be empty")
+	}
+}
+
+// checks the tree has exactly the keys of the map in order
+func samePersistentKeys(t *testing.T, st *PersistentRedBlackBST[int, int], expected map[int]int) {
+	if !st.IsRedBlack() {
+		t.Fatalf("tree should be balanced")
+	}
+	if st.Size() != len(expected) {
+		t.Fatalf("expected size %d, got %d", len(expected), st.Size())
+	}
+
+	keys := make([]int, 0, len(expected))
+	for k := range expected {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	i := 0
+	st.Walk(false, func(k, v int) bool {
+		if k != keys[i] || v != expected[k] {
+			t.Fatalf("expected key %d with %d, got %d with %d", keys[i], expected[k], k, v)
+		}
+		i += 1
+		return true
+	})
+	for i, k := range keys {
+		if st.Select(i) != k || st.Rank(k) != i || st.Get(k) != expected[k] {
+			t.Fatalf("invalid select, rank or value of key %d", k)
+		}
+	}
+}
+
+func TestPersistentRedBlackSnapshots(t *testing.T) {
+	st := NewPersistentRedBlackBSTOf[int, int]()
+	expected := make(map[int]int)
+
+	snapshots := make([]*PersistentRedBlackBST[int, int], 0)
+	contents := make([]map[int]int, 0)
+	for i := 0; i < 20000; i += 1 {
+		k := rand.Intn(2000)
+		if rand.Intn(3) == 0 && len(expected) > 0 {
+			st.Delete(k)
+			delete(expected, k)
+		} else {
+			st.Put(k, i)
+			expected[k] = i
+		}
+
+		if i % 1000 == 0 {
+			samePersistentKeys(t, st, expected)
+
+			copied := make(map[int]int, len(expected))
+			for k, v := range expected {
+				copied[k] = v
+			}
+			snapshots = append(snapshots, st.Snapshot())
+			contents = append(contents, copied)
+		}
+	}
+
+	// old versions are not touched by the later writes
+	for i := range snapshots {
+		samePersistentKeys(t, snapshots[i], contents[i])
+	}
+
+	// a snapshot can be changed without changing the tree
+	fork := st.Snapshot()
+	for k := range expected {
+		fork.Delete(k)
+	}
+	if !fork.IsEmpty() {
+		t.Errorf("fork should be empty")
+	}
+	samePersistentKeys(t, st, expected)
+}
+
+func TestPersistentRedBlackConcurrentReaders(t *testing.T) {
+	st := NewPersistentRedBlackBSTOf[int, int]()
+	for i := 0; i < 1000; i += 1 {
+		st.Put(i, i)
+	}
+
+	snapshots := make(chan *PersistentRedBlackBST[int, int])
+	done := make(chan bool)
+	go func() {
+		// every version has the keys 0..999 with values summing up the same
+		for s := range snapshots {
+			sum := 0
+			s.Walk(false, func(k, v int) bool {
+				sum += v - k
+				return true
+			})
+			if s.Size() != 1000 || sum != 0 {
+				t.Errorf("reader got an inconsistent version")
+			}
+		}
+		done <- true
+	}()
+
+	for i := 0; i < 100; i += 1 {
+		snapshots <- st.Snapshot()
+		// moving a unit between two keys keeps the sum
+		for j := 0; j < 100; j += 1 {
+			a, b := rand.Intn(1000), rand.Intn(1000)
+			st.Put(a, st.Get(a) + 1)
+			st.Put(b, st.Get(b) - 1)
+		}
+	}
+	close(snapshots)
+	<-done
+}
+
+func benchmarkPersistentRedBlackPut(snapshotEvery int, b *testing.B) {
+	st := NewPersistentRedBlackBSTOf[float64, int]()
+	for i := 0; i < 10000; i += 1 {
+		st.Put(rand.Float64(), i)
+	}
+	keys := make([]float64, 1024)
+	for i := range keys {
+		keys[i] = st.Select(rand.Intn(st.Size()))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		if snapshotEvery > 0 && i % snapshotEvery == 0 {
+			st.Snapshot()
+		}
+		st.Put(keys[i % len(keys)], i)
+	}
+}
+
+func BenchmarkPersistentRedBlack10kPut(b *testing.B) {
+	benchmarkPersistentRedBlackPut(0, b)
+}
+
+func BenchmarkPersistentRedBlack10kPutSnapshotEvery100(b *testing.B) {
+	benchmarkPersistentRedBlackPut(100, b)
+}
+
+func BenchmarkPersistentRedBlack10kPutSnapshotEvery1(b *testing.B) {
+	benchmarkPersistentRedBlackPut(1, b)
+}
@@ -1,40 +1,59 @@
 package hftorderbook
 
 import (
+	"cmp"
 	"fmt"
+	"math/bits"
 )
 
 // A self-balancing Binary Search Tree with 2*lgN worst case garantees for
 // search, put, delete, min, max, select, rank, floor, ceiling operations.
 // Average runtine for search-based operations estimated as 1*lgN
 
-type nodeRedBlack struct {
-	Key float64
-	Value *LimitOrder
-	Next *nodeRedBlack
-	Prev *nodeRedBlack
+type RedBlackNode[K cmp.Ordered, V any] struct {
+	Key K
+	Value V
+	Next *RedBlackNode[K, V]
+	Prev *RedBlackNode[K, V]
 	
-	left *nodeRedBlack
-	right *nodeRedBlack
+	left *RedBlackNode[K, V]
+	right *RedBlackNode[K, V]
 	size int
 	isRed bool
+
+	// subtree sums, kept only if the tree has an aggregate function
+	volume float64
+	count int
 }
 
-type redBlackBST struct {
-	root *nodeRedBlack
-	minC *nodeRedBlack // cached min/max keys for O(1) access
-	maxC *nodeRedBlack
+type RedBlackBST[K cmp.Ordered, V any] struct {
+	root *RedBlackNode[K, V]
+	minC *RedBlackNode[K, V] // cached min/max keys for O(1) access
+	maxC *RedBlackNode[K, V]
+
+	nodes *freeList[RedBlackNode[K, V]] // nil to allocate nodes on every put
+
+	// volume and count of a value summed over subtrees, nil to keep sizes only
+	aggregate func(value V) (volume float64, count int)
 }
 
+// price levels tree, the zero value of any RedBlackBST is an empty tree
+type redBlackBST = RedBlackBST[float64, *LimitOrder]
+type nodeRedBlack = RedBlackNode[float64, *LimitOrder]
+
 func NewRedBlackBST() redBlackBST {
 	return redBlackBST{}
 }
 
-func (t *redBlackBST) Size() int {
+func NewRedBlackBSTOf[K cmp.Ordered, V any]() RedBlackBST[K, V] {
+	return RedBlackBST[K, V]{}
+}
+
+func (t *RedBlackBST[K, V]) Size() int {
 	return t.size(t.root)
 }
 
-func (t *redBlackBST) size(n *nodeRedBlack) int {
+func (t *RedBlackBST[K, V]) size(n *RedBlackNode[K, V]) int {
 	if n == nil {
 		return 0
 	}
@@ -42,32 +61,51 @@ func (t *redBlackBST) size(n *nodeRedBlack) int {
 	return n.size
 }
 
-func (t *redBlackBST) IsEmpty() bool {
+// re-calculates the size and the sums of the node from its children
+func (t *RedBlackBST[K, V]) update(n *RedBlackNode[K, V]) {
+	n.size = t.size(n.left) + 1 + t.size(n.right)
+	if t.aggregate != nil {
+		volume, count := t.aggregate(n.Value)
+		lvolume, lcount := t.sums(n.left)
+		rvolume, rcount := t.sums(n.right)
+		n.volume = lvolume + volume + rvolume
+		n.count = lcount + count + rcount
+	}
+}
+
+func (t *RedBlackBST[K, V]) sums(n *RedBlackNode[K, V]) (float64, int) {
+	if n == nil {
+		return 0, 0
+	}
+	return n.volume, n.count
+}
+
+func (t *RedBlackBST[K, V]) IsEmpty() bool {
 	return t.size(t.root) == 0
 }
 
-func (t *redBlackBST) panicIfEmpty() {
+func (t *RedBlackBST[K, V]) panicIfEmpty() {
 	if t.IsEmpty() {
 		panic("Red Black BST is empty")
 	}
 }
 
-func (t *redBlackBST) Contains(key float64) bool {
+func (t *RedBlackBST[K, V]) Contains(key K) bool {
 	return t.get(t.root, key) != nil
 }
 
-func (t *redBlackBST) Get(key float64) *LimitOrder {
+func (t *RedBlackBST[K, V]) Get(key K) V {
 	t.panicIfEmpty()
 
 	x := t.get(t.root, key)
 	if x == nil {
-		panic(fmt.Sprintf("key %0.8f does not exist", key))
+		panic(fmt.Sprintf("key %v does not exist", key))
 	}
 
 	return x.Value
 }
 
-func (t *redBlackBST) get(n *nodeRedBlack, key float64) *nodeRedBlack {
+func (t *RedBlackBST[K, V]) get(n *RedBlackNode[K, V], key K) *RedBlackNode[K, V] {
 	if n == nil {
 		return nil
 	}
@@ -83,7 +121,7 @@ func (t *redBlackBST) get(n *nodeRedBlack, key float64) *nodeRedBlack {
 	}
 }
 
-func (t *redBlackBST) isRed(n *nodeRedBlack) bool {
+func (t *RedBlackBST[K, V]) isRed(n *RedBlackNode[K, V]) bool {
 	if n == nil {
 		// nil nodes are black by default
 		return false
@@ -92,7 +130,7 @@ func (t *redBlackBST) isRed(n *nodeRedBlack) bool {
 	return n.isRed
 }
 
-func (t *redBlackBST) flipColors(n *nodeRedBlack) {
+func (t *RedBlackBST[K, V]) flipColors(n *RedBlackNode[K, V]) {
 	if n == nil {
 		return
 	}
@@ -109,7 +147,7 @@ func (t *redBlackBST) flipColors(n *nodeRedBlack) {
 	n.isRed = !n.isRed
 }
 
-func (t *redBlackBST) rotateLeft(n *nodeRedBlack) *nodeRedBlack {
+func (t *RedBlackBST[K, V]) rotateLeft(n *RedBlackNode[K, V]) *RedBlackNode[K, V] {
 	x := n.right
 	n.right = x.left
 	x.left = n
@@ -117,14 +155,14 @@ func (t *redBlackBST) rotateLeft(n *nodeRedBlack) *nodeRedBlack {
 	x.isRed = n.isRed
 	n.isRed = true
 
-	// re-calculate sizes
-	n.size = t.size(n.left) + 1 + t.size(n.right)
-	x.size = t.size(x.left) + 1 + t.size(x.right)
+	// re-calculate sizes and sums
+	t.update(n)
+	t.update(x)
 
 	return x
 }
 
-func (t *redBlackBST) rotateRight(n *nodeRedBlack) *nodeRedBlack {
+func (t *RedBlackBST[K, V]) rotateRight(n *RedBlackNode[K, V]) *RedBlackNode[K, V] {
 	x := n.left
 	n.left = x.right
 	x.right = n
@@ -132,29 +170,43 @@ func (t *redBlackBST) rotateRight(n *nodeRedBlack) *nodeRedBlack {
 	x.isRed = n.isRed
 	n.isRed = true
 
-	// re-calculate sizes
-	n.size = t.size(n.left) + 1 + t.size(n.right)
-	x.size = t.size(x.left) + 1 + t.size(x.right)
+	// re-calculate sizes and sums
+	t.update(n)
+	t.update(x)
 
 	return x
 }
 
-func (t *redBlackBST) Put(key float64, value *LimitOrder) {
+func (t *RedBlackBST[K, V]) newNode() *RedBlackNode[K, V] {
+	if t.nodes == nil {
+		return &RedBlackNode[K, V]{}
+	}
+	return t.nodes.get()
+}
+
+// hands a detached node back to the free list
+func (t *RedBlackBST[K, V]) release(n *RedBlackNode[K, V]) {
+	if t.nodes != nil {
+		*n = RedBlackNode[K, V]{}
+		t.nodes.put(n)
+	}
+}
+
+func (t *RedBlackBST[K, V]) Put(key K, value V) {
 	t.root = t.put(t.root, key, value)
 
 	// keeping root black
 	t.root.isRed = false
 }
 
-func (t *redBlackBST) put(n *nodeRedBlack, key float64, value *LimitOrder) *nodeRedBlack {
+func (t *RedBlackBST[K, V]) put(n *RedBlackNode[K, V], key K, value V) *RedBlackNode[K, V] {
 	if n == nil {
 		// search miss, creating a new node with a red link as a part of 3- or 4-node
-		n := &nodeRedBlack{
-			Value: value,
-			Key: key,
-			size: 1,
-			isRed: true,
-		}
+		n := t.newNode()
+		n.Value = value
+		n.Key = key
+		n.isRed = true
+		t.update(n)
 
 		if t.minC == nil || key < t.minC.Key {
 			// new min
@@ -171,6 +223,7 @@ func (t *redBlackBST) put(n *nodeRedBlack, key float64, value *LimitOrder) *node
 	if n.Key == key {
 		// search hit, updating the value
 		n.Value = value
+		t.update(n)
 		return n
 	}
 
@@ -218,11 +271,92 @@ func (t *redBlackBST) put(n *nodeRedBlack, key float64, value *LimitOrder) *node
 	}
 
 	// re-calc size
-	n.size = t.size(n.left) + 1 + t.size(n.right)
+	t.update(n)
 	return n
 }
 
-func (t *redBlackBST) Height() int {
+// builds the empty tree from keys in strictly ascending order in O(N) instead
+// of N puts: 2-3 nodes are laid out level by level with the same black height,
+// 3-nodes (a black node with a red left child) take up the keys a perfect
+// tree of black nodes can not hold
+func (t *RedBlackBST[K, V]) Load(keys []K, values []V) {
+	if !t.IsEmpty() {
+		panic("loading into non-empty tree")
+	}
+	if len(keys) != len(values) {
+		panic(fmt.Sprintf("%d keys for %d values", len(keys), len(values)))
+	}
+	for i := 1; i < len(keys); i += 1 {
+		if keys[i - 1] >= keys[i] {
+			panic("keys should be in strictly ascending order")
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+
+	// the largest black height with all the keys fitting, 2^h-1 <= N <= 3^h-1
+	height := bits.Len(uint(len(keys) + 1)) - 1
+	var last *RedBlackNode[K, V]
+	t.root = t.load(keys, values, height, &last)
+	t.minC = t.min(t.root)
+	t.maxC = last
+}
+
+// subtree of the black height over the keys, last is the previous node in key order
+func (t *RedBlackBST[K, V]) load(keys []K, values []V, height int, last **RedBlackNode[K, V]) *RedBlackNode[K, V] {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	// a child of the black height below holds up to 3^(h-1)-1 keys
+	capacity := 1
+	for i := 1; i < height; i += 1 {
+		capacity *= 3
+	}
+	capacity -= 1
+
+	if len(keys) - 1 <= 2 * capacity {
+		// 2-node
+		mid := (len(keys) - 1) / 2
+		left := t.load(keys[:mid], values[:mid], height - 1, last)
+		n := t.loadNode(keys[mid], values[mid], last)
+		n.left = left
+		n.right = t.load(keys[mid + 1:], values[mid + 1:], height - 1, last)
+		t.update(n)
+		return n
+	}
+
+	// 3-node
+	a := (len(keys) - 2) / 3
+	b := a + 1 + (len(keys) - 2 - a) / 2
+	left := t.load(keys[:a], values[:a], height - 1, last)
+	red := t.loadNode(keys[a], values[a], last)
+	red.isRed = true
+	red.left = left
+	red.right = t.load(keys[a + 1:b], values[a + 1:b], height - 1, last)
+	t.update(red)
+	n := t.loadNode(keys[b], values[b], last)
+	n.left = red
+	n.right = t.load(keys[b + 1:], values[b + 1:], height - 1, last)
+	t.update(n)
+	return n
+}
+
+// black node linked after the last one in key order
+func (t *RedBlackBST[K, V]) loadNode(key K, value V, last **RedBlackNode[K, V]) *RedBlackNode[K, V] {
+	n := t.newNode()
+	n.Key = key
+	n.Value = value
+	n.Prev = *last
+	if *last != nil {
+		(*last).Next = n
+	}
+	*last = n
+	return n
+}
+
+func (t *RedBlackBST[K, V]) Height() int {
 	if t.IsEmpty() {
 		return 0
 	}
@@ -230,7 +364,7 @@ func (t *redBlackBST) Height() int {
 	return t.height(t.root)
 }
 
-func (t *redBlackBST) height(n *nodeRedBlack) int {
+func (t *RedBlackBST[K, V]) height(n *RedBlackNode[K, V]) int {
 	if n == nil {
 		return 0
 	}
@@ -246,12 +380,12 @@ func (t *redBlackBST) height(n *nodeRedBlack) int {
 	return height + 1
 }
 
-func (t *redBlackBST) IsRedBlack() bool {
+func (t *RedBlackBST[K, V]) IsRedBlack() bool {
 	balanced, _ := t.isBalanced(t.root)
 	return balanced && t.is23(t.root)
 }
 
-func (t *redBlackBST) isBalanced(n *nodeRedBlack) (bool, int) {
+func (t *RedBlackBST[K, V]) isBalanced(n *RedBlackNode[K, V]) (bool, int) {
 	if n == nil {
 		// nil node is black by default
 		return true, 1
@@ -272,7 +406,7 @@ func (t *redBlackBST) isBalanced(n *nodeRedBlack) (bool, int) {
 	return lb && rb && l == r, b
 }
 
-func (t *redBlackBST) is23(n *nodeRedBlack) bool {
+func (t *RedBlackBST[K, V]) is23(n *RedBlackNode[K, V]) bool {
 	if n == nil {
 		return true
 	}
@@ -290,22 +424,22 @@ func (t *redBlackBST) is23(n *nodeRedBlack) bool {
 	return t.is23(n.left) && t.is23(n.right)
 }
 
-func (t *redBlackBST) Min() float64 {
+func (t *RedBlackBST[K, V]) Min() K {
 	t.panicIfEmpty()
 	return t.minC.Key
 }
 
-func (t *redBlackBST) MinValue() *LimitOrder {
+func (t *RedBlackBST[K, V]) MinValue() V {
 	t.panicIfEmpty()
 	return t.minC.Value
 }
 
-func (t *redBlackBST) MinPointer() *nodeRedBlack {
+func (t *RedBlackBST[K, V]) MinPointer() *RedBlackNode[K, V] {
 	t.panicIfEmpty()
 	return t.minC
 }
 
-func (t *redBlackBST) min(n *nodeRedBlack) *nodeRedBlack {
+func (t *RedBlackBST[K, V]) min(n *RedBlackNode[K, V]) *RedBlackNode[K, V] {
 	if n.left == nil {
 		return n
 	}
@@ -313,22 +447,22 @@ func (t *redBlackBST) min(n *nodeRedBlack) *nodeRedBlack {
 	return t.min(n.left)
 }
 
-func (t *redBlackBST) Max() float64 {
+func (t *RedBlackBST[K, V]) Max() K {
 	t.panicIfEmpty()
 	return t.maxC.Key
 }
 
-func (t *redBlackBST) MaxValue() *LimitOrder {
+func (t *RedBlackBST[K, V]) MaxValue() V {
 	t.panicIfEmpty()
 	return t.maxC.Value
 }
 
-func (t *redBlackBST) MaxPointer() *nodeRedBlack {
+func (t *RedBlackBST[K, V]) MaxPointer() *RedBlackNode[K, V] {
 	t.panicIfEmpty()
 	return t.maxC
 }
 
-func (t *redBlackBST) max(n *nodeRedBlack) *nodeRedBlack {
+func (t *RedBlackBST[K, V]) max(n *RedBlackNode[K, V]) *RedBlackNode[K, V] {
 	if n.right == nil {
 		return n
 	}
@@ -336,18 +470,18 @@ func (t *redBlackBST) max(n *nodeRedBlack) *nodeRedBlack {
 	return t.max(n.right)
 }
 
-func (t *redBlackBST) Floor(key float64) float64 {
+func (t *RedBlackBST[K, V]) Floor(key K) K {
 	t.panicIfEmpty()
 
 	floor := t.floor(t.root, key)
 	if floor == nil {
-		panic(fmt.Sprintf("there are no keys <= %0.8f", key))
+		panic(fmt.Sprintf("there are no keys <= %v", key))
 	}
 
 	return floor.Key
 }
 
-func (t *redBlackBST) floor(n *nodeRedBlack, key float64) *nodeRedBlack {
+func (t *RedBlackBST[K, V]) floor(n *RedBlackNode[K, V], key K) *RedBlackNode[K, V] {
 	if n == nil {
 		// search miss
 		return nil
@@ -372,18 +506,18 @@ func (t *redBlackBST) floor(n *nodeRedBlack, key float64) *nodeRedBlack {
 	return n
 }
 
-func (t *redBlackBST) Ceiling(key float64) float64 {
+func (t *RedBlackBST[K, V]) Ceiling(key K) K {
 	t.panicIfEmpty()
 
 	ceiling := t.ceiling(t.root, key)
 	if ceiling == nil {
-		panic(fmt.Sprintf("there are no keys >= %0.8f", key))
+		panic(fmt.Sprintf("there are no keys >= %v", key))
 	}
 
 	return ceiling.Key
 }
 
-func (t *redBlackBST) ceiling(n *nodeRedBlack, key float64) *nodeRedBlack {
+func (t *RedBlackBST[K, V]) ceiling(n *RedBlackNode[K, V], key K) *RedBlackNode[K, V] {
 	if n == nil {
 		// search miss
 		return nil
@@ -408,7 +542,7 @@ func (t *redBlackBST) ceiling(n *nodeRedBlack, key float64) *nodeRedBlack {
 	return n
 }
 
-func (t *redBlackBST) Select(k int) float64 {
+func (t *RedBlackBST[K, V]) Select(k int) K {
 	if k < 0 || k >= t.Size() {
 		panic("index out of range")
 	}
@@ -416,7 +550,7 @@ func (t *redBlackBST) Select(k int) float64 {
 	return t.selectNode(t.root, k).Key
 }
 
-func (t *redBlackBST) selectNode(n *nodeRedBlack, k int) *nodeRedBlack {
+func (t *RedBlackBST[K, V]) selectNode(n *RedBlackNode[K, V], k int) *RedBlackNode[K, V] {
 	if t.size(n.left) == k {
 		return n
 	}
@@ -429,12 +563,12 @@ func (t *redBlackBST) selectNode(n *nodeRedBlack, k int) *nodeRedBlack {
 	return t.selectNode(n.right, k)
 }
 
-func (t *redBlackBST) Rank(key float64) int {
+func (t *RedBlackBST[K, V]) Rank(key K) int {
 	t.panicIfEmpty()
 	return t.rank(t.root, key)
 }
 
-func (t *redBlackBST) rank(n *nodeRedBlack, key float64) int {
+func (t *RedBlackBST[K, V]) rank(n *RedBlackNode[K, V], key K) int {
 	if n == nil {
 		return 0
 	}
@@ -450,7 +584,7 @@ func (t *redBlackBST) rank(n *nodeRedBlack, key float64) int {
 	return t.size(n.left) + 1 + t.rank(n.right, key)
 }
 
-func (t *redBlackBST) moveRedLeft(n *nodeRedBlack) *nodeRedBlack {
+func (t *RedBlackBST[K, V]) moveRedLeft(n *RedBlackNode[K, V]) *RedBlackNode[K, V] {
 	// assuming that n.left and n.left.left are black and n is red,
 	// make h.left or one of its children red
 	t.flipColors(n)
@@ -470,7 +604,7 @@ func (t *redBlackBST) moveRedLeft(n *nodeRedBlack) *nodeRedBlack {
 	return n
 }
 
-func (t *redBlackBST) DeleteMin() {
+func (t *RedBlackBST[K, V]) DeleteMin() {
 	t.panicIfEmpty()
 
 	if !t.isRed(t.root.left) && !t.isRed(t.root.right) {
@@ -483,7 +617,7 @@ func (t *redBlackBST) DeleteMin() {
 	}
 }
 
-func (t *redBlackBST) deleteMin(n *nodeRedBlack) *nodeRedBlack {
+func (t *RedBlackBST[K, V]) deleteMin(n *RedBlackNode[K, V]) *RedBlackNode[K, V] {
 	if n.left == nil {
 		// we've reached the least leave of the tree
 		next := n.Next
@@ -502,7 +636,9 @@ func (t *redBlackBST) deleteMin(n *nodeRedBlack) *nodeRedBlack {
 			t.minC = next
 		}
 
-		return n.right
+		right := n.right
+		t.release(n)
+		return right
 	}
 
 	// making current node a part of 3 or 4 node by moving red link to the left
@@ -523,11 +659,11 @@ func (t *redBlackBST) deleteMin(n *nodeRedBlack) *nodeRedBlack {
 		t.flipColors(n)
 	}
 
-	n.size = t.size(n.left) + 1 + t.size(n.right)
+	t.update(n)
 	return n
 }
 
-func (t *redBlackBST) moveRedRight(n *nodeRedBlack) *nodeRedBlack {
+func (t *RedBlackBST[K, V]) moveRedRight(n *RedBlackNode[K, V]) *RedBlackNode[K, V] {
 	// assuming n is red, n.right and n.right.left are black,
 	// make h.right or one of its children red
 	t.flipColors(n)
@@ -544,7 +680,7 @@ func (t *redBlackBST) moveRedRight(n *nodeRedBlack) *nodeRedBlack {
 	return n
 }
 
-func (t *redBlackBST) DeleteMax() {
+func (t *RedBlackBST[K, V]) DeleteMax() {
 	t.panicIfEmpty()
 
 	if !t.isRed(t.root.left) && !t.isRed(t.root.right) {
@@ -556,7 +692,7 @@ func (t *redBlackBST) DeleteMax() {
 	}
 }
 
-func (t *redBlackBST) deleteMax(n *nodeRedBlack) *nodeRedBlack {
+func (t *RedBlackBST[K, V]) deleteMax(n *RedBlackNode[K, V]) *RedBlackNode[K, V] {
 	if t.isRed(n.left) {
 		// making right red by rotating
 		n = t.rotateRight(n)
@@ -579,7 +715,9 @@ func (t *redBlackBST) deleteMax(n *nodeRedBlack) *nodeRedBlack {
 			t.maxC = prev
 		}
 
-		return n.left
+		left := n.left
+		t.release(n)
+		return left
 	}
 
 	// making right left on the way from top to bottom
@@ -600,11 +738,11 @@ func (t *redBlackBST) deleteMax(n *nodeRedBlack) *nodeRedBlack {
 		t.flipColors(n)
 	}
 
-	n.size = t.size(n.left) + 1 + t.size(n.right)
+	t.update(n)
 	return n
 }
 
-func (t *redBlackBST) Delete(key float64) {
+func (t *RedBlackBST[K, V]) Delete(key K) {
 	t.panicIfEmpty()
 
 	if !t.isRed(t.root.left) && !t.isRed(t.root.right) {
@@ -616,7 +754,7 @@ func (t *redBlackBST) Delete(key float64) {
 	}
 }
 
-func (t *redBlackBST) delete(n *nodeRedBlack, key float64) *nodeRedBlack {
+func (t *RedBlackBST[K, V]) delete(n *RedBlackNode[K, V], key K) *RedBlackNode[K, V] {
 	if n.Key > key {
 		if n.left == nil {
 			// search miss
@@ -657,6 +795,7 @@ func (t *redBlackBST) delete(n *nodeRedBlack, key float64) *nodeRedBlack {
 				t.minC = next
 			}
 
+			t.release(n)
 			return nil
 		}
 
@@ -674,6 +813,10 @@ func (t *redBlackBST) delete(n *nodeRedBlack, key float64) *nodeRedBlack {
 
 			// global min will be updated automatically if requied, 
 			// as we copy values from successor
+			if t.maxC == rightMin {
+				// the successor node is gone, its key lives in the current one now
+				t.maxC = n
+			}
 		} else {
 			if n.right == nil {
 				// search miss
@@ -695,11 +838,71 @@ func (t *redBlackBST) delete(n *nodeRedBlack, key float64) *nodeRedBlack {
 		t.flipColors(n)
 	}
 
-	n.size = t.size(n.left) + 1 + t.size(n.right)
+	t.update(n)
 	return n
 }
 
-func (t *redBlackBST) Keys(lo, hi float64) []float64 {
+// value of the least key > key, zero value if there is none
+func (t *RedBlackBST[K, V]) Next(key K) V {
+	var next *RedBlackNode[K, V]
+	for n := t.root; n != nil; {
+		if n.Key > key {
+			next = n
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	if next == nil {
+		var zero V
+		return zero
+	}
+	return next.Value
+}
+
+// value of the greatest key < key, zero value if there is none
+func (t *RedBlackBST[K, V]) Prev(key K) V {
+	var prev *RedBlackNode[K, V]
+	for n := t.root; n != nil; {
+		if n.Key < key {
+			prev = n
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	if prev == nil {
+		var zero V
+		return zero
+	}
+	return prev.Value
+}
+
+// walks the keys linked list from the ceiling of lo
+func (t *RedBlackBST[K, V]) Range(lo, hi K, fn func(value V) bool) {
+	for n := t.ceiling(t.root, lo); n != nil && n.Key <= hi; n = n.Next {
+		if !fn(n.Value) {
+			return
+		}
+	}
+}
+
+// walks the keys linked list from the min or the max
+func (t *RedBlackBST[K, V]) Walk(descending bool, fn func(value V) bool) {
+	n := t.minC
+	if descending {
+		n = t.maxC
+	}
+	for n != nil && fn(n.Value) {
+		if descending {
+			n = n.Prev
+		} else {
+			n = n.Next
+		}
+	}
+}
+
+func (t *RedBlackBST[K, V]) Keys(lo, hi K) []K {
 	if lo < t.Min() || hi > t.Max() {
 		panic("keys out of range")
 	}
@@ -707,7 +910,7 @@ func (t *redBlackBST) Keys(lo, hi float64) []float64 {
 	return t.keys(t.root, lo, hi)
 }
 
-func (t *redBlackBST) keys(n *nodeRedBlack, lo, hi float64) []float64 {
+func (t *RedBlackBST[K, V]) keys(n *RedBlackNode[K, V], lo, hi K) []K {
 	if n == nil {
 		return nil
 	}
@@ -721,7 +924,7 @@ func (t *redBlackBST) keys(n *nodeRedBlack, lo, hi float64) []float64 {
 	l := t.keys(n.left, lo, hi)
 	r := t.keys(n.right, lo, hi)
 	
-	keys := make([]float64, 0)
+	keys := make([]K, 0)
 	if l != nil {
 		keys = append(keys, l...)
 	}
@@ -733,13 +936,112 @@ func (t *redBlackBST) keys(n *nodeRedBlack, lo, hi float64) []float64 {
 	return keys
 }
 
-func (t *redBlackBST) Print() {
+// recomputes the sums on the path to the key after its value changed in place
+func (t *RedBlackBST[K, V]) Refresh(key K) {
+	t.refresh(t.root, key)
+}
+
+func (t *RedBlackBST[K, V]) refresh(n *RedBlackNode[K, V], key K) {
+	if n == nil {
+		return
+	}
+
+	if n.Key > key {
+		t.refresh(n.left, key)
+	} else if n.Key < key {
+		t.refresh(n.right, key)
+	}
+	t.update(n)
+}
+
+// total volume and count of the keys between lo and hi, O(lgN)
+func (t *RedBlackBST[K, V]) RangeVolume(lo, hi K) (float64, int) {
+	if t.aggregate == nil {
+		panic("tree has no aggregate function")
+	}
+
+	// descending to the node splitting the range
+	n := t.root
+	for n != nil && (n.Key < lo || n.Key > hi) {
+		if n.Key < lo {
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	if n == nil {
+		return 0, 0
+	}
+
+	volume, count := t.aggregate(n.Value)
+
+	// keys >= lo in the left sub-tree
+	for x := n.left; x != nil; {
+		if x.Key >= lo {
+			v, c := t.aggregate(x.Value)
+			rv, rc := t.sums(x.right)
+			volume, count = volume + v + rv, count + c + rc
+			x = x.left
+		} else {
+			x = x.right
+		}
+	}
+
+	// keys <= hi in the right sub-tree
+	for x := n.right; x != nil; {
+		if x.Key <= hi {
+			v, c := t.aggregate(x.Value)
+			lv, lc := t.sums(x.left)
+			volume, count = volume + v + lv, count + c + lc
+			x = x.right
+		} else {
+			x = x.left
+		}
+	}
+
+	return volume, count
+}
+
+// least key with the volume of all keys up to it reaching volume, or the greatest
+// key with the volume of all keys from it reaching volume if descending,
+// false if the whole tree has less volume, O(lgN)
+func (t *RedBlackBST[K, V]) VolumeKey(volume float64, descending bool) (K, bool) {
+	if t.aggregate == nil {
+		panic("tree has no aggregate function")
+	}
+
+	for n := t.root; n != nil; {
+		near, far := n.left, n.right
+		if descending {
+			near, far = far, near
+		}
+
+		nv, _ := t.sums(near)
+		if near != nil && volume <= nv {
+			n = near
+			continue
+		}
+		volume -= nv
+
+		v, _ := t.aggregate(n.Value)
+		if volume <= v {
+			return n.Key, true
+		}
+		volume -= v
+		n = far
+	}
+
+	var zero K
+	return zero, false
+}
+
+func (t *RedBlackBST[K, V]) Print() {
 	fmt.Println()
 	t.print(t.root)
 	fmt.Println()
 }
 
-func (t *redBlackBST) print(n *nodeRedBlack) {
+func (t *RedBlackBST[K, V]) print(n *RedBlackNode[K, V]) {
 	if n == nil {
 		return
 	}
@@ -747,7 +1049,7 @@ func (t *redBlackBST) print(n *nodeRedBlack) {
 	if n.isRed {
 		fmt.Printf("*")
 	}
-	fmt.Printf("%0.8f ", n.Key)
+	fmt.Printf("%v ", n.Key)
 
 	t.print(n.left)
 	t.print(n.right)
@@ -0,0 +1,171 @@
+package hftorderbook
+
+import (
+	"testing"
+)
+
+// Put of the red-black tree specialized for float64 prices and *LimitOrder
+// values as it was before the tree became generic, kept as the baseline of
+// the generic tree benchmarks
+
+type float64NodeRedBlack struct {
+	Key float64
+	Value *LimitOrder
+	Next *float64NodeRedBlack
+	Prev *float64NodeRedBlack
+
+	left *float64NodeRedBlack
+	right *float64NodeRedBlack
+	size int
+	isRed bool
+}
+
+type float64RedBlackBST struct {
+	root *float64NodeRedBlack
+	minC *float64NodeRedBlack
+	maxC *float64NodeRedBlack
+}
+
+func (t *float64RedBlackBST) size(n *float64NodeRedBlack) int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (t *float64RedBlackBST) isRed(n *float64NodeRedBlack) bool {
+	if n == nil {
+		return false
+	}
+	return n.isRed
+}
+
+func (t *float64RedBlackBST) flipColors(n *float64NodeRedBlack) {
+	if n == nil {
+		return
+	}
+	if n.left != nil {
+		n.left.isRed = !n.left.isRed
+	}
+	if n.right != nil {
+		n.right.isRed = !n.right.isRed
+	}
+	n.isRed = !n.isRed
+}
+
+func (t *float64RedBlackBST) rotateLeft(n *float64NodeRedBlack) *float64NodeRedBlack {
+	x := n.right
+	n.right = x.left
+	x.left = n
+
+	x.isRed = n.isRed
+	n.isRed = true
+
+	n.size = t.size(n.left) + 1 + t.size(n.right)
+	x.size = t.size(x.left) + 1 + t.size(x.right)
+	return x
+}
+
+func (t *float64RedBlackBST) rotateRight(n *float64NodeRedBlack) *float64NodeRedBlack {
+	x := n.left
+	n.left = x.right
+	x.right = n
+
+	x.isRed = n.isRed
+	n.isRed = true
+
+	n.size = t.size(n.left) + 1 + t.size(n.right)
+	x.size = t.size(x.left) + 1 + t.size(x.right)
+	return x
+}
+
+func (t *float64RedBlackBST) Put(key float64, value *LimitOrder) {
+	t.root = t.put(t.root, key, value)
+	t.root.isRed = false
+}
+
+func (t *float64RedBlackBST) put(n *float64NodeRedBlack, key float64, value *LimitOrder) *float64NodeRedBlack {
+	if n == nil {
+		n := &float64NodeRedBlack{Key: key, Value: value, size: 1, isRed: true}
+		if t.minC == nil || key < t.minC.Key {
+			t.minC = n
+		}
+		if t.maxC == nil || key > t.maxC.Key {
+			t.maxC = n
+		}
+		return n
+	}
+
+	if n.Key == key {
+		n.Value = value
+		return n
+	}
+
+	if n.Key > key {
+		left := n.left
+		n.left = t.put(n.left, key, value)
+		if left == nil {
+			prev := n.Prev
+			if prev != nil {
+				prev.Next = n.left
+			}
+			n.left.Prev = prev
+			n.left.Next = n
+			n.Prev = n.left
+		}
+	} else {
+		right := n.right
+		n.right = t.put(n.right, key, value)
+		if right == nil {
+			next := n.Next
+			if next != nil {
+				next.Prev = n.right
+			}
+			n.right.Next = next
+			n.right.Prev = n
+			n.Next = n.right
+		}
+	}
+
+	if t.isRed(n.right) && !t.isRed(n.left) {
+		n = t.rotateLeft(n)
+	}
+	if t.isRed(n.left) && t.isRed(n.left.left) {
+		n = t.rotateRight(n)
+	}
+	if t.isRed(n.left) && t.isRed(n.right) {
+		t.flipColors(n)
+	}
+
+	n.size = t.size(n.left) + 1 + t.size(n.right)
+	return n
+}
+
+func TestFloat64RedBlackBaseline(t *testing.T) {
+	st := float64RedBlackBST{}
+	for _, k := range []float64{5, 1, 9, 3, 7} {
+		st.Put(k, nil)
+	}
+	keys := make([]float64, 0)
+	for n := st.minC; n != nil; n = n.Next {
+		keys = append(keys, n.Key)
+	}
+	if len(keys) != 5 || keys[0] != 1 || keys[4] != 9 || st.maxC.Key != 9 || st.root.size != 5 {
+		t.Errorf("baseline tree should link the keys in order, got %v", keys)
+	}
+}
+
+func BenchmarkRedBlackBaseline5kLevelsRandomInsertWithCaching(b *testing.B) {
+	st := float64RedBlackBST{}
+	benchmarkRedBlackLimitedRandomInsertWithCaching(5000, st.Put, b)
+}
+
+func BenchmarkRedBlackBaseline10kLevelsRandomInsertWithCaching(b *testing.B) {
+	st := float64RedBlackBST{}
+	benchmarkRedBlackLimitedRandomInsertWithCaching(10000, st.Put, b)
+}
+
+func BenchmarkRedBlackBaseline20kLevelsRandomInsertWithCaching(b *testing.B) {
+	st := float64RedBlackBST{}
+	benchmarkRedBlackLimitedRandomInsertWithCaching(20000, st.Put, b)
+}
@@ -3,6 +3,8 @@ package hftorderbook
 import (
 	"testing"
 	"math/rand"
+	"cmp"
+	"fmt"
 	//"fmt"
 )
 
@@ -332,9 +334,29 @@ func TestRedBlackPutDeleteLinkedListOrder(t *testing.T) {
 	}
 }
 
-func benchmarkRedBlackLimitedRandomInsertWithCaching(n int, b *testing.B) {
+func TestRedBlackDeleteKeepsMaxPointer(t *testing.T) {
 	st := NewRedBlackBST()
+	n := 1000
+	for i := 0; i < n; i += 1 {
+		st.Put(rand.Float64(), nil)
+	}
+
+	for st.Size() > 1 {
+		st.Delete(st.Select(rand.Intn(st.Size())))
+
+		// walking down from the cached max should visit every key
+		count := 0
+		for p := st.MaxPointer(); p != nil; p = p.Prev {
+			count += 1
+		}
+		if count != st.Size() || st.MaxPointer().Key != st.Max() {
+			t.Errorf("max pointer is detached from the keys list")
+			break
+		}
+	}
+}
 
+func benchmarkRedBlackLimitedRandomInsertWithCaching(n int, put func(key float64, value *LimitOrder), b *testing.B) {
 	// maximum number of levels in average is 10k
 	limitslist := make([]float64, n)
 	for i := range limitslist {
@@ -377,19 +399,226 @@ func benchmarkRedBlackLimitedRandomInsertWithCaching(n int, b *testing.B) {
 			limitscache[price] = &l
 			
 			// inserting into tree
-			st.Put(l.Price, &l)
+			put(l.Price, &l)
 		}
 	}
 }
 
 func BenchmarkRedBlack5kLevelsRandomInsertWithCaching(b *testing.B) {
-	benchmarkRedBlackLimitedRandomInsertWithCaching(5000, b)
+	st := NewRedBlackBST()
+	benchmarkRedBlackLimitedRandomInsertWithCaching(5000, st.Put, b)
 }
 
 func BenchmarkRedBlack10kLevelsRandomInsertWithCaching(b *testing.B) {
-	benchmarkRedBlackLimitedRandomInsertWithCaching(10000, b)
+	st := NewRedBlackBST()
+	benchmarkRedBlackLimitedRandomInsertWithCaching(10000, st.Put, b)
 }
 
 func BenchmarkRedBlack20kLevelsRandomInsertWithCaching(b *testing.B) {
-	benchmarkRedBlackLimitedRandomInsertWithCaching(20000, b)
+	st := NewRedBlackBST()
+	benchmarkRedBlackLimitedRandomInsertWithCaching(20000, st.Put, b)
+}
+
+func TestRedBlackGenericKeys(t *testing.T) {
+	// stop orders by trigger price in ticks
+	st := NewRedBlackBSTOf[int64, string]()
+	for i, k := range []int64{105, 99, 101, 120} {
+		st.Put(k, fmt.Sprint("stop", i))
+	}
+	st.Delete(99)
+
+	if st.Min() != 101 || st.Max() != 120 || st.Get(105) != "stop0" || st.Next(105) != "stop3" || st.Prev(101) != "" {
+		t.Errorf("invalid generic tree")
+	}
+	if !st.IsRedBlack() || st.Rank(120) != 2 || st.Floor(119) != 105 {
+		t.Errorf("invalid generic tree order")
+	}
+}
+
+// put and delete of a new max key in a 10k keys tree, float64 and int64 keys
+// should cost the same as the tree specialized for float64 prices
+func benchmarkRedBlackPutDelete[K cmp.Ordered](key func(x float64) K, b *testing.B) {
+	st := NewRedBlackBSTOf[K, *LimitOrder]()
+	for i := 0; i < 10000; i += 1 {
+		st.Put(key(rand.Float64()), nil)
+	}
+	keys := make([]K, 1024)
+	for i := range keys {
+		keys[i] = key(rand.Float64() + 1)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		k := keys[i & 1023]
+		st.Put(k, nil)
+		st.Delete(k)
+	}
+}
+
+func BenchmarkRedBlackPutDelete(b *testing.B) {
+	benchmarkRedBlackPutDelete(func(x float64) float64 { return x }, b)
+}
+
+func BenchmarkRedBlackInt64PutDelete(b *testing.B) {
+	benchmarkRedBlackPutDelete(func(x float64) int64 { return int64(x * 1e8) }, b)
+}
+
+// checks the subtree sums of every node against its children
+func checkRedBlackSums(t *testing.T, st *RedBlackBST[int, int], n *RedBlackNode[int, int]) {
+	if n == nil {
+		return
+	}
+	lv, lc := st.sums(n.left)
+	rv, rc := st.sums(n.right)
+	if n.volume != lv + float64(n.Value) + rv || n.count != lc + 1 + rc {
+		t.Fatalf("invalid sums at key %d", n.Key)
+	}
+	checkRedBlackSums(t, st, n.left)
+	checkRedBlackSums(t, st, n.right)
+}
+
+func TestRedBlackAggregates(t *testing.T) {
+	st := NewRedBlackBSTOf[int, int]()
+	st.aggregate = func(v int) (float64, int) {
+		return float64(v), 1
+	}
+
+	values := make(map[int]int)
+	for i := 0; i < 5000; i += 1 {
+		k := rand.Intn(1000)
+		if rand.Intn(3) == 0 && len(values) > 0 {
+			k := st.Select(rand.Intn(st.Size()))
+			st.Delete(k)
+			delete(values, k)
+			continue
+		}
+		v := 1 + rand.Intn(10)
+		st.Put(k, v)
+		values[k] = v
+	}
+	checkRedBlackSums(t, &st, st.root)
+
+	for i := 0; i < 100; i += 1 {
+		lo := rand.Intn(1000)
+		hi := lo + rand.Intn(200)
+		volume, count := 0.0, 0
+		for k, v := range values {
+			if k >= lo && k <= hi {
+				volume += float64(v)
+				count += 1
+			}
+		}
+		if v, c := st.RangeVolume(lo, hi); v != volume || c != count {
+			t.Errorf("range [%d, %d]: expected %0.0f in %d keys, got %0.0f in %d", lo, hi, volume, count, v, c)
+		}
+	}
+
+	// walking the keys both ways to find where the cumulative volume is reached
+	keys := st.Keys(st.Min(), st.Max())
+	total, _ := st.sums(st.root)
+	for i := 0; i < 100; i += 1 {
+		target := float64(1 + rand.Intn(int(total)))
+		for _, descending := range []bool{false, true} {
+			cumulative := 0.0
+			expected := 0
+			for j := range keys {
+				k := keys[j]
+				if descending {
+					k = keys[len(keys) - 1 - j]
+				}
+				cumulative += float64(values[k])
+				if cumulative >= target {
+					expected = k
+					break
+				}
+			}
+			if k, ok := st.VolumeKey(target, descending); !ok || k != expected {
+				t.Errorf("volume %0.0f (descending %v): expected key %d, got %d", target, descending, expected, k)
+			}
+		}
+	}
+	if _, ok := st.VolumeKey(total + 1, false); ok {
+		t.Errorf("volume above the total should not be reached")
+	}
+}
+
+func TestRedBlackLoad(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 3, 4, 5, 7, 8, 26, 27, 100, 1000, 20000} {
+		st := NewRedBlackBSTOf[int, int]()
+		st.aggregate = func(v int) (float64, int) {
+			return float64(v), 1
+		}
+
+		keys := make([]int, n)
+		values := make([]int, n)
+		for i := range keys {
+			keys[i] = 2 * i
+			values[i] = 1 + rand.Intn(10)
+		}
+		st.Load(keys, values)
+
+		if st.Size() != n || !st.IsRedBlack() {
+			t.Fatalf("%d keys: expected a red-black tree of size %d, got %d", n, n, st.Size())
+		}
+		checkRedBlackSums(t, &st, st.root)
+		if n == 0 {
+			continue
+		}
+		if st.Min() != 0 || st.Max() != 2 * (n - 1) {
+			t.Fatalf("%d keys: invalid min/max %d, %d", n, st.Min(), st.Max())
+		}
+
+		// linked in key order both ways
+		i := 0
+		var prev *RedBlackNode[int, int]
+		for p := st.MinPointer(); p != nil; p = p.Next {
+			if p.Key != keys[i] || p.Value != values[i] || p.Prev != prev || st.Select(i) != p.Key {
+				t.Fatalf("%d keys: invalid node at %d", n, i)
+			}
+			prev = p
+			i += 1
+		}
+		if i != n || prev != st.MaxPointer() {
+			t.Fatalf("%d keys: linked %d nodes", n, i)
+		}
+
+		// the loaded tree keeps balancing on puts and deletes
+		for j := 0; j < 100; j += 1 {
+			st.Put(2 * rand.Intn(n) + 1, 1)
+			if k := 2 * rand.Intn(n); st.Contains(k) {
+				st.Delete(k)
+			}
+		}
+		if !st.IsRedBlack() {
+			t.Fatalf("%d keys: not a red-black tree after puts and deletes", n)
+		}
+		checkRedBlackSums(t, &st, st.root)
+	}
+}
+
+func benchmarkRedBlack20kLevels(load bool, b *testing.B) {
+	keys := make([]float64, 20000)
+	values := make([]*LimitOrder, len(keys))
+	for i := range keys {
+		keys[i] = float64(i) / 100
+	}
+
+	for i := 0; i < b.N; i += 1 {
+		st := NewRedBlackBST()
+		if load {
+			st.Load(keys, values)
+			continue
+		}
+		for j, k := range keys {
+			st.Put(k, values[j])
+		}
+	}
+}
+
+func BenchmarkRedBlack20kLevelsLoad(b *testing.B) {
+	benchmarkRedBlack20kLevels(true, b)
+}
+
+func BenchmarkRedBlack20kLevelsPut(b *testing.B) {
+	benchmarkRedBlack20kLevels(false, b)
 }
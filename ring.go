@@ -0,0 +1,95 @@
+package hftorderbook
+
+import (
+	"sync/atomic"
+)
+
+// keeps hot atomic counters on separate cache lines
+type cacheLinePad [64]byte
+
+type ringCell[T any] struct {
+	seq atomic.Uint64
+	value T
+}
+
+// Bounded lock-free multi-producer multi-consumer queue over a preallocated
+// ring of cells, every cell carries a sequence number telling whether it is
+// ready to be written or read at the current lap
+type ring[T any] struct {
+	_ cacheLinePad
+	enqueue atomic.Uint64
+	_ cacheLinePad
+	dequeue atomic.Uint64
+	_ cacheLinePad
+	mask uint64
+	cells []ringCell[T]
+}
+
+// capacity is rounded up to the power of 2
+func newRing[T any](capacity int) *ring[T] {
+	size := 2
+	for size < capacity {
+		size <<= 1
+	}
+
+	r := &ring[T]{
+		mask: uint64(size - 1),
+		cells: make([]ringCell[T], size),
+	}
+	for i := range r.cells {
+		r.cells[i].seq.Store(uint64(i))
+	}
+	return r
+}
+
+func (r *ring[T]) capacity() int {
+	return len(r.cells)
+}
+
+// appends the value, false if the ring is full
+func (r *ring[T]) offer(v T) bool {
+	pos := r.enqueue.Load()
+	for {
+		cell := &r.cells[pos & r.mask]
+		diff := int64(cell.seq.Load()) - int64(pos)
+		if diff == 0 {
+			// the cell is free at this lap, claiming it
+			if r.enqueue.CompareAndSwap(pos, pos + 1) {
+				cell.value = v
+				cell.seq.Store(pos + 1)
+				return true
+			}
+			pos = r.enqueue.Load()
+		} else if diff < 0 {
+			// the cell has not been read at the previous lap yet
+			return false
+		} else {
+			// another producer took the cell
+			pos = r.enqueue.Load()
+		}
+	}
+}
+
+// removes the oldest value, false if the ring is empty
+func (r *ring[T]) poll() (T, bool) {
+	var zero T
+	pos := r.dequeue.Load()
+	for {
+		cell := &r.cells[pos & r.mask]
+		diff := int64(cell.seq.Load()) - int64(pos + 1)
+		if diff == 0 {
+			if r.dequeue.CompareAndSwap(pos, pos + 1) {
+				v := cell.value
+				cell.value = zero
+				// the cell is free for the next lap
+				cell.seq.Store(pos + r.mask + 1)
+				return v, true
+			}
+			pos = r.dequeue.Load()
+		} else if diff < 0 {
+			return zero, false
+		} else {
+			pos = r.dequeue.Load()
+		}
+	}
+}
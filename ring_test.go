@@ -0,0 +1,99 @@
+package hftorderbook
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestRingFIFO(t *testing.T) {
+	r := newRing[int](5)
+	if r.capacity() != 8 {
+		t.Errorf("capacity should be rounded up to 8, got %d", r.capacity())
+	}
+	if _, ok := r.poll(); ok {
+		t.Errorf("ring should be empty")
+	}
+
+	// a few laps over the ring
+	for lap := 0; lap < 3; lap += 1 {
+		for i := 0; i < 8; i += 1 {
+			if !r.offer(i) {
+				t.Fatalf("offer %d should succeed", i)
+			}
+		}
+		if r.offer(8) {
+			t.Errorf("ring should be full")
+		}
+		for i := 0; i < 8; i += 1 {
+			if v, ok := r.poll(); !ok || v != i {
+				t.Fatalf("expected %d, got %d", i, v)
+			}
+		}
+	}
+}
+
+func TestRingConcurrent(t *testing.T) {
+	r := newRing[int](64)
+	producers, consumers, n := 4, 4, 10000
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p += 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 1; i <= n; i += 1 {
+				for !r.offer(i) {
+					runtime.Gosched()
+				}
+			}
+		}()
+	}
+
+	sums := make([]int, consumers)
+	var cwg sync.WaitGroup
+	var received sync.WaitGroup
+	received.Add(producers * n)
+	done := make(chan struct{})
+	for c := 0; c < consumers; c += 1 {
+		cwg.Add(1)
+		go func(c int) {
+			defer cwg.Done()
+			for {
+				if v, ok := r.poll(); ok {
+					sums[c] += v
+					received.Done()
+					continue
+				}
+				select {
+				case <-done:
+					return
+				default:
+					runtime.Gosched()
+				}
+			}
+		}(c)
+	}
+
+	wg.Wait()
+	received.Wait()
+	close(done)
+	cwg.Wait()
+
+	total := 0
+	for _, s := range sums {
+		total += s
+	}
+	if expected := producers * n * (n + 1) / 2; total != expected {
+		t.Errorf("expected sum %d, got %d", expected, total)
+	}
+}
+
+func BenchmarkRingOfferPoll(b *testing.B) {
+	r := newRing[Command](1024)
+	c := Command{Type: CommandAdd}
+	for i := 0; i < b.N; i += 1 {
+		r.offer(c)
+		r.poll()
+	}
+}
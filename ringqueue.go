@@ -0,0 +1,137 @@
+package hftorderbook
+
+// Orders queue in a ring buffer. Orders remember their slot, cancel leaves
+// a tombstone in O(1), tombstones are skipped at the ends of the queue and
+// compacted away when they outnumber the live orders or fill the ring.
+type ringQueue struct {
+	orders []*Order // power of two length
+	head int // slot of the oldest order
+	tail int // slot after the newest order
+	size int
+	tombstones int // deleted orders between head and tail
+}
+
+const ringQueueMinCapacity = 8
+
+func NewRingQueue() ringQueue {
+	return ringQueue{
+		orders: make([]*Order, ringQueueMinCapacity),
+	}
+}
+
+func (this *ringQueue) Size() int {
+	return this.size
+}
+
+func (this *ringQueue) IsEmpty() bool {
+	return this.size == 0
+}
+
+func (this *ringQueue) at(slot int) *Order {
+	return this.orders[slot & (len(this.orders) - 1)]
+}
+
+func (this *ringQueue) Enqueue(o *Order) {
+	if this.tail - this.head == len(this.orders) {
+		// growing unless compaction frees a quarter of the ring, so a full
+		// ring is not compacted again after a few cancels
+		if this.tombstones >= len(this.orders) / 4 {
+			this.compact(len(this.orders))
+		} else {
+			this.compact(2 * len(this.orders))
+		}
+	}
+
+	o.slot = this.tail
+	this.orders[this.tail & (len(this.orders) - 1)] = o
+	this.tail++
+	this.size++
+}
+
+func (this *ringQueue) Dequeue() *Order {
+	if this.size == 0 {
+		return nil
+	}
+
+	// head is always a live order
+	o := this.at(this.head)
+	this.orders[this.head & (len(this.orders) - 1)] = nil
+	this.head++
+	this.size--
+	this.trim()
+	return o
+}
+
+func (this *ringQueue) Delete(o *Order) {
+	this.orders[o.slot & (len(this.orders) - 1)] = nil
+	this.size--
+	this.tombstones++
+	this.trim()
+
+	if this.tombstones > this.size && this.tombstones > ringQueueMinCapacity {
+		this.compact(len(this.orders))
+	}
+}
+
+// drops the tombstones at both ends of the queue
+func (this *ringQueue) trim() {
+	for this.head < this.tail && this.at(this.head) == nil {
+		this.head++
+		this.tombstones--
+	}
+	for this.head < this.tail && this.at(this.tail - 1) == nil {
+		this.tail--
+		this.tombstones--
+	}
+}
+
+// packs the live orders after the head into a ring of the given capacity,
+// in place when the capacity does not change
+func (this *ringQueue) compact(capacity int) {
+	orders, base := this.orders, this.head
+	if capacity != len(orders) {
+		orders, base = make([]*Order, capacity), 0
+	}
+
+	// in place the orders only move back towards the head, behind the reading slot
+	n := base
+	for slot := this.head; slot < this.tail; slot++ {
+		o := this.at(slot)
+		if o == nil {
+			continue
+		}
+		this.orders[slot & (len(this.orders) - 1)] = nil
+		o.slot = n
+		orders[n & (len(orders) - 1)] = o
+		n++
+	}
+
+	this.orders = orders
+	this.head = base
+	this.tail = n
+	this.tombstones = 0
+}
+
+func (this *ringQueue) Clear() {
+	clear(this.orders)
+	this.head = 0
+	this.tail = 0
+	this.size = 0
+	this.tombstones = 0
+}
+
+func (this *ringQueue) Front() *Order {
+	if this.size == 0 {
+		return nil
+	}
+	return this.at(this.head)
+}
+
+func (this *ringQueue) Next(o *Order) *Order {
+	for slot := o.slot + 1; slot < this.tail; slot++ {
+		if next := this.at(slot); next != nil {
+			return next
+		}
+	}
+	return nil
+}
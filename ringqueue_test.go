@@ -0,0 +1,206 @@
+package hftorderbook
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestRingQueueEmpty(t *testing.T) {
+	q := NewRingQueue()
+	if !q.IsEmpty() || q.Size() != 0 || q.Front() != nil || q.Dequeue() != nil {
+		t.Errorf("a queue should be initialized as empty")
+	}
+}
+
+// random enqueues, dequeues and deletes against a reference slice
+func TestRingQueueRandom(t *testing.T) {
+	q := NewRingQueue()
+	expected := make([]*Order, 0)
+	for i := 0; i < 20000; i += 1 {
+		switch r := rand.Intn(10); {
+		case r < 2 && len(expected) > 0:
+			o := q.Dequeue()
+			if o != expected[0] {
+				t.Fatalf("expected order %d, got %d", expected[0].Id, o.Id)
+			}
+			expected = expected[1:]
+		case r < 6 && len(expected) > 0:
+			j := rand.Intn(len(expected))
+			q.Delete(expected[j])
+			expected = append(expected[:j], expected[j+1:]...)
+		default:
+			o := &Order{Id: i}
+			q.Enqueue(o)
+			expected = append(expected, o)
+		}
+
+		if q.Size() != len(expected) {
+			t.Fatalf("expected size %d, got %d", len(expected), q.Size())
+		}
+		if i % 100 == 0 {
+			j := 0
+			for o := q.Front(); o != nil; o = q.Next(o) {
+				if o != expected[j] {
+					t.Fatalf("expected order %d at %d, got %d", expected[j].Id, j, o.Id)
+				}
+				j += 1
+			}
+			if j != len(expected) {
+				t.Fatalf("expected %d orders, walked %d", len(expected), j)
+			}
+		}
+	}
+}
+
+func TestRingQueueCompaction(t *testing.T) {
+	q := NewRingQueue()
+	orders := make([]*Order, 1000)
+	for i := range orders {
+		orders[i] = &Order{Id: i}
+		q.Enqueue(orders[i])
+	}
+	capacity := len(q.orders)
+
+	// cancelling all but the last order of every ten, the ring keeps its capacity
+	for i, o := range orders {
+		if i % 10 != 9 {
+			q.Delete(o)
+		}
+	}
+	for i := 0; i < 900; i += 1 {
+		q.Enqueue(&Order{Id: 1000 + i})
+	}
+	if len(q.orders) != capacity || q.tombstones > q.size {
+		t.Errorf("tombstones should be compacted instead of growing the ring")
+	}
+	if q.Front() != orders[9] || q.Next(orders[9]) != orders[19] {
+		t.Errorf("compaction should keep the queue order")
+	}
+
+	q.Clear()
+	if !q.IsEmpty() || q.Front() != nil {
+		t.Errorf("a queue should be empty after clear")
+	}
+}
+
+func TestOrderbookRingQueue(t *testing.T) {
+	seed := rand.Int63()
+	var expected []byte
+	for _, newQueue := range []func() Queue{nil, func() Queue {
+		q := NewRingQueue()
+		return &q
+	}} {
+		book := NewOrderbookWithConfig(OrderbookConfig{NewQueue: newQueue})
+		r := rand.New(rand.NewSource(seed))
+
+		orders := make([]*Order, 0)
+		for i := 0; i < 5000; i += 1 {
+			if r.Intn(3) == 0 && len(orders) > 0 {
+				j := r.Intn(len(orders))
+				book.Cancel(orders[j])
+				orders[j] = orders[len(orders) - 1]
+				orders = orders[:len(orders) - 1]
+				continue
+			}
+			price := float64(r.Intn(20)) / 10
+			o := book.NewOrder(i, float64(1 + r.Intn(3)), price < 1)
+			book.Add(price, o)
+			orders = append(orders, o)
+		}
+
+		data, _ := book.MarshalBinary()
+		if expected == nil {
+			expected = data
+		} else if !bytes.Equal(data, expected) {
+			t.Errorf("book with ring queues differs from the linked list one")
+		}
+	}
+}
+
+func newBenchmarkQueue(ring bool) Queue {
+	if ring {
+		q := NewRingQueue()
+		return &q
+	}
+	q := NewOrdersQueue()
+	return &q
+}
+
+// steady queue of n orders, the oldest one is filled for every new one
+func benchmarkQueueEnqueueDequeue(ring bool, n int, b *testing.B) {
+	q := newBenchmarkQueue(ring)
+	orders := make([]Order, n + 1)
+	for i := 0; i < n; i += 1 {
+		q.Enqueue(&orders[i])
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		o := q.Dequeue()
+		q.Enqueue(o)
+	}
+}
+
+// steady queue of n orders, a random order is cancelled for every new one
+func benchmarkQueueCancel(ring bool, n int, b *testing.B) {
+	q := newBenchmarkQueue(ring)
+	orders := make([]*Order, n)
+	for i := range orders {
+		orders[i] = &Order{Id: i}
+		q.Enqueue(orders[i])
+	}
+	cancels := make([]int, 1024)
+	for i := range cancels {
+		cancels[i] = rand.Intn(n)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		o := orders[cancels[i % len(cancels)]]
+		q.Delete(o)
+		q.Enqueue(o)
+	}
+}
+
+// walks all n orders of the queue, as matching or snapshots do,
+// orders are queued out of their memory order like in a long running book
+func benchmarkQueueWalk(ring bool, n int, b *testing.B) {
+	q := newBenchmarkQueue(ring)
+	orders := make([]Order, n)
+	for _, i := range rand.Perm(n) {
+		q.Enqueue(&orders[i])
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		volume := 0.0
+		for o := q.Front(); o != nil; o = q.Next(o) {
+			volume += o.Volume
+		}
+	}
+}
+
+func BenchmarkOrdersQueue1kEnqueueDequeue(b *testing.B) {
+	benchmarkQueueEnqueueDequeue(false, 1000, b)
+}
+
+func BenchmarkRingQueue1kEnqueueDequeue(b *testing.B) {
+	benchmarkQueueEnqueueDequeue(true, 1000, b)
+}
+
+func BenchmarkOrdersQueue1kCancel(b *testing.B) {
+	benchmarkQueueCancel(false, 1000, b)
+}
+
+func BenchmarkRingQueue1kCancel(b *testing.B) {
+	benchmarkQueueCancel(true, 1000, b)
+}
+
+func BenchmarkOrdersQueue1kWalk(b *testing.B) {
+	benchmarkQueueWalk(false, 1000, b)
+}
+
+func BenchmarkRingQueue1kWalk(b *testing.B) {
+	benchmarkQueueWalk(true, 1000, b)
+}
@@ -0,0 +1,209 @@
+package hftorderbook
+
+import (
+	"errors"
+	"sync"
+)
+
+var ErrEngineStopped = errors.New("engine is stopped")
+
+type SymbolCommand struct {
+	Symbol string
+	Command
+}
+
+type SymbolResult struct {
+	Symbol string
+	Result
+}
+
+// Books of many symbols sharded across a fixed set of worker goroutines,
+// a symbol is pinned to a worker by its hash, so every book stays
+// single-threaded and commands of a symbol are applied in submission order
+type ShardedEngine struct {
+	mu sync.RWMutex // guards the flags only, never held while waiting for a queue
+	stopped bool
+	started bool
+	done chan struct{} // closed by Stop, wakes up blocked submits
+	submits sync.WaitGroup // submits in flight, the queues are closed after them
+
+	workers []*shardWorker
+	results chan SymbolResult
+	wg sync.WaitGroup
+}
+
+type shardWorker struct {
+	commands chan SymbolCommand
+	books map[string]*engineBook
+}
+
+// commandsCapacity is the bound of every worker queue, resultsCapacity 0
+// disables results, otherwise workers wait for consumers when it is full
+func NewShardedEngine(workers, commandsCapacity, resultsCapacity int) *ShardedEngine {
+	if workers <= 0 {
+		panic("at least one worker is required")
+	}
+
+	e := &ShardedEngine{
+		workers: make([]*shardWorker, workers),
+		done: make(chan struct{}),
+	}
+	for i := range e.workers {
+		e.workers[i] = &shardWorker{
+			commands: make(chan SymbolCommand, commandsCapacity),
+			books: make(map[string]*engineBook),
+		}
+	}
+	if resultsCapacity > 0 {
+		e.results = make(chan SymbolResult, resultsCapacity)
+	}
+	return e
+}
+
+func (this *ShardedEngine) Start() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.started || this.stopped {
+		return
+	}
+	this.started = true
+
+	this.wg.Add(len(this.workers))
+	for _, w := range this.workers {
+		go this.run(w)
+	}
+}
+
+// stops accepting commands, waits until the workers apply all the pending ones
+// and closes the results channel. Workers wait for the results consumers, so
+// Results have to be read until the channel is closed; an engine that was
+// never started applies the pending commands and drops the results not fitting
+// into the channel
+func (this *ShardedEngine) Stop() {
+	this.mu.Lock()
+	if this.stopped {
+		this.mu.Unlock()
+		return
+	}
+	this.stopped = true
+	started := this.started
+	this.mu.Unlock()
+
+	// submits blocked on full queues give up, then nobody sends to the queues
+	close(this.done)
+	this.submits.Wait()
+	for _, w := range this.workers {
+		close(w.commands)
+	}
+
+	if !started {
+		// nobody is going to drain the queues
+		for _, w := range this.workers {
+			this.drain(w, false)
+		}
+	}
+	this.wg.Wait()
+	if this.results != nil {
+		close(this.results)
+	}
+}
+
+// registers a submit in flight, false if the engine is stopped
+func (this *ShardedEngine) enter() bool {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	if this.stopped {
+		return false
+	}
+	this.submits.Add(1)
+	return true
+}
+
+// waits for a free slot in the symbol worker queue,
+// ErrEngineStopped if the engine is stopped meanwhile
+func (this *ShardedEngine) Submit(symbol string, c Command) error {
+	if !this.enter() {
+		return ErrEngineStopped
+	}
+	defer this.submits.Done()
+
+	select {
+	case this.worker(symbol).commands <- SymbolCommand{symbol, c}:
+		return nil
+	case <-this.done:
+		return ErrEngineStopped
+	}
+}
+
+// false if the symbol worker queue is full
+func (this *ShardedEngine) TrySubmit(symbol string, c Command) (bool, error) {
+	if !this.enter() {
+		return false, ErrEngineStopped
+	}
+	defer this.submits.Done()
+
+	select {
+	case this.worker(symbol).commands <- SymbolCommand{symbol, c}:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// results of all symbols, nil if results are disabled,
+// closed when the engine is stopped
+func (this *ShardedEngine) Results() <-chan SymbolResult {
+	return this.results
+}
+
+// the symbol book or nil if it has received no commands,
+// safe to use only after Stop
+func (this *ShardedEngine) Book(symbol string) *Orderbook {
+	b := this.worker(symbol).books[symbol]
+	if b == nil {
+		return nil
+	}
+	return &b.book
+}
+
+func (this *ShardedEngine) worker(symbol string) *shardWorker {
+	// FNV-1a
+	h := uint32(2166136261)
+	for i := 0; i < len(symbol); i += 1 {
+		h ^= uint32(symbol[i])
+		h *= 16777619
+	}
+	return this.workers[h % uint32(len(this.workers))]
+}
+
+func (this *ShardedEngine) run(w *shardWorker) {
+	defer this.wg.Done()
+	this.drain(w, true)
+}
+
+// applies commands until the queue is closed and empty, without wait
+// the results are published only if there is room for them
+func (this *ShardedEngine) drain(w *shardWorker, wait bool) {
+	for c := range w.commands {
+		b := w.books[c.Symbol]
+		if b == nil {
+			book := newEngineBook()
+			b = &book
+			w.books[c.Symbol] = b
+		}
+
+		status := b.apply(&c.Command)
+		if this.results == nil {
+			continue
+		}
+		r := SymbolResult{c.Symbol, Result{c.Type, c.OrderId, status}}
+		if wait {
+			this.results <- r
+		} else {
+			select {
+			case this.results <- r:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package hftorderbook
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShardedEngineSymbolOrdering(t *testing.T) {
+	e := NewShardedEngine(4, 8, 0)
+	e.Start()
+
+	symbols, n := 50, 1000
+	var wg sync.WaitGroup
+	for s := 0; s < symbols; s += 1 {
+		wg.Add(1)
+		go func(symbol string) {
+			defer wg.Done()
+			for i := 0; i < n; i += 1 {
+				e.Submit(symbol, Command{CommandAdd, i, true, 1.0, 1})
+				if i % 2 == 1 {
+					// applied after the add of the same symbol
+					e.Submit(symbol, Command{CommandCancel, i - 1, true, 0, 0})
+				}
+			}
+		}(fmt.Sprintf("SYM%d", s))
+	}
+	wg.Wait()
+	e.Stop()
+
+	for s := 0; s < symbols; s += 1 {
+		book := e.Book(fmt.Sprintf("SYM%d", s))
+		if book == nil || book.GetVolumeAtBidLimit(1.0) != float64(n / 2) {
+			t.Errorf("invalid book of symbol %d", s)
+		}
+	}
+	if e.Book("UNKNOWN") != nil {
+		t.Errorf("unknown symbol should have no book")
+	}
+}
+
+func TestShardedEngineResults(t *testing.T) {
+	e := NewShardedEngine(2, 4, 4)
+	e.Start()
+
+	expected := map[string][]ResultStatus{
+		"AAPL": {ResultAccepted, ResultRejected, ResultAccepted},
+		"MSFT": {ResultAccepted, ResultAccepted},
+	}
+	received := make(map[string][]ResultStatus)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// closed by Stop
+		for r := range e.Results() {
+			received[r.Symbol] = append(received[r.Symbol], r.Status)
+		}
+	}()
+
+	e.Submit("AAPL", Command{CommandAdd, 1, true, 1.0, 1})
+	e.Submit("MSFT", Command{CommandAdd, 1, false, 2.0, 1})
+	e.Submit("AAPL", Command{CommandAdd, 1, true, 1.0, 1}) // duplicate id
+	e.Submit("MSFT", Command{CommandCancel, 1, false, 0, 0})
+	e.Submit("AAPL", Command{CommandAmend, 1, true, 1.5, 2})
+	e.Stop()
+	<-done
+
+	for symbol, statuses := range expected {
+		if fmt.Sprint(received[symbol]) != fmt.Sprint(statuses) {
+			t.Errorf("%s: expected %v, got %v", symbol, statuses, received[symbol])
+		}
+	}
+}
+
+func TestShardedEngineStop(t *testing.T) {
+	e := NewShardedEngine(2, 16, 0)
+	for i := 0; i < 10; i += 1 {
+		e.Submit("AAPL", Command{CommandAdd, i, false, 1.0, 1})
+	}
+
+	// pending commands are applied even if the engine was never started
+	e.Stop()
+	if e.Book("AAPL").GetVolumeAtAskLimit(1.0) != 10 {
+		t.Errorf("pending commands should be drained on stop")
+	}
+
+	if err := e.Submit("AAPL", Command{CommandAdd, 10, false, 1.0, 1}); err != ErrEngineStopped {
+		t.Errorf("submit after stop should fail, got %v", err)
+	}
+	if ok, err := e.TrySubmit("AAPL", Command{CommandAdd, 10, false, 1.0, 1}); ok || err != ErrEngineStopped {
+		t.Errorf("try submit after stop should fail")
+	}
+	e.Stop()
+}
+
+// a submit blocked on a full queue of an engine never started does not hold
+// Stop, neither do the results nobody reads
+func TestShardedEngineStopBlockedSubmit(t *testing.T) {
+	e := NewShardedEngine(1, 2, 1)
+	for i := 0; i < 2; i += 1 {
+		e.Submit("AAPL", Command{CommandAdd, i, false, 1.0, 1})
+	}
+
+	submitted := make(chan error)
+	go func() {
+		submitted <- e.Submit("AAPL", Command{CommandAdd, 2, false, 1.0, 1})
+	}()
+
+	stopped := make(chan bool)
+	go func() {
+		e.Stop()
+		stopped <- true
+	}()
+
+	timeout := time.After(5 * time.Second)
+	for i := 0; i < 2; i += 1 {
+		select {
+		case err := <-submitted:
+			if err != nil && err != ErrEngineStopped {
+				t.Errorf("blocked submit should fail with ErrEngineStopped, got %v", err)
+			}
+		case <-stopped:
+		case <-timeout:
+			t.Fatal("stop should not wait for the blocked submit")
+		}
+	}
+	if e.Book("AAPL").GetVolumeAtAskLimit(1.0) < 2 {
+		t.Errorf("queued commands should be applied on stop")
+	}
+}
+
+func benchmarkShardedEngineSymbols(symbols int, b *testing.B) {
+	names := make([]string, symbols)
+	for i := range names {
+		names[i] = fmt.Sprintf("SYM%d", i)
+	}
+	commands := make([]SymbolCommand, b.N)
+	for i := range commands {
+		price := rand.Float64()
+		commands[i] = SymbolCommand{names[i % symbols], Command{CommandAdd, i, price < 0.5, price, 1}}
+	}
+
+	e := NewShardedEngine(4, 4096, 0)
+	e.Start()
+
+	// measure submission and processing time
+	b.ResetTimer()
+	for i := range commands {
+		e.Submit(commands[i].Symbol, commands[i].Command)
+	}
+	e.Stop()
+}
+
+func BenchmarkShardedEngine10Symbols(b *testing.B) {
+	benchmarkShardedEngineSymbols(10, b)
+}
+
+func BenchmarkShardedEngine100Symbols(b *testing.B) {
+	benchmarkShardedEngineSymbols(100, b)
+}
+
+func BenchmarkShardedEngine500Symbols(b *testing.B) {
+	benchmarkShardedEngineSymbols(500, b)
+}
@@ -0,0 +1,86 @@
+package hftorderbook
+
+// Price levels of one side of the book ordered by price,
+// Get, Min, Max, MinValue and MaxValue panic if there is no such level
+type Side interface {
+	Size() int
+	IsEmpty() bool
+	Contains(price float64) bool
+	Get(price float64) *LimitOrder
+	Put(price float64, limit *LimitOrder)
+	Delete(price float64)
+
+	Min() float64
+	Max() float64
+	MinValue() *LimitOrder
+	MaxValue() *LimitOrder
+
+	// nearest level above or below the price, nil if there is none
+	Next(price float64) *LimitOrder
+	Prev(price float64) *LimitOrder
+
+	// calls fn for levels between lo and hi in ascending order until it returns false
+	Range(lo, hi float64, fn func(limit *LimitOrder) bool)
+
+	// calls fn for all levels in ascending or descending order until it returns false,
+	// following the links of the structure instead of a search per level
+	Walk(descending bool, fn func(limit *LimitOrder) bool)
+}
+
+// Side taking only some prices, TryAdd and snapshot restores check a new
+// level before it is put. Prices close to a taken one are snapped to it,
+// so the book keys every level by a single price.
+type PriceChecker interface {
+	CheckPrice(price float64) error
+	SnapPrice(price float64) float64
+}
+
+func (this *Orderbook) checkPrice(price float64, bidOrAsk bool) error {
+	side := this.Asks
+	if bidOrAsk {
+		side = this.Bids
+	}
+	if c, ok := side.(PriceChecker); ok {
+		return c.CheckPrice(price)
+	}
+	return nil
+}
+
+// price the level is kept under, the price itself for the sides taking any
+func (this *Orderbook) snapPrice(price float64, bidOrAsk bool) float64 {
+	side := this.Asks
+	if bidOrAsk {
+		side = this.Bids
+	}
+	if c, ok := side.(PriceChecker); ok {
+		return c.SnapPrice(price)
+	}
+	return price
+}
+
+// calls fn for the levels of the side from the best one until it returns false
+func (this *Orderbook) walk(side Side, bidOrAsk bool, fn func(limit *LimitOrder) bool) {
+	// bids are best at the max price
+	side.Walk(bidOrAsk, fn)
+}
+
+// appends the top depth levels of the side to buf, best first
+func (this *Orderbook) topLimits(buf []*LimitOrder, side Side, bidOrAsk bool, depth int) []*LimitOrder {
+	if depth <= 0 {
+		return buf
+	}
+	n := len(buf) + depth
+	this.walk(side, bidOrAsk, func(limit *LimitOrder) bool {
+		buf = append(buf, limit)
+		return len(buf) < n
+	})
+	return buf
+}
+
+// calls fn for all levels of the side in ascending order
+func (this *Orderbook) ascend(side Side, fn func(limit *LimitOrder)) {
+	side.Walk(false, func(limit *LimitOrder) bool {
+		fn(limit)
+		return true
+	})
+}
@@ -0,0 +1,406 @@
+package hftorderbook
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+var testSides = []struct {
+	name string
+	newSide func(bidOrAsk bool) Side
+}{
+	{"RedBlack", func(bidOrAsk bool) Side {
+		t := NewRedBlackBST()
+		return &t
+	}},
+	{"BST", func(bidOrAsk bool) Side {
+		t := NewBST()
+		return &t
+	}},
+	{"Heap", func(bidOrAsk bool) Side {
+		pq := NewHeapSide(bidOrAsk)
+		return &pq
+	}},
+	{"TickLadder", func(bidOrAsk bool) Side {
+		l := NewTickLadder(0.01, 64)
+		return &l
+	}},
+	{"SkipList", func(bidOrAsk bool) Side {
+		l := NewSkipList()
+		return &l
+	}},
+	{"BTree", func(bidOrAsk bool) Side {
+		t := NewBTree()
+		return &t
+	}},
+}
+
+func TestSides(t *testing.T) {
+	for _, ts := range testSides {
+		for _, bidOrAsk := range []bool{true, false} {
+			side := ts.newSide(bidOrAsk)
+			prices := make(map[float64]bool)
+			for i := 0; i < 2000; i += 1 {
+				price := float64(rand.Intn(1000)) / 100
+				if rand.Intn(3) == 0 && len(prices) > 0 {
+					for p := range prices {
+						side.Delete(p)
+						delete(prices, p)
+						break
+					}
+					continue
+				}
+				limit := NewLimitOrder(price)
+				side.Put(price, &limit)
+				prices[price] = true
+			}
+
+			keys := make([]float64, 0, len(prices))
+			for p := range prices {
+				keys = append(keys, p)
+			}
+			sort.Float64s(keys)
+
+			if side.Size() != len(keys) || side.Min() != keys[0] || side.Max() != keys[len(keys) - 1] {
+				t.Errorf("%s: invalid size or extremes", ts.name)
+				continue
+			}
+			if side.MinValue().Price != keys[0] || side.MaxValue().Price != keys[len(keys) - 1] {
+				t.Errorf("%s: invalid extreme values", ts.name)
+			}
+
+			// walking the levels both ways
+			i := 0
+			for l := side.MinValue(); l != nil; l = side.Next(l.Price) {
+				if !side.Contains(l.Price) || side.Get(l.Price) != l || l.Price != keys[i] {
+					t.Errorf("%s: expected next level %0.2f, got %0.2f", ts.name, keys[i], l.Price)
+					break
+				}
+				i += 1
+			}
+			i = len(keys) - 1
+			for l := side.MaxValue(); l != nil; l = side.Prev(l.Price) {
+				if l.Price != keys[i] {
+					t.Errorf("%s: expected prev level %0.2f, got %0.2f", ts.name, keys[i], l.Price)
+					break
+				}
+				i -= 1
+			}
+
+			// ordered walks following the links, stopped early
+			for _, descending := range []bool{false, true} {
+				visited := make([]float64, 0)
+				side.Walk(descending, func(l *LimitOrder) bool {
+					visited = append(visited, l.Price)
+					return true
+				})
+				for i := range visited {
+					j := i
+					if descending {
+						j = len(keys) - 1 - i
+					}
+					if i >= len(keys) || visited[i] != keys[j] {
+						t.Fatalf("%s: invalid walk at %d", ts.name, i)
+					}
+				}
+				if len(visited) != len(keys) {
+					t.Errorf("%s: walk visited %d of %d levels", ts.name, len(visited), len(keys))
+				}
+
+				count := 0
+				side.Walk(descending, func(l *LimitOrder) bool {
+					count += 1
+					return count < 3
+				})
+				if count != 3 {
+					t.Errorf("%s: walk should stop when fn returns false", ts.name)
+				}
+			}
+
+			// range in the middle, stopped early
+			lo, hi := keys[len(keys) / 4], keys[len(keys) * 3 / 4]
+			visited := make([]float64, 0)
+			side.Range(lo, hi, func(l *LimitOrder) bool {
+				visited = append(visited, l.Price)
+				return len(visited) < 5
+			})
+			if len(visited) != 5 || visited[0] != lo || visited[4] != keys[len(keys) / 4 + 4] {
+				t.Errorf("%s: invalid range %v", ts.name, visited)
+			}
+		}
+	}
+}
+
+// ordered tables with order statistics, the side structures keyed by ints
+type orderedTable interface {
+	Put(key int, value int)
+	Delete(key int)
+	Get(key int) int
+	IsEmpty() bool
+	Size() int
+	Min() int
+	Max() int
+	Floor(key int) int
+	Ceiling(key int) int
+	Select(k int) int
+	Rank(key int) int
+	Keys(lo, hi int) []int
+}
+
+var testOrderedTables = []struct {
+	name string
+	newTable func() orderedTable
+}{
+	{"RedBlack", func() orderedTable {
+		t := NewRedBlackBSTOf[int, int]()
+		return &t
+	}},
+	{"SkipList", func() orderedTable {
+		l := NewSkipListOf[int, int]()
+		return &l
+	}},
+	{"BTree", func() orderedTable {
+		t := NewBTreeOf[int, int]()
+		return &t
+	}},
+}
+
+// compares the tables against sorted keys after random puts and deletes
+func TestOrderStatistics(t *testing.T) {
+	for _, ts := range testOrderedTables {
+		st := ts.newTable()
+		keys := make(map[int]bool)
+		for i := 0; i < 20000; i += 1 {
+			k := rand.Intn(5000) * 2
+			if rand.Intn(3) == 0 && keys[k] {
+				st.Delete(k)
+				delete(keys, k)
+			} else {
+				st.Put(k, k * 10)
+				keys[k] = true
+			}
+		}
+
+		sorted := make([]int, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Ints(sorted)
+
+		if st.Size() != len(sorted) || st.Min() != sorted[0] || st.Max() != sorted[len(sorted) - 1] {
+			t.Fatalf("%s: invalid size or extremes", ts.name)
+		}
+		for i, k := range sorted {
+			if st.Select(i) != k || st.Rank(k) != i || st.Get(k) != k * 10 {
+				t.Fatalf("%s: invalid select, rank or value for key %d", ts.name, k)
+			}
+			// odd keys are never in the table
+			if st.Rank(k + 1) != i + 1 || st.Floor(k + 1) != k || st.Ceiling(k - 1) != k {
+				t.Fatalf("%s: invalid rank, floor or ceiling around key %d", ts.name, k)
+			}
+		}
+
+		lo, hi := sorted[len(sorted) / 4], sorted[len(sorted) * 3 / 4]
+		got := st.Keys(lo, hi)
+		expected := sorted[len(sorted) / 4:len(sorted) * 3 / 4 + 1]
+		if len(got) != len(expected) {
+			t.Fatalf("%s: expected %d keys, got %d", ts.name, len(expected), len(got))
+		}
+		for i := range got {
+			if got[i] != expected[i] {
+				t.Fatalf("%s: expected key %d, got %d", ts.name, expected[i], got[i])
+			}
+		}
+
+		// reusable after deleting all keys
+		for !st.IsEmpty() {
+			st.Delete(st.Select(rand.Intn(st.Size())))
+		}
+		st.Put(1, 10)
+		if st.Min() != 1 || st.Max() != 1 || st.Rank(2) != 1 {
+			t.Errorf("%s: table should be reusable after deleting all keys", ts.name)
+		}
+	}
+}
+
+// the same operations give the same books with all side structures
+func TestOrderbookSides(t *testing.T) {
+	seed := rand.Int63()
+	var expected []byte
+	for _, ts := range testSides {
+		book := NewOrderbookWithConfig(OrderbookConfig{NewSide: ts.newSide})
+		r := rand.New(rand.NewSource(seed))
+
+		orders := make([]*Order, 0)
+		for i := 0; i < 5000; i += 1 {
+			switch {
+			case r.Intn(4) == 0 && len(orders) > 0:
+				j := r.Intn(len(orders))
+				book.Cancel(orders[j])
+				orders[j] = orders[len(orders) - 1]
+				orders = orders[:len(orders) - 1]
+			case r.Intn(4) == 0 && len(orders) > 0:
+				j := r.Intn(len(orders))
+				o := orders[j]
+				book.Reduce(o, 0.5)
+				if o.Volume <= 0 {
+					orders[j] = orders[len(orders) - 1]
+					orders = orders[:len(orders) - 1]
+				}
+			default:
+				price := float64(r.Intn(200)) / 100
+				o := &Order{Id: i, Volume: float64(1 + r.Intn(3)) / 2, BidOrAsk: price < 1}
+				book.Add(price, o)
+				orders = append(orders, o)
+			}
+		}
+
+		data, _ := book.MarshalBinary()
+		if expected == nil {
+			expected = data
+		} else if !bytes.Equal(data, expected) {
+			t.Errorf("%s: book differs from the red-black one", ts.name)
+		}
+
+		var restored Orderbook
+		restored.config.NewSide = ts.newSide
+		if err := restored.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		sameOrderbooks(t, &book, &restored)
+	}
+}
+
+func benchmarkOrderbookSideRandomInsert(newSide func(bidOrAsk bool) Side, n int, b *testing.B) {
+	book := NewOrderbookWithConfig(OrderbookConfig{NewSide: newSide})
+
+	// prices on a 0.01 tick grid with a bounded band
+	limitslist := make([]float64, n)
+	for i := range limitslist {
+		limitslist[i] = float64(rand.Intn(2 * n)) / 100
+	}
+
+	// preallocate empty orders
+	orders := make([]*Order, 0, b.N)
+	for i := 0; i < b.N; i += 1 {
+		orders = append(orders, &Order{})
+	}
+
+	// measure insertion time
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		price := limitslist[rand.Intn(len(limitslist))]
+
+		o := orders[i]
+		o.Id = i
+		o.Volume = rand.Float64()
+		o.BidOrAsk = price < float64(n) / 100
+
+		book.Add(price, o)
+	}
+}
+
+func BenchmarkOrderbook10kLevelsRandomInsertRedBlack(b *testing.B) {
+	benchmarkOrderbookSideRandomInsert(testSides[0].newSide, 10000, b)
+}
+
+func BenchmarkOrderbook10kLevelsRandomInsertBST(b *testing.B) {
+	benchmarkOrderbookSideRandomInsert(testSides[1].newSide, 10000, b)
+}
+
+func BenchmarkOrderbook10kLevelsRandomInsertHeap(b *testing.B) {
+	benchmarkOrderbookSideRandomInsert(testSides[2].newSide, 10000, b)
+}
+
+func BenchmarkOrderbook10kLevelsRandomInsertTickLadder(b *testing.B) {
+	benchmarkOrderbookSideRandomInsert(testSides[3].newSide, 10000, b)
+}
+
+func BenchmarkOrderbook10kLevelsRandomInsertSkipList(b *testing.B) {
+	benchmarkOrderbookSideRandomInsert(testSides[4].newSide, 10000, b)
+}
+
+func BenchmarkOrderbook10kLevelsRandomInsertBTree(b *testing.B) {
+	benchmarkOrderbookSideRandomInsert(testSides[5].newSide, 10000, b)
+}
+
+// the best level empties and comes back, every cancel looks for the next best
+// level among n levels spaced apart by gap ticks
+func benchmarkOrderbookSideTopChurn(newSide func(bidOrAsk bool) Side, n, gap int, b *testing.B) {
+	book := NewOrderbookWithConfig(OrderbookConfig{NewSide: newSide})
+	for i := 1; i <= n; i += 1 {
+		book.Add(float64(i * gap) / 100, &Order{Id: i, Volume: 1})
+	}
+	best := &Order{Id: 0, Volume: 1}
+	price := float64(gap / 2) / 100
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		book.Add(price, best)
+		book.Cancel(best)
+	}
+}
+
+func BenchmarkOrderbook10kLevelsTopChurnRedBlack(b *testing.B) {
+	benchmarkOrderbookSideTopChurn(testSides[0].newSide, 10000, 100, b)
+}
+
+func BenchmarkOrderbook10kLevelsTopChurnTickLadder(b *testing.B) {
+	benchmarkOrderbookSideTopChurn(testSides[3].newSide, 10000, 100, b)
+}
+
+// top 25 levels of a 10K-level side from the best one, as the checksums do
+func benchmarkSideWalkTop(newSide func(bidOrAsk bool) Side, b *testing.B) {
+	side := newSide(true)
+	for i := 0; i < 10000; i += 1 {
+		price := float64(i) / 100
+		limit := NewLimitOrder(price)
+		side.Put(price, &limit)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		n := 0
+		side.Walk(true, func(l *LimitOrder) bool {
+			n += 1
+			return n < 25
+		})
+	}
+}
+
+func BenchmarkRedBlack10kLevelsWalkTop(b *testing.B) {
+	benchmarkSideWalkTop(testSides[0].newSide, b)
+}
+
+func BenchmarkHeap10kLevelsWalkTop(b *testing.B) {
+	benchmarkSideWalkTop(testSides[2].newSide, b)
+}
+
+// a level added and removed on a 10K-level side
+func benchmarkSidePutDelete(newSide func(bidOrAsk bool) Side, b *testing.B) {
+	side := newSide(true)
+	for i := 0; i < 10000; i += 1 {
+		side.Put(rand.Float64(), nil)
+	}
+	keys := make([]float64, 1024)
+	for i := range keys {
+		keys[i] = rand.Float64() + 1
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		k := keys[i % len(keys)]
+		side.Put(k, nil)
+		side.Delete(k)
+	}
+}
+
+func BenchmarkSkipListPutDelete(b *testing.B) {
+	benchmarkSidePutDelete(testSides[4].newSide, b)
+}
+
+func BenchmarkBTreePutDelete(b *testing.B) {
+	benchmarkSidePutDelete(testSides[5].newSide, b)
+}
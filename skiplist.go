@@ -0,0 +1,357 @@
+package hftorderbook
+
+import (
+	"cmp"
+	"fmt"
+)
+
+// Indexable skip list: every link keeps its span, the number of level 0 steps
+// it jumps over, so select and rank run in expected lgN next to search, put,
+// delete, floor and ceiling. Level 0 is doubly linked for O(1) min and max.
+
+const (
+	skipListMaxLevel = 32
+	skipListP = 4 // a node gets one more level with probability 1/skipListP
+)
+
+type skipLink[K cmp.Ordered, V any] struct {
+	node *SkipListNode[K, V]
+	span int
+}
+
+type SkipListNode[K cmp.Ordered, V any] struct {
+	Key K
+	Value V
+	Prev *SkipListNode[K, V] // nil for the first node
+
+	next []skipLink[K, V]
+}
+
+// next node at level 0
+func (n *SkipListNode[K, V]) Next() *SkipListNode[K, V] {
+	return n.next[0].node
+}
+
+type SkipList[K cmp.Ordered, V any] struct {
+	head SkipListNode[K, V]
+	tail *SkipListNode[K, V]
+	level int
+	size int
+	seed uint64
+
+	// search paths of Put and Delete, kept to avoid allocations,
+	// reads do not touch them so concurrent readers are safe
+	update [skipListMaxLevel]*SkipListNode[K, V]
+	rank [skipListMaxLevel]int
+}
+
+// price levels skip list
+type skipList = SkipList[float64, *LimitOrder]
+
+func NewSkipList() skipList {
+	return NewSkipListOf[float64, *LimitOrder]()
+}
+
+func NewSkipListOf[K cmp.Ordered, V any]() SkipList[K, V] {
+	return SkipList[K, V]{
+		head: SkipListNode[K, V]{
+			next: make([]skipLink[K, V], skipListMaxLevel),
+		},
+		level: 1,
+		// fixed seed, the same operations build the same list
+		seed: 0x9e3779b97f4a7c15,
+	}
+}
+
+func (t *SkipList[K, V]) Size() int {
+	return t.size
+}
+
+func (t *SkipList[K, V]) IsEmpty() bool {
+	return t.size == 0
+}
+
+func (t *SkipList[K, V]) panicIfEmpty() {
+	if t.IsEmpty() {
+		panic("Skip list is empty")
+	}
+}
+
+func (t *SkipList[K, V]) randomLevel() int {
+	level := 1
+	for level < skipListMaxLevel {
+		// xorshift64
+		t.seed ^= t.seed << 13
+		t.seed ^= t.seed >> 7
+		t.seed ^= t.seed << 17
+		if t.seed % skipListP != 0 {
+			break
+		}
+		level++
+	}
+	return level
+}
+
+// last node with the key < key (or <= key if inclusive) at level 0,
+// the head if there is none, and the number of keys up to it, read only
+func (t *SkipList[K, V]) find(key K, inclusive bool) (*SkipListNode[K, V], int) {
+	x := &t.head
+	rank := 0
+	for i := t.level - 1; i >= 0; i-- {
+		for {
+			next := x.next[i].node
+			if next == nil || next.Key > key || next.Key == key && !inclusive {
+				break
+			}
+			rank += x.next[i].span
+			x = next
+		}
+	}
+	return x, rank
+}
+
+// the same as find, filling the search path for Put and Delete
+func (t *SkipList[K, V]) search(key K, inclusive bool) *SkipListNode[K, V] {
+	x := &t.head
+	for i := t.level - 1; i >= 0; i-- {
+		if i == t.level - 1 {
+			t.rank[i] = 0
+		} else {
+			t.rank[i] = t.rank[i+1]
+		}
+		for {
+			next := x.next[i].node
+			if next == nil || next.Key > key || next.Key == key && !inclusive {
+				break
+			}
+			t.rank[i] += x.next[i].span
+			x = next
+		}
+		t.update[i] = x
+	}
+	return x
+}
+
+func (t *SkipList[K, V]) get(key K) *SkipListNode[K, V] {
+	x, _ := t.find(key, false)
+	x = x.next[0].node
+	if x == nil || x.Key != key {
+		return nil
+	}
+	return x
+}
+
+func (t *SkipList[K, V]) Contains(key K) bool {
+	return t.get(key) != nil
+}
+
+func (t *SkipList[K, V]) Get(key K) V {
+	t.panicIfEmpty()
+
+	x := t.get(key)
+	if x == nil {
+		panic(fmt.Sprintf("key %v does not exist", key))
+	}
+	return x.Value
+}
+
+func (t *SkipList[K, V]) Put(key K, value V) {
+	x := t.search(key, false)
+	if next := x.next[0].node; next != nil && next.Key == key {
+		// search hit, updating the value
+		next.Value = value
+		return
+	}
+
+	level := t.randomLevel()
+	if level > t.level {
+		for i := t.level; i < level; i++ {
+			t.rank[i] = 0
+			t.update[i] = &t.head
+			t.head.next[i] = skipLink[K, V]{nil, t.size}
+		}
+		t.level = level
+	}
+
+	n := &SkipListNode[K, V]{
+		Key: key,
+		Value: value,
+		next: make([]skipLink[K, V], level),
+	}
+	for i := 0; i < level; i++ {
+		link := &t.update[i].next[i]
+		n.next[i] = skipLink[K, V]{link.node, link.span - (t.rank[0] - t.rank[i])}
+		*link = skipLink[K, V]{n, t.rank[0] - t.rank[i] + 1}
+	}
+	// the new node is under the higher links
+	for i := level; i < t.level; i++ {
+		t.update[i].next[i].span++
+	}
+
+	if x != &t.head {
+		n.Prev = x
+	}
+	if next := n.next[0].node; next != nil {
+		next.Prev = n
+	} else {
+		t.tail = n
+	}
+	t.size++
+}
+
+func (t *SkipList[K, V]) Delete(key K) {
+	t.panicIfEmpty()
+
+	x := t.search(key, false).next[0].node
+	if x == nil || x.Key != key {
+		// search miss
+		return
+	}
+
+	for i := 0; i < t.level; i++ {
+		link := &t.update[i].next[i]
+		if link.node == x {
+			*link = skipLink[K, V]{x.next[i].node, link.span + x.next[i].span - 1}
+		} else {
+			link.span--
+		}
+	}
+
+	if next := x.next[0].node; next != nil {
+		next.Prev = x.Prev
+	} else {
+		t.tail = x.Prev
+	}
+	for t.level > 1 && t.head.next[t.level - 1].node == nil {
+		t.level--
+	}
+	t.size--
+}
+
+func (t *SkipList[K, V]) MinPointer() *SkipListNode[K, V] {
+	t.panicIfEmpty()
+	return t.head.next[0].node
+}
+
+func (t *SkipList[K, V]) MaxPointer() *SkipListNode[K, V] {
+	t.panicIfEmpty()
+	return t.tail
+}
+
+func (t *SkipList[K, V]) Min() K {
+	return t.MinPointer().Key
+}
+
+func (t *SkipList[K, V]) MinValue() V {
+	return t.MinPointer().Value
+}
+
+func (t *SkipList[K, V]) Max() K {
+	return t.MaxPointer().Key
+}
+
+func (t *SkipList[K, V]) MaxValue() V {
+	return t.MaxPointer().Value
+}
+
+func (t *SkipList[K, V]) Floor(key K) K {
+	t.panicIfEmpty()
+
+	x, _ := t.find(key, true)
+	if x == &t.head {
+		panic(fmt.Sprintf("there are no keys <= %v", key))
+	}
+	return x.Key
+}
+
+func (t *SkipList[K, V]) Ceiling(key K) K {
+	t.panicIfEmpty()
+
+	x, _ := t.find(key, false)
+	x = x.next[0].node
+	if x == nil {
+		panic(fmt.Sprintf("there are no keys >= %v", key))
+	}
+	return x.Key
+}
+
+// value of the least key > key, zero value if there is none
+func (t *SkipList[K, V]) Next(key K) V {
+	x, _ := t.find(key, true)
+	x = x.next[0].node
+	if x == nil {
+		var zero V
+		return zero
+	}
+	return x.Value
+}
+
+// value of the greatest key < key, zero value if there is none
+func (t *SkipList[K, V]) Prev(key K) V {
+	x, _ := t.find(key, false)
+	if x == &t.head {
+		var zero V
+		return zero
+	}
+	return x.Value
+}
+
+// k-th smallest key, 0-based
+func (t *SkipList[K, V]) Select(k int) K {
+	if k < 0 || k >= t.size {
+		panic("index out of range")
+	}
+
+	x := &t.head
+	traversed := 0
+	for i := t.level - 1; i >= 0; i-- {
+		for x.next[i].node != nil && traversed + x.next[i].span <= k + 1 {
+			traversed += x.next[i].span
+			x = x.next[i].node
+		}
+	}
+	return x.Key
+}
+
+// number of keys < key
+func (t *SkipList[K, V]) Rank(key K) int {
+	_, rank := t.find(key, false)
+	return rank
+}
+
+func (t *SkipList[K, V]) Range(lo, hi K, fn func(value V) bool) {
+	x, _ := t.find(lo, false)
+	for x = x.next[0].node; x != nil && x.Key <= hi; x = x.next[0].node {
+		if !fn(x.Value) {
+			return
+		}
+	}
+}
+
+// walks level 0 from the first or the last node
+func (t *SkipList[K, V]) Walk(descending bool, fn func(value V) bool) {
+	x := t.head.next[0].node
+	if descending {
+		x = t.tail
+	}
+	for x != nil && fn(x.Value) {
+		if descending {
+			x = x.Prev
+		} else {
+			x = x.next[0].node
+		}
+	}
+}
+
+func (t *SkipList[K, V]) Keys(lo, hi K) []K {
+	if lo < t.Min() || hi > t.Max() {
+		panic("keys out of range")
+	}
+
+	keys := make([]K, 0)
+	x, _ := t.find(lo, false)
+	for x = x.next[0].node; x != nil && x.Key <= hi; x = x.next[0].node {
+		keys = append(keys, x.Key)
+	}
+	return keys
+}
@@ -0,0 +1,94 @@
+package hftorderbook
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// checks key order, level 0 back links and the spans of all links
+func checkSkipList(t *testing.T, st *SkipList[int, int]) {
+	position := map[*SkipListNode[int, int]]int{&st.head: 0}
+	var prev *SkipListNode[int, int]
+	for x := st.head.next[0].node; x != nil; x = x.next[0].node {
+		if x.Prev != prev || prev != nil && prev.Key >= x.Key {
+			t.Fatalf("invalid level 0 links at key %d", x.Key)
+		}
+		position[x] = len(position)
+		prev = x
+	}
+	if st.tail != prev || len(position) != st.Size() + 1 {
+		t.Fatalf("invalid tail or size")
+	}
+
+	for x, pos := range position {
+		for i, link := range x.next {
+			if link.node == nil {
+				continue
+			}
+			if i >= st.level || len(link.node.next) <= i {
+				t.Fatalf("link at level %d above the node levels", i)
+			}
+			if position[link.node] - pos != link.span {
+				t.Fatalf("link at level %d spans %d, expected %d", i, link.span, position[link.node] - pos)
+			}
+		}
+	}
+}
+
+func TestSkipListSpans(t *testing.T) {
+	st := NewSkipListOf[int, int]()
+	for i := 0; i < 20000; i += 1 {
+		k := rand.Intn(2000)
+		if rand.Intn(3) == 0 && !st.IsEmpty() {
+			st.Delete(k)
+		} else {
+			st.Put(k, k)
+		}
+	}
+	checkSkipList(t, &st)
+
+	for !st.IsEmpty() {
+		st.Delete(st.Select(rand.Intn(st.Size())))
+	}
+	checkSkipList(t, &st)
+}
+
+// reads keep off the search paths of Put and Delete, so they may run
+// concurrently under a read lock
+func TestSkipListConcurrentReads(t *testing.T) {
+	st := NewSkipListOf[int, int]()
+	for i := 0; i < 10000; i += 1 {
+		st.Put(i * 2, i)
+	}
+	update, rank := st.update, st.rank
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g += 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10000; i += 1 {
+				k := rand.Intn(20000)
+				st.Contains(k)
+				st.Next(k)
+				st.Prev(k)
+				st.Rank(k)
+				if k > 0 {
+					st.Floor(k)
+				}
+				if k < 19998 {
+					st.Ceiling(k)
+				}
+				st.Range(k, k + 10, func(v int) bool {
+					return true
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if st.update != update || st.rank != rank {
+		t.Errorf("reads should not touch the search paths")
+	}
+}
@@ -0,0 +1,286 @@
+package hftorderbook
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Binary snapshot layout, integers are little endian or varints:
+//
+//	magic "HFOB", version byte
+//	bids, asks: uvarint levels count, levels in ascending price order
+//	level: float64 price, uvarint orders count, orders in FIFO order
+//	order: varint id, float64 volume
+const snapshotVersion byte = 1
+
+var snapshotMagic = []byte("HFOB")
+
+var (
+	ErrInvalidSnapshot = errors.New("invalid orderbook snapshot")
+	ErrUnsortedLevels = errors.New("levels are not in price order")
+)
+
+func (this *Orderbook) MarshalBinary() ([]byte, error) {
+	// rough estimate to avoid re-allocations for typical books
+	buf := make([]byte, 0, 64 + (this.BLength() + this.ALength()) * 32)
+	buf = append(buf, snapshotMagic...)
+	buf = append(buf, snapshotVersion)
+	buf = this.appendSide(buf, this.Bids)
+	buf = this.appendSide(buf, this.Asks)
+	return buf, nil
+}
+
+func (this *Orderbook) appendSide(buf []byte, side Side) []byte {
+	buf = binary.AppendUvarint(buf, uint64(side.Size()))
+	this.ascend(side, func(limit *LimitOrder) {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(limit.Price))
+		buf = binary.AppendUvarint(buf, uint64(limit.Size()))
+
+		for o := limit.Front(); o != nil; o = limit.Next(o) {
+			buf = binary.AppendVarint(buf, int64(o.Id))
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(o.Volume))
+		}
+	})
+
+	return buf
+}
+
+// replaces the book content with the snapshot, orders are allocated anew
+func (this *Orderbook) UnmarshalBinary(data []byte) error {
+	if len(data) < len(snapshotMagic) + 1 || string(data[:len(snapshotMagic)]) != string(snapshotMagic) {
+		return fmt.Errorf("%w: bad magic", ErrInvalidSnapshot)
+	}
+	if v := data[len(snapshotMagic)]; v != snapshotVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidSnapshot, v)
+	}
+
+	r := snapshotReader{data: data, pos: len(snapshotMagic) + 1}
+	book := NewOrderbookWithConfig(this.config)
+	if err := r.readSide(&book, true); err != nil {
+		return err
+	}
+	if err := r.readSide(&book, false); err != nil {
+		return err
+	}
+	if r.pos != len(data) {
+		return fmt.Errorf("%w: %d trailing bytes", ErrInvalidSnapshot, len(data) - r.pos)
+	}
+
+	this.restore(book)
+	return nil
+}
+
+// swaps in the restored book keeping the listeners, which see the old levels
+// deleted and the restored orders added
+func (this *Orderbook) restore(book Orderbook) {
+	if this.listeners != nil && this.Bids != nil {
+		for !this.Bids.IsEmpty() {
+			this.DeleteBidLimit(this.Bids.Min())
+		}
+		for !this.Asks.IsEmpty() {
+			this.DeleteAskLimit(this.Asks.Min())
+		}
+	}
+
+	book.listeners = this.listeners
+	*this = book
+
+	if this.listeners != nil {
+		for _, side := range []struct{
+			levels Side
+			bidOrAsk bool
+		}{{this.Bids, true}, {this.Asks, false}} {
+			this.walk(side.levels, side.bidOrAsk, func(limit *LimitOrder) bool {
+				for o := limit.Front(); o != nil; o = limit.Next(o) {
+					this.emit(EventAdd, side.bidOrAsk, limit.Price, o.Id, o.Volume)
+				}
+				return true
+			})
+		}
+	}
+}
+
+type snapshotReader struct {
+	data []byte
+	pos int
+	err error
+}
+
+func (r *snapshotReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		r.err = fmt.Errorf("%w: truncated at %d", ErrInvalidSnapshot, r.pos)
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+func (r *snapshotReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data[r.pos:])
+	if n <= 0 {
+		r.err = fmt.Errorf("%w: truncated at %d", ErrInvalidSnapshot, r.pos)
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+func (r *snapshotReader) float64() float64 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.data) - r.pos < 8 {
+		r.err = fmt.Errorf("%w: truncated at %d", ErrInvalidSnapshot, r.pos)
+		return 0
+	}
+	v := math.Float64frombits(binary.LittleEndian.Uint64(r.data[r.pos:]))
+	r.pos += 8
+	return v
+}
+
+func (r *snapshotReader) readSide(book *Orderbook, bidOrAsk bool) error {
+	levels := int(r.uvarint())
+	if r.err != nil {
+		return r.err
+	}
+	// every level takes at least 9 bytes, guards allocations on corrupted input
+	if levels > (len(r.data) - r.pos) / 9 {
+		return fmt.Errorf("%w: too many levels %d", ErrInvalidSnapshot, levels)
+	}
+
+	// orders are allocated once per level
+	prices := make([]float64, 0, levels)
+	limits := make([]*LimitOrder, 0, levels)
+	for i := 0; i < levels; i += 1 {
+		price := r.float64()
+		count := int(r.uvarint())
+		if r.err != nil {
+			return r.err
+		}
+		// every order takes at least 9 bytes, cleared limits stay in the book with none
+		if count > (len(r.data) - r.pos) / 9 {
+			return fmt.Errorf("%w: invalid orders count %d", ErrInvalidSnapshot, count)
+		}
+
+		limit := book.limits.get()
+		if limit == nil {
+			return ErrLimitsExhausted
+		}
+		limit.Price = price
+		orders := make([]Order, count)
+		for j := range orders {
+			o := &orders[j]
+			o.Id = int(r.varint())
+			o.Volume = r.float64()
+			o.BidOrAsk = bidOrAsk
+			limit.Enqueue(o)
+		}
+		if r.err != nil {
+			return r.err
+		}
+		prices = append(prices, price)
+		limits = append(limits, limit)
+	}
+
+	if err := book.load(prices, limits, bidOrAsk); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+	}
+	return nil
+}
+
+// price level with its orders in FIFO order
+type LevelOrders struct {
+	Price float64
+	Orders []*Order
+}
+
+// book holding the levels, given best first on each side as the L2 and L3
+// views list them: bids in descending and asks in ascending price order.
+// The orders are linked into the book as they are and the red-black sides
+// are built in O(N) instead of N adds, no events are emitted.
+func NewOrderbookFromLevels(config OrderbookConfig, bids, asks []LevelOrders) (Orderbook, error) {
+	book := NewOrderbookWithConfig(config)
+	for _, side := range []struct{
+		levels []LevelOrders
+		bidOrAsk bool
+	}{{bids, true}, {asks, false}} {
+		prices := make([]float64, len(side.levels))
+		limits := make([]*LimitOrder, len(side.levels))
+		for i, l := range side.levels {
+			limit := book.limits.get()
+			if limit == nil {
+				return book, ErrLimitsExhausted
+			}
+			limit.Price = l.Price
+			for _, o := range l.Orders {
+				o.BidOrAsk = side.bidOrAsk
+				limit.Enqueue(o)
+			}
+
+			// load takes the levels in ascending order
+			j := i
+			if side.bidOrAsk {
+				j = len(side.levels) - 1 - i
+			}
+			prices[j], limits[j] = l.Price, limit
+		}
+
+		if err := book.load(prices, limits, side.bidOrAsk); err != nil {
+			return book, err
+		}
+	}
+	return book, nil
+}
+
+// fills the empty side with limits in ascending price order, the red-black
+// sides are built in O(N) instead of N puts
+func (this *Orderbook) load(prices []float64, limits []*LimitOrder, bidOrAsk bool) error {
+	for i := 1; i < len(prices); i += 1 {
+		if !(prices[i - 1] < prices[i]) {
+			return ErrUnsortedLevels
+		}
+	}
+	if this.config.MaxLevels > 0 && len(limits) > this.config.MaxLevels {
+		return ErrOutOfWindow
+	}
+
+	cache := this.askLimitsCache
+	side := this.Asks
+	if bidOrAsk {
+		cache = this.bidLimitsCache
+		side = this.Bids
+	}
+
+	tree, ok := side.(*redBlackBST)
+	if ok {
+		// the subtree volumes are summed while building
+		tree.Load(prices, limits)
+	}
+	for i, limit := range limits {
+		if !ok {
+			if err := this.checkPrice(prices[i], bidOrAsk); err != nil {
+				return err
+			}
+			// prices next to each other may snap to the same level
+			limit.Price = this.snapPrice(prices[i], bidOrAsk)
+			if side.Contains(limit.Price) {
+				return ErrUnsortedLevels
+			}
+			side.Put(limit.Price, limit)
+		}
+		cache[limit.Price] = limit
+		if this.config.Versions {
+			this.refreshVersion(limit, bidOrAsk)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,326 @@
+package hftorderbook
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func randomOrderbook(levels, orders int) Orderbook {
+	book := NewOrderbook()
+
+	prices := make([]float64, levels)
+	for i := range prices {
+		prices[i] = rand.Float64()
+	}
+
+	for i := 0; i < orders; i += 1 {
+		price := prices[rand.Intn(len(prices))]
+		book.Add(price, &Order{
+			Id: i,
+			Volume: rand.Float64(),
+			BidOrAsk: price < 0.5,
+		})
+	}
+	return book
+}
+
+// checks both books have the same levels and the same orders in the same FIFO order
+func sameOrderbooks(t *testing.T, a, b *Orderbook) {
+	for _, sides := range [][2]Side{{a.Bids, b.Bids}, {a.Asks, b.Asks}} {
+		if sides[0].Size() != sides[1].Size() {
+			t.Fatalf("sides have different number of levels %d != %d", sides[0].Size(), sides[1].Size())
+		}
+		if sides[0].IsEmpty() {
+			continue
+		}
+
+		x, y := sides[0].MinValue(), sides[1].MinValue()
+		for ; x != nil; x, y = sides[0].Next(x.Price), sides[1].Next(y.Price) {
+			if y == nil || x.Price != y.Price || x.Size() != y.Size() || x.TotalVolume() != y.TotalVolume() {
+				t.Fatalf("levels at %0.8f differ", x.Price)
+			}
+
+			o, p := x.Front(), y.Front()
+			for i := 0; i < x.Size(); i += 1 {
+				if o.Id != p.Id || o.Volume != p.Volume || o.BidOrAsk != p.BidOrAsk || p.Limit != y {
+					t.Fatalf("orders %d and %d differ at level %0.8f", o.Id, p.Id, x.Price)
+				}
+				o, p = x.Next(o), y.Next(p)
+			}
+		}
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	book := randomOrderbook(1000, 10000)
+	data, err := book.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var restored Orderbook
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	sameOrderbooks(t, &book, &restored)
+
+	if restored.BLength() != book.BLength() || restored.ALength() != book.ALength() {
+		t.Errorf("limits cache should be restored")
+	}
+	if !restored.Bids.(*redBlackBST).IsRedBlack() || !restored.Asks.(*redBlackBST).IsRedBlack() {
+		t.Errorf("restored trees should be balanced")
+	}
+	if restored.GetBestBid() != book.GetBestBid() || restored.GetBestOffer() != book.GetBestOffer() {
+		t.Errorf("best prices should be restored")
+	}
+
+	// restored book should be fully functional
+	bid := &Order{Id: -1, Volume: 1, BidOrAsk: true}
+	restored.Add(restored.GetBestBid(), bid)
+	restored.Cancel(bid)
+	for restored.BLength() > 0 {
+		restored.DeleteBidLimit(restored.GetBestBid())
+	}
+}
+
+// cleared limits stay in the book as empty levels
+func TestSnapshotClearedLimits(t *testing.T) {
+	book := randomOrderbook(100, 1000)
+	book.ClearBidLimit(book.GetBestBid())
+	book.ClearAskLimit(book.GetBestOffer())
+	data, _ := book.MarshalBinary()
+
+	var restored Orderbook
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	sameOrderbooks(t, &book, &restored)
+
+	bid := &Order{Id: -1, Volume: 1, BidOrAsk: true}
+	restored.Add(restored.GetBestBid(), bid)
+	if restored.GetVolumeAtBidLimit(restored.GetBestBid()) != 1 {
+		t.Errorf("cleared limit should take new orders")
+	}
+}
+
+// an order added again after its limit was cleared does not bring back
+// the links of the cleared queue
+func TestSnapshotClearedLimitReAdd(t *testing.T) {
+	book := NewOrderbook()
+	a := &Order{Id: 1, Volume: 1, BidOrAsk: true}
+	b := &Order{Id: 2, Volume: 2, BidOrAsk: true}
+	book.Add(1, a)
+	book.Add(1, b)
+	book.ClearBidLimit(1)
+	book.Add(1, a)
+
+	limit := book.bidLimitsCache[1]
+	count := 0
+	for o := limit.Front(); o != nil; o = limit.Next(o) {
+		count += 1
+	}
+	if count != 1 || limit.Size() != 1 {
+		t.Fatalf("expected 1 order at the level, walked %d of %d", count, limit.Size())
+	}
+
+	data, _ := book.MarshalBinary()
+	var restored Orderbook
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	sameOrderbooks(t, &book, &restored)
+}
+
+// views and journals subscribed before a restore follow the restored book
+func TestSnapshotRestoreListeners(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.journal")
+	journal, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	book := randomOrderbook(50, 500)
+	view := NewBucketView(&book, 0.25)
+	initial, _ := book.MarshalBinary()
+	journal.Attach(&book)
+
+	snapshot := randomOrderbook(100, 1000)
+	data, _ := snapshot.MarshalBinary()
+	if err := book.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	sameBuckets(t, view, &book, true)
+	sameBuckets(t, view, &book, false)
+
+	snapshot = randomOrderbook(80, 800)
+	data, _ = json.Marshal(&snapshot)
+	if err := json.Unmarshal(data, &book); err != nil {
+		t.Fatal(err)
+	}
+	book.Add(0.25, &Order{Id: -1, Volume: 1, BidOrAsk: true})
+	sameBuckets(t, view, &book, true)
+	sameBuckets(t, view, &book, false)
+
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	replayed, _, err := ReplayJournal(initial, 0, f, OrderbookConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameOrderbooks(t, &book, &replayed)
+}
+
+// levels of the book best first with copies of the orders
+func levelOrdersOf(book *Orderbook) (bids, asks []LevelOrders) {
+	for _, side := range []struct{
+		levels Side
+		bidOrAsk bool
+		result *[]LevelOrders
+	}{{book.Bids, true, &bids}, {book.Asks, false, &asks}} {
+		book.walk(side.levels, side.bidOrAsk, func(limit *LimitOrder) bool {
+			l := LevelOrders{Price: limit.Price}
+			for o := limit.Front(); o != nil; o = limit.Next(o) {
+				l.Orders = append(l.Orders, &Order{Id: o.Id, Volume: o.Volume})
+			}
+			*side.result = append(*side.result, l)
+			return true
+		})
+	}
+	return bids, asks
+}
+
+func TestOrderbookFromLevels(t *testing.T) {
+	book := randomOrderbook(100, 1000)
+	for _, config := range []OrderbookConfig{{}, {Depth: true, Versions: true}} {
+		bids, asks := levelOrdersOf(&book)
+		built, err := NewOrderbookFromLevels(config, bids, asks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sameOrderbooks(t, &book, &built)
+
+		// the given orders rest in the book
+		o := bids[0].Orders[0]
+		if o.Limit == nil || !o.BidOrAsk {
+			t.Fatalf("order should be linked into the book")
+		}
+		volume := built.GetVolumeAtBidLimit(o.Limit.Price)
+		built.Cancel(o)
+		if built.GetVolumeAtBidLimit(bids[0].Price) != volume - o.Volume {
+			t.Errorf("order should be cancelled from its level")
+		}
+	}
+
+	// empty levels are kept
+	built, err := NewOrderbookFromLevels(OrderbookConfig{}, []LevelOrders{{Price: 1}}, nil)
+	if err != nil || built.BLength() != 1 || built.GetVolumeAtBidLimit(1) != 0 {
+		t.Errorf("empty level should be kept, %v", err)
+	}
+
+	if _, err := NewOrderbookFromLevels(OrderbookConfig{}, nil, []LevelOrders{{Price: 2}, {Price: 1}}); !errors.Is(err, ErrUnsortedLevels) {
+		t.Errorf("expected unsorted levels error, got %v", err)
+	}
+	if _, err := NewOrderbookFromLevels(OrderbookConfig{MaxLevels: 1}, nil, []LevelOrders{{Price: 1}, {Price: 2}}); !errors.Is(err, ErrOutOfWindow) {
+		t.Errorf("expected out of window error, got %v", err)
+	}
+}
+
+func TestSnapshotEmpty(t *testing.T) {
+	book := NewOrderbook()
+	data, _ := book.MarshalBinary()
+
+	restored := randomOrderbook(10, 100)
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if restored.BLength() != 0 || restored.ALength() != 0 {
+		t.Errorf("restored book should be empty")
+	}
+}
+
+func TestSnapshotInvalid(t *testing.T) {
+	book := randomOrderbook(10, 100)
+	data, _ := book.MarshalBinary()
+
+	var restored Orderbook
+	for _, bad := range [][]byte{
+		nil,
+		[]byte("HFOB\x02"),
+		data[:len(data)-1],
+		append(append([]byte{}, data...), 0),
+	} {
+		if err := restored.UnmarshalBinary(bad); !errors.Is(err, ErrInvalidSnapshot) {
+			t.Errorf("invalid snapshot should be rejected, got %v", err)
+		}
+	}
+}
+
+// bulk loaded sides keep the subtree volumes and the versions
+func TestSnapshotDepthVersions(t *testing.T) {
+	book := NewOrderbookWithConfig(OrderbookConfig{Depth: true, Versions: true})
+	source := randomOrderbook(1000, 5000)
+	data, _ := source.MarshalBinary()
+	if err := book.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	sameOrderbooks(t, &source, &book)
+
+	for i := 0; i < 100; i += 1 {
+		lo, hi := rand.Float64(), rand.Float64()
+		volume, count := 0.0, 0
+		for _, limit := range source.bidLimitsCache {
+			if limit.Price >= lo && limit.Price <= hi {
+				volume += limit.TotalVolume()
+				count += limit.Size()
+			}
+		}
+		if v, c := book.GetBidVolumeBetween(lo, hi); math.Abs(v - volume) > 1e-9 || c != count {
+			t.Fatalf("bids [%0.4f, %0.4f]: expected %0.4f in %d orders, got %0.4f in %d", lo, hi, volume, count, v, c)
+		}
+	}
+
+	version := book.Version()
+	if !reflect.DeepEqual(version.L2(0), source.L2(0)) {
+		t.Errorf("version should have the loaded levels")
+	}
+}
+
+func BenchmarkSnapshotMarshal10kLevels(b *testing.B) {
+	book := randomOrderbook(10000, 100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		book.MarshalBinary()
+	}
+}
+
+func benchmarkSnapshotUnmarshal(levels int, b *testing.B) {
+	book := randomOrderbook(levels, 10 * levels)
+	data, _ := book.MarshalBinary()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		var restored Orderbook
+		if err := restored.UnmarshalBinary(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSnapshotUnmarshal10kLevels(b *testing.B) {
+	benchmarkSnapshotUnmarshal(10000, b)
+}
+
+func BenchmarkSnapshotUnmarshal20kLevels(b *testing.B) {
+	benchmarkSnapshotUnmarshal(20000, b)
+}
@@ -0,0 +1,126 @@
+package hftorderbook
+
+import (
+	"sync"
+)
+
+// Orderbook safe for one writer and many concurrent readers,
+// all methods take the book lock, released even if the book panics
+type SyncOrderbook struct {
+	mu sync.RWMutex
+	book Orderbook
+}
+
+func NewSyncOrderbook() *SyncOrderbook {
+	return &SyncOrderbook{
+		book: NewOrderbook(),
+	}
+}
+
+// runs fn holding the write lock, for compound updates
+func (this *SyncOrderbook) Write(fn func(book *Orderbook)) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	fn(&this.book)
+}
+
+// runs fn holding the read lock, for consistent compound reads,
+// fn must not change the book
+func (this *SyncOrderbook) Read(fn func(book *Orderbook)) {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	fn(&this.book)
+}
+
+func (this *SyncOrderbook) Add(price float64, o *Order) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.book.Add(price, o)
+}
+
+func (this *SyncOrderbook) Cancel(o *Order) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.book.Cancel(o)
+}
+
+func (this *SyncOrderbook) Reduce(o *Order, volume float64) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.book.Reduce(o, volume)
+}
+
+func (this *SyncOrderbook) ClearBidLimit(price float64) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.book.ClearBidLimit(price)
+}
+
+func (this *SyncOrderbook) ClearAskLimit(price float64) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.book.ClearAskLimit(price)
+}
+
+func (this *SyncOrderbook) DeleteBidLimit(price float64) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.book.DeleteBidLimit(price)
+}
+
+func (this *SyncOrderbook) DeleteAskLimit(price float64) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.book.DeleteAskLimit(price)
+}
+
+func (this *SyncOrderbook) GetVolumeAtBidLimit(price float64) float64 {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.book.GetVolumeAtBidLimit(price)
+}
+
+func (this *SyncOrderbook) GetVolumeAtAskLimit(price float64) float64 {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.book.GetVolumeAtAskLimit(price)
+}
+
+// best bid and false if there are no bids
+func (this *SyncOrderbook) GetBestBid() (float64, bool) {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	if this.book.Bids.IsEmpty() {
+		return 0, false
+	}
+	return this.book.GetBestBid(), true
+}
+
+// best offer and false if there are no asks
+func (this *SyncOrderbook) GetBestOffer() (float64, bool) {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	if this.book.Asks.IsEmpty() {
+		return 0, false
+	}
+	return this.book.GetBestOffer(), true
+}
+
+func (this *SyncOrderbook) BLength() int {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.book.BLength()
+}
+
+func (this *SyncOrderbook) ALength() int {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.book.ALength()
+}
+
+// consistent copy of the top depth levels of both sides
+func (this *SyncOrderbook) L2(depth int) L2Book {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.book.L2(depth)
+}
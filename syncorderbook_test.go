@@ -0,0 +1,125 @@
+package hftorderbook
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSyncOrderbookConcurrentReaders(t *testing.T) {
+	book := NewSyncOrderbook()
+	stop := make(chan struct{})
+
+	// the writer keeps the book uncrossed: bids below 0.5, asks above
+	var writes sync.WaitGroup
+	writes.Add(1)
+	go func() {
+		defer writes.Done()
+		live := make([]*Order, 0)
+		for i := 0; i < 20000; i += 1 {
+			if len(live) > 100 && rand.Intn(2) == 0 {
+				k := rand.Intn(len(live))
+				book.Cancel(live[k])
+				live[k] = live[len(live)-1]
+				live = live[:len(live)-1]
+				continue
+			}
+
+			price := float64(rand.Intn(100)) / 100
+			o := &Order{Id: i, Volume: 1, BidOrAsk: price < 0.5}
+			book.Add(price, o)
+			live = append(live, o)
+		}
+		close(stop)
+	}()
+
+	var reads sync.WaitGroup
+	var crossed int32
+	for r := 0; r < 4; r += 1 {
+		reads.Add(1)
+		go func() {
+			defer reads.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				bid, hasBid := book.GetBestBid()
+				ask, hasAsk := book.GetBestOffer()
+				if hasBid && hasAsk && bid >= ask {
+					atomic.StoreInt32(&crossed, 1)
+				}
+				book.GetVolumeAtBidLimit(bid)
+
+				book.Read(func(b *Orderbook) {
+					if b.BLength() != b.Bids.Size() {
+						atomic.StoreInt32(&crossed, 1)
+					}
+				})
+				book.L2(10)
+			}
+		}()
+	}
+
+	writes.Wait()
+	reads.Wait()
+	if crossed != 0 {
+		t.Errorf("readers should see a consistent book")
+	}
+}
+
+func TestSyncOrderbookUnlockOnPanic(t *testing.T) {
+	book := NewSyncOrderbook()
+	func() {
+		defer func() {
+			recover()
+		}()
+		book.ClearBidLimit(1.0)
+	}()
+
+	// the lock should be released
+	book.Add(1.0, &Order{Id: 1, Volume: 1, BidOrAsk: true})
+	if book.BLength() != 1 {
+		t.Errorf("book should be usable after a panic")
+	}
+}
+
+// readers of the top of book in parallel with a writer, run with -cpu 1,2,4,8
+func BenchmarkSyncOrderbookParallelRead(b *testing.B) {
+	book := NewSyncOrderbook()
+	for i := 0; i < 10000; i += 1 {
+		price := rand.Float64()
+		book.Add(price, &Order{Id: i, Volume: 1, BidOrAsk: price < 0.5})
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		o := &Order{Id: -1, Volume: 1, BidOrAsk: true}
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			book.Add(0.25, o)
+			book.Cancel(o)
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			book.GetBestBid()
+			book.GetBestOffer()
+		}
+	})
+	b.StopTimer()
+
+	close(stop)
+	<-done
+}
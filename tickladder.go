@@ -0,0 +1,422 @@
+package hftorderbook
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Price levels in an array indexed by the tick offset from the band base,
+// put, get and delete are O(1), min/max are kept by cursors. A bitmap of the
+// occupied levels finds the next one when a cursor level is deleted and
+// serves floor, ceiling and the walks in near-constant time however sparse
+// the band is. Prices out of the band re-center it, the band grows if the
+// levels do not fit, up to the max band width.
+
+// default max band width, 32MB of levels
+const MaxTickLadderLevels = 1 << 22
+
+var (
+	ErrOffTick = errors.New("price is not a multiple of the tick")
+	ErrOutOfBand = errors.New("price is out of the max tick ladder band")
+)
+
+type tickLadder struct {
+	tick float64
+	perUnit float64 // ticks per price unit if whole, 0 otherwise
+	base int64 // price of levels[0] in ticks
+	levels []*LimitOrder
+	maxLevels int // max band width
+	occupied levelBitmap
+	size int
+	min int // cursors to the occupied extremes, valid if size > 0
+	max int
+}
+
+// levels is the initial band width in ticks
+func NewTickLadder(tick float64, levels int) tickLadder {
+	return NewTickLadderWithMax(tick, levels, max(levels, MaxTickLadderLevels))
+}
+
+// the band never grows wider than maxLevels ticks, puts of farther prices panic
+// and CheckPrice reports them with ErrOutOfBand
+func NewTickLadderWithMax(tick float64, levels, maxLevels int) tickLadder {
+	if tick <= 0 || levels <= 0 || maxLevels < levels {
+		panic("tick and levels should be positive, max levels should be at least levels")
+	}
+	perUnit := math.Round(1 / tick)
+	if math.Abs(1 / tick - perUnit) > tickTolerance {
+		perUnit = 0
+	}
+	return tickLadder{
+		tick: tick,
+		perUnit: perUnit,
+		levels: make([]*LimitOrder, levels),
+		maxLevels: maxLevels,
+		occupied: newLevelBitmap(levels),
+	}
+}
+
+func (t *tickLadder) Size() int {
+	return t.size
+}
+
+func (t *tickLadder) IsEmpty() bool {
+	return t.size == 0
+}
+
+func (t *tickLadder) panicIfEmpty() {
+	if t.IsEmpty() {
+		panic("Tick ladder is empty")
+	}
+}
+
+// prices within the tolerance (in ticks) of a grid price are on it
+const tickTolerance = 1e-6
+
+// price in ticks, panics if the price is off the tick grid
+func (t *tickLadder) ticks(price float64) int64 {
+	if err := t.checkTick(price); err != nil {
+		panic(err.Error())
+	}
+	return int64(math.Round(price / t.tick))
+}
+
+// band offset of the grid price at or below (above if up) any price,
+// clamped so that far prices stay out of the band
+func (t *tickLadder) offset(price float64, up bool) int64 {
+	x := math.Floor(price / t.tick + tickTolerance)
+	if up {
+		x = math.Ceil(price / t.tick - tickTolerance)
+	}
+	if x > 1 << 52 {
+		x = 1 << 52
+	} else if !(x > -(1 << 52)) {
+		x = -(1 << 52)
+	}
+	return int64(x) - t.base
+}
+
+func (t *tickLadder) checkTick(price float64) error {
+	x := price / t.tick
+	// negated to reject NaN and infinities
+	if !(math.Abs(x - math.Round(x)) <= tickTolerance) {
+		return fmt.Errorf("%w: %0.8f, tick %0.8f", ErrOffTick, price, t.tick)
+	}
+	if math.Abs(x) >= 1 << 52 {
+		return fmt.Errorf("%w: %0.8f", ErrOutOfBand, price)
+	}
+	return nil
+}
+
+// ErrOffTick or ErrOutOfBand if the price can not be put
+func (t *tickLadder) CheckPrice(price float64) error {
+	if err := t.checkTick(price); err != nil {
+		return err
+	}
+	if t.index(price) < 0 {
+		if lo, hi := t.span(t.ticks(price)); hi - lo + 1 > int64(t.maxLevels) {
+			return fmt.Errorf("%w: %0.8f", ErrOutOfBand, price)
+		}
+	}
+	return nil
+}
+
+// grid price the book keys the level by, prices off the grid are kept as is.
+// Dividing by whole ticks per unit gives the nearest float to the decimal
+// price, the same float as its literal.
+func (t *tickLadder) SnapPrice(price float64) float64 {
+	if t.checkTick(price) != nil {
+		return price
+	}
+	if t.perUnit > 0 {
+		return float64(t.ticks(price)) / t.perUnit
+	}
+	return float64(t.ticks(price)) * t.tick
+}
+
+// array index of the price, -1 if out of the band or off the grid
+func (t *tickLadder) index(price float64) int {
+	if t.checkTick(price) != nil {
+		return -1
+	}
+	i := t.ticks(price) - t.base
+	if i < 0 || i >= int64(len(t.levels)) {
+		return -1
+	}
+	return int(i)
+}
+
+func (t *tickLadder) Contains(key float64) bool {
+	i := t.index(key)
+	return i >= 0 && t.levels[i] != nil
+}
+
+// nil if there is no such level
+func (t *tickLadder) value(key float64) *LimitOrder {
+	i := t.index(key)
+	if i < 0 {
+		return nil
+	}
+	return t.levels[i]
+}
+
+func (t *tickLadder) Get(key float64) *LimitOrder {
+	t.panicIfEmpty()
+
+	i := t.index(key)
+	if i < 0 || t.levels[i] == nil {
+		panic(fmt.Sprintf("key %0.8f does not exist", key))
+	}
+	return t.levels[i]
+}
+
+func (t *tickLadder) Put(key float64, value *LimitOrder) {
+	i := t.index(key)
+	if i < 0 {
+		t.recenter(t.ticks(key))
+		i = t.index(key)
+	}
+
+	if t.levels[i] == nil {
+		t.occupied.set(i)
+		t.size++
+		if t.size == 1 {
+			t.min, t.max = i, i
+		} else if i < t.min {
+			t.min = i
+		} else if i > t.max {
+			t.max = i
+		}
+	}
+	t.levels[i] = value
+}
+
+// ticks range of the occupied levels and the price
+func (t *tickLadder) span(ticks int64) (lo, hi int64) {
+	lo, hi = ticks, ticks
+	if t.size > 0 {
+		if first := t.base + int64(t.min); first < lo {
+			lo = first
+		}
+		if last := t.base + int64(t.max); last > hi {
+			hi = last
+		}
+	}
+	return lo, hi
+}
+
+// moves the band so that the occupied levels and the new price are in
+// the middle of it, doubling the band while they do not fit
+func (t *tickLadder) recenter(ticks int64) {
+	lo, hi := t.span(ticks)
+	if hi - lo + 1 > int64(t.maxLevels) {
+		panic(fmt.Sprintf("%s: %d ticks wide", ErrOutOfBand, hi - lo + 1))
+	}
+
+	width := int64(len(t.levels))
+	for hi - lo + 1 > width {
+		width *= 2
+	}
+	if width > int64(t.maxLevels) {
+		width = int64(t.maxLevels)
+	}
+	base := lo - (width - (hi - lo + 1)) / 2
+
+	if t.size == 0 {
+		t.base = base
+		if width != int64(len(t.levels)) {
+			t.levels = make([]*LimitOrder, width)
+			t.occupied = newLevelBitmap(int(width))
+		}
+		return
+	}
+
+	start := int(t.base + int64(t.min) - base)
+	count := t.max - t.min + 1
+	if width != int64(len(t.levels)) {
+		levels := make([]*LimitOrder, width)
+		copy(levels[start:], t.levels[t.min:t.max + 1])
+		t.levels = levels
+	} else {
+		copy(t.levels[start:], t.levels[t.min:t.max + 1])
+		// clearing the old slots not covered by the moved levels
+		for i := t.min; i <= t.max; i++ {
+			if i < start || i >= start + count {
+				t.levels[i] = nil
+			}
+		}
+	}
+
+	t.base = base
+	t.min = start
+	t.max = start + count - 1
+
+	// re-indexing the moved levels
+	if len(t.occupied.layers[0]) * 64 < len(t.levels) {
+		t.occupied = newLevelBitmap(len(t.levels))
+	} else {
+		t.occupied.reset()
+	}
+	for i := t.min; i <= t.max; i++ {
+		if t.levels[i] != nil {
+			t.occupied.set(i)
+		}
+	}
+}
+
+func (t *tickLadder) Delete(key float64) {
+	t.panicIfEmpty()
+
+	i := t.index(key)
+	if i < 0 || t.levels[i] == nil {
+		// search miss
+		return
+	}
+
+	t.levels[i] = nil
+	t.occupied.clear(i)
+	t.size--
+	if t.size == 0 {
+		return
+	}
+
+	// moving the cursors to the next occupied levels
+	if i == t.min {
+		t.min = t.occupied.next(i)
+	}
+	if i == t.max {
+		t.max = t.occupied.prev(i)
+	}
+}
+
+func (t *tickLadder) Min() float64 {
+	t.panicIfEmpty()
+	return t.levels[t.min].Price
+}
+
+func (t *tickLadder) MinValue() *LimitOrder {
+	t.panicIfEmpty()
+	return t.levels[t.min]
+}
+
+func (t *tickLadder) Max() float64 {
+	t.panicIfEmpty()
+	return t.levels[t.max].Price
+}
+
+func (t *tickLadder) MaxValue() *LimitOrder {
+	t.panicIfEmpty()
+	return t.levels[t.max]
+}
+
+// index of the greatest level <= key, -1 if there is none
+func (t *tickLadder) floor(key float64) int {
+	return t.before(t.offset(key, false))
+}
+
+// index of the least level >= key, -1 if there is none
+func (t *tickLadder) ceiling(key float64) int {
+	return t.after(t.offset(key, true))
+}
+
+// index of the greatest level at or before the band offset i, -1 if there is none
+func (t *tickLadder) before(i int64) int {
+	if t.size == 0 || i < int64(t.min) {
+		return -1
+	}
+	if i > int64(t.max) {
+		return t.max
+	}
+	return t.occupied.prev(int(i))
+}
+
+// index of the least level at or after the band offset i, -1 if there is none
+func (t *tickLadder) after(i int64) int {
+	if t.size == 0 || i > int64(t.max) {
+		return -1
+	}
+	if i < int64(t.min) {
+		return t.min
+	}
+	return t.occupied.next(int(i))
+}
+
+func (t *tickLadder) Floor(key float64) float64 {
+	t.panicIfEmpty()
+
+	i := t.floor(key)
+	if i < 0 {
+		panic(fmt.Sprintf("there are no keys <= %0.8f", key))
+	}
+	return t.levels[i].Price
+}
+
+func (t *tickLadder) Ceiling(key float64) float64 {
+	t.panicIfEmpty()
+
+	i := t.ceiling(key)
+	if i < 0 {
+		panic(fmt.Sprintf("there are no keys >= %0.8f", key))
+	}
+	return t.levels[i].Price
+}
+
+// value of the least key > key, nil if there is none
+func (t *tickLadder) Next(key float64) *LimitOrder {
+	i := t.after(t.offset(key, false) + 1)
+	if i < 0 {
+		return nil
+	}
+	return t.levels[i]
+}
+
+// value of the greatest key < key, nil if there is none
+func (t *tickLadder) Prev(key float64) *LimitOrder {
+	i := t.before(t.offset(key, true) - 1)
+	if i < 0 {
+		return nil
+	}
+	return t.levels[i]
+}
+
+func (t *tickLadder) Range(lo, hi float64, fn func(value *LimitOrder) bool) {
+	last := t.floor(hi)
+	for i := t.ceiling(lo); i >= 0 && i <= last; i = t.occupied.next(i + 1) {
+		if !fn(t.levels[i]) {
+			return
+		}
+	}
+}
+
+// follows the occupied bitmap from the cursors
+func (t *tickLadder) Walk(descending bool, fn func(value *LimitOrder) bool) {
+	if t.size == 0 {
+		return
+	}
+	i := t.min
+	if descending {
+		i = t.max
+	}
+	for i >= 0 && fn(t.levels[i]) {
+		if descending {
+			i = t.occupied.prev(i - 1)
+		} else {
+			i = t.occupied.next(i + 1)
+		}
+	}
+}
+
+// keys between lo and hi in ascending order
+func (t *tickLadder) Keys(lo, hi float64) []float64 {
+	if lo < t.Min() || hi > t.Max() {
+		panic("keys out of range")
+	}
+
+	keys := make([]float64, 0)
+	t.Range(lo, hi, func(l *LimitOrder) bool {
+		keys = append(keys, l.Price)
+		return true
+	})
+	return keys
+}
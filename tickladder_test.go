@@ -0,0 +1,248 @@
+package hftorderbook
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestTickLadderEmpty(t *testing.T) {
+	l := NewTickLadder(0.01, 16)
+	if l.Size() != 0 || !l.IsEmpty() || l.Contains(1.0) {
+		t.Errorf("Tick ladder should be empty")
+	}
+}
+
+func TestTickLadderBasic(t *testing.T) {
+	l := NewTickLadder(0.5, 8)
+	for _, price := range []float64{10.0, 9.5, 12.0, 11.0} {
+		limit := NewLimitOrder(price)
+		l.Put(price, &limit)
+	}
+
+	if l.Size() != 4 || l.Min() != 9.5 || l.Max() != 12.0 {
+		t.Errorf("invalid ladder size or extremes")
+	}
+	if l.Get(11.0).Price != 11.0 || !l.Contains(10.0) || l.Contains(10.5) {
+		t.Errorf("invalid ladder lookup")
+	}
+	if l.Floor(11.5) != 11.0 || l.Ceiling(10.5) != 11.0 || l.Floor(100) != 12.0 || l.Ceiling(1) != 9.5 {
+		t.Errorf("invalid floor or ceiling")
+	}
+	keys := l.Keys(9.5, 11.0)
+	if len(keys) != 3 || keys[0] != 9.5 || keys[1] != 10.0 || keys[2] != 11.0 {
+		t.Errorf("invalid keys %v", keys)
+	}
+
+	l.Delete(9.5)
+	l.Delete(12.0)
+	l.Delete(12.5) // search miss
+	if l.Size() != 2 || l.Min() != 10.0 || l.Max() != 11.0 {
+		t.Errorf("cursors should move to the next levels, got %0.2f %0.2f", l.Min(), l.Max())
+	}
+}
+
+func TestTickLadderRecenter(t *testing.T) {
+	l := NewTickLadder(1, 4)
+	for _, price := range []float64{100, 101, 102, 103} {
+		limit := NewLimitOrder(price)
+		l.Put(price, &limit)
+	}
+
+	// the band moves
+	l.Delete(100)
+	l.Delete(101)
+	limit := NewLimitOrder(105)
+	l.Put(105, &limit)
+	if len(l.levels) != 4 || l.Min() != 102 || l.Max() != 105 || l.Size() != 3 {
+		t.Errorf("band should move without growing")
+	}
+
+	// the band grows
+	low := NewLimitOrder(90)
+	l.Put(90, &low)
+	if len(l.levels) != 16 || l.Min() != 90 || l.Max() != 105 || l.Get(103).Price != 103 {
+		t.Errorf("band should grow to fit the levels")
+	}
+}
+
+func TestTickLadderRandom(t *testing.T) {
+	l := NewTickLadder(0.01, 64)
+	prices := make(map[float64]bool)
+	for i := 0; i < 1000; i += 1 {
+		price := float64(rand.Intn(2000) - 1000) / 100
+		if rand.Intn(3) == 0 && len(prices) > 0 {
+			for p := range prices {
+				l.Delete(p)
+				delete(prices, p)
+				break
+			}
+			continue
+		}
+		limit := NewLimitOrder(price)
+		l.Put(price, &limit)
+		prices[price] = true
+	}
+
+	keys := make([]float64, 0, len(prices))
+	for p := range prices {
+		keys = append(keys, p)
+	}
+	sort.Float64s(keys)
+	if l.Size() != len(keys) || l.Min() != keys[0] || l.Max() != keys[len(keys) - 1] {
+		t.Errorf("ladder is out of sync with the levels")
+	}
+	all := l.Keys(l.Min(), l.Max())
+	for i := range keys {
+		if all[i] != keys[i] {
+			t.Errorf("expected key %0.2f, got %0.2f", keys[i], all[i])
+			break
+		}
+	}
+}
+
+func TestTickLadderOffTick(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("price off the tick grid should panic")
+		}
+	}()
+	l := NewTickLadder(0.5, 4)
+	limit := NewLimitOrder(1.25)
+	l.Put(1.25, &limit)
+}
+
+// prices within float error of a tick share one level in the book, lookups
+// of prices off the grid miss instead of panicking
+func TestTickLadderSnapping(t *testing.T) {
+	book := NewOrderbookWithConfig(OrderbookConfig{
+		NewSide: func(bidOrAsk bool) Side {
+			l := NewTickLadder(0.01, 16)
+			return &l
+		},
+	})
+	a, b := 0.1, 0.2
+	sum := a + b
+	x := &Order{Id: 1, Volume: 1, BidOrAsk: true}
+	y := &Order{Id: 2, Volume: 2, BidOrAsk: true}
+	book.Add(0.3, x)
+	book.Add(sum, y)
+	if book.BLength() != 1 || book.Bids.Size() != 1 || book.GetVolumeAtBidLimit(0.3) != 3 {
+		t.Fatalf("expected one level of volume 3, got %d levels", book.BLength())
+	}
+	book.Cancel(y)
+	if book.Bids.Size() != 1 || book.GetVolumeAtBidLimit(sum) != 1 {
+		t.Errorf("the level should stay with the other order")
+	}
+	book.DeleteBidLimit(sum)
+	if book.BLength() != 0 || book.Bids.Size() != 0 {
+		t.Errorf("the level should be deleted")
+	}
+
+	l := NewTickLadder(0.5, 8)
+	for _, price := range []float64{1, 2, 3} {
+		limit := NewLimitOrder(price)
+		l.Put(price, &limit)
+	}
+	if l.Contains(1.25) || l.Next(1.25).Price != 2 || l.Prev(1.75).Price != 1 {
+		t.Errorf("off-grid lookups should miss or go to the next levels")
+	}
+	if l.Floor(2.9) != 2 || l.Ceiling(2.1) != 3 || l.Next(2).Price != 3 || l.Prev(2).Price != 1 {
+		t.Errorf("invalid floor, ceiling or neighbours")
+	}
+	count := 0
+	l.Range(1.1, 2.9, func(limit *LimitOrder) bool {
+		count += 1
+		return true
+	})
+	if count != 1 || l.Next(1e300) != nil || l.Prev(-1e300) != nil {
+		t.Errorf("invalid range or far lookups")
+	}
+}
+
+func TestTickLadderMaxBand(t *testing.T) {
+	book := NewOrderbookWithConfig(OrderbookConfig{
+		NewSide: func(bidOrAsk bool) Side {
+			l := NewTickLadderWithMax(0.01, 16, 1024)
+			return &l
+		},
+	})
+	book.Add(100, &Order{Id: 1, Volume: 1})
+	book.Add(105, &Order{Id: 2, Volume: 1})
+
+	// a fat finger price far away from the band and an off-tick price
+	if err := book.TryAdd(10000, &Order{Id: 3, Volume: 1}); !errors.Is(err, ErrOutOfBand) {
+		t.Errorf("far price should be rejected, got %v", err)
+	}
+	if err := book.TryAdd(100.005, &Order{Id: 4, Volume: 1}); !errors.Is(err, ErrOffTick) {
+		t.Errorf("off-tick price should be rejected, got %v", err)
+	}
+	if book.ALength() != 2 {
+		t.Errorf("rejected orders should not change the book")
+	}
+
+	// the band grows up to the max width only
+	if err := book.TryAdd(110.23, &Order{Id: 5, Volume: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if l := book.Asks.(*tickLadder); len(l.levels) > 1024 || l.Max() != 110.23 {
+		t.Errorf("band should stay within the max width, got %d", len(l.levels))
+	}
+}
+
+// same workload as benchmarkOrderbookLimitedRandomInsert with prices on a
+// 0.00001 tick grid, bids and asks on separate ladders
+func benchmarkTickLadderLimitedRandomInsert(n int, b *testing.B) {
+	tick := 0.00001
+	bids := NewTickLadder(tick, 50000)
+	asks := NewTickLadder(tick, 50000)
+
+	limitslist := make([]float64, n)
+	for i := range limitslist {
+		limitslist[i] = float64(rand.Intn(100000)) * tick
+	}
+
+	// preallocate empty orders
+	orders := make([]*Order, 0, b.N)
+	for i := 0; i < b.N; i += 1 {
+		orders = append(orders, &Order{})
+	}
+
+	// measure insertion time
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i += 1 {
+		price := limitslist[rand.Intn(len(limitslist))]
+
+		o := orders[i]
+		o.Id = i
+		o.Volume = rand.Float64()
+		o.BidOrAsk = price < 0.5
+
+		// the ladder is the limits cache
+		side := &asks
+		if o.BidOrAsk {
+			side = &bids
+		}
+		if limit := side.value(price); limit != nil {
+			limit.Enqueue(o)
+		} else {
+			l := NewLimitOrder(price)
+			l.Enqueue(o)
+			side.Put(price, &l)
+		}
+	}
+}
+
+func BenchmarkTickLadder5kLevelsRandomInsert(b *testing.B) {
+	benchmarkTickLadderLimitedRandomInsert(5000, b)
+}
+
+func BenchmarkTickLadder10kLevelsRandomInsert(b *testing.B) {
+	benchmarkTickLadderLimitedRandomInsert(10000, b)
+}
+
+func BenchmarkTickLadder20kLevelsRandomInsert(b *testing.B) {
+	benchmarkTickLadderLimitedRandomInsert(20000, b)
+}
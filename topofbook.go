@@ -0,0 +1,75 @@
+package hftorderbook
+
+import (
+	"math"
+	"runtime"
+	"sync/atomic"
+)
+
+// Best bid and offer with their volumes, zero volume means the side is empty
+type TopOfBook struct {
+	BidPrice float64
+	BidVolume float64
+	AskPrice float64
+	AskVolume float64
+	Seq uint64 // number of publications so far
+}
+
+// Top of book published by the single book writer and read by any number of
+// goroutines without locks: the sequence is odd while the writer updates the
+// fields, readers retry if it was odd or changed during their read
+type TopOfBookSeqlock struct {
+	_ cacheLinePad
+	seq atomic.Uint64
+	bidPrice atomic.Uint64
+	bidVolume atomic.Uint64
+	askPrice atomic.Uint64
+	askVolume atomic.Uint64
+	_ cacheLinePad
+}
+
+// stores the current top of the book, must be called by the book writer only
+func (this *TopOfBookSeqlock) Publish(book *Orderbook) {
+	var bidPrice, bidVolume, askPrice, askVolume float64
+	if !book.Bids.IsEmpty() {
+		limit := book.Bids.MaxValue()
+		bidPrice, bidVolume = limit.Price, limit.TotalVolume()
+	}
+	if !book.Asks.IsEmpty() {
+		limit := book.Asks.MinValue()
+		askPrice, askVolume = limit.Price, limit.TotalVolume()
+	}
+
+	seq := this.seq.Load()
+	this.seq.Store(seq + 1)
+	this.bidPrice.Store(math.Float64bits(bidPrice))
+	this.bidVolume.Store(math.Float64bits(bidVolume))
+	this.askPrice.Store(math.Float64bits(askPrice))
+	this.askVolume.Store(math.Float64bits(askVolume))
+	this.seq.Store(seq + 2)
+}
+
+// consistent copy of the last published top of book
+func (this *TopOfBookSeqlock) Load() TopOfBook {
+	for spins := 0; ; spins += 1 {
+		seq := this.seq.Load()
+		if seq & 1 == 0 {
+			tob := TopOfBook{
+				BidPrice: math.Float64frombits(this.bidPrice.Load()),
+				BidVolume: math.Float64frombits(this.bidVolume.Load()),
+				AskPrice: math.Float64frombits(this.askPrice.Load()),
+				AskVolume: math.Float64frombits(this.askVolume.Load()),
+				Seq: seq / 2,
+			}
+			if this.seq.Load() == seq {
+				return tob
+			}
+		}
+
+		if spins > engineSpins {
+			// the writer has been preempted in the middle of the update
+			spins = 0
+			runtime.Gosched()
+		}
+	}
+}
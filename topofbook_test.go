@@ -0,0 +1,131 @@
+package hftorderbook
+
+import (
+	"math/rand"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestTopOfBookPublish(t *testing.T) {
+	var top TopOfBookSeqlock
+	book := NewOrderbook()
+
+	top.Publish(&book)
+	if tob := top.Load(); tob != (TopOfBook{Seq: 1}) {
+		t.Errorf("empty book should publish zero top of book, got %+v", tob)
+	}
+
+	book.Add(1.0, &Order{Id: 1, Volume: 2, BidOrAsk: true})
+	book.Add(1.0, &Order{Id: 2, Volume: 3, BidOrAsk: true})
+	book.Add(0.5, &Order{Id: 3, Volume: 7, BidOrAsk: true})
+	book.Add(2.0, &Order{Id: 4, Volume: 4, BidOrAsk: false})
+	top.Publish(&book)
+
+	expected := TopOfBook{1.0, 5, 2.0, 4, 2}
+	if tob := top.Load(); tob != expected {
+		t.Errorf("expected %+v, got %+v", expected, tob)
+	}
+}
+
+func TestTopOfBookConcurrentReaders(t *testing.T) {
+	var top TopOfBookSeqlock
+	book := NewOrderbook()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r += 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				// the writer keeps the bid and ask volumes equal
+				tob := top.Load()
+				if tob.Seq > 0 && (tob.BidVolume != tob.AskVolume || tob.AskPrice - tob.BidPrice != 1) {
+					t.Errorf("torn top of book %+v", tob)
+					return
+				}
+				runtime.Gosched()
+			}
+		}()
+	}
+
+	n := 10000
+	for i := 1; i <= n; i += 1 {
+		price := float64(i % 100)
+		bid := &Order{Id: 2*i, Volume: float64(i), BidOrAsk: true}
+		ask := &Order{Id: 2*i + 1, Volume: float64(i), BidOrAsk: false}
+		book.Add(price, bid)
+		book.Add(price + 1, ask)
+		top.Publish(&book)
+		book.Cancel(bid)
+		book.Cancel(ask)
+	}
+	close(stop)
+	wg.Wait()
+
+	if tob := top.Load(); tob.Seq != uint64(n) {
+		t.Errorf("expected %d publications, got %d", n, tob.Seq)
+	}
+}
+
+func TestEngineTopOfBook(t *testing.T) {
+	e := NewEngine(16, 0)
+	e.Start()
+	e.Submit(Command{CommandAdd, 1, true, 1.0, 2})
+	e.Submit(Command{CommandAdd, 2, false, 1.5, 3})
+	e.Submit(Command{CommandCancel, 3, true, 0, 0}) // rejected, not published
+	e.Stop()
+
+	expected := TopOfBook{1.0, 2, 1.5, 3, 2}
+	if tob := e.TopOfBook(); tob != expected {
+		t.Errorf("expected %+v, got %+v", expected, tob)
+	}
+}
+
+// readers of the top of book in parallel with a writer, run with -cpu 1,2,4,8
+// and compare with BenchmarkSyncOrderbookParallelRead
+func BenchmarkTopOfBookParallelRead(b *testing.B) {
+	var top TopOfBookSeqlock
+	book := NewOrderbook()
+	for i := 0; i < 10000; i += 1 {
+		price := rand.Float64()
+		book.Add(price, &Order{Id: i, Volume: 1, BidOrAsk: price < 0.5})
+	}
+	top.Publish(&book)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		o := &Order{Id: -1, Volume: 1, BidOrAsk: true}
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			book.Add(0.25, o)
+			top.Publish(&book)
+			book.Cancel(o)
+			top.Publish(&book)
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			top.Load()
+		}
+	})
+	b.StopTimer()
+
+	close(stop)
+	<-done
+}
@@ -0,0 +1,96 @@
+package hftorderbook
+
+// Point-in-time L2 views of the book over persistent red-black trees,
+// available with OrderbookConfig.Versions
+
+type persistentLevels = PersistentRedBlackBST[float64, Level]
+
+// Immutable L2 view of both sides of the book, the levels are read only
+// through its methods so readers can not change the trees of the book
+type BookVersion struct {
+	bids *persistentLevels
+	asks *persistentLevels
+}
+
+// current levels of the book in O(1), called by the goroutine owning the book,
+// the version can then be handed to any number of readers and stays
+// unchanged while the book goes on
+func (this *Orderbook) Version() BookVersion {
+	if !this.config.Versions {
+		panic("versions need OrderbookConfig.Versions")
+	}
+	return BookVersion{
+		bids: this.bidLevels.Snapshot(),
+		asks: this.askLevels.Snapshot(),
+	}
+}
+
+func (this *Orderbook) refreshVersion(limit *LimitOrder, bidOrAsk bool) {
+	level := Level{
+		Price: limit.Price,
+		Volume: limit.TotalVolume(),
+		Orders: limit.Size(),
+	}
+	if bidOrAsk {
+		this.bidLevels.Put(limit.Price, level)
+	} else {
+		this.askLevels.Put(limit.Price, level)
+	}
+}
+
+func (this *Orderbook) removeVersion(price float64, bidOrAsk bool) {
+	if bidOrAsk {
+		this.bidLevels.Delete(price)
+	} else {
+		this.askLevels.Delete(price)
+	}
+}
+
+func (this *BookVersion) side(bidOrAsk bool) *persistentLevels {
+	if bidOrAsk {
+		return this.bids
+	}
+	return this.asks
+}
+
+// number of levels of the side
+func (this *BookVersion) Len(bidOrAsk bool) int {
+	return this.side(bidOrAsk).Size()
+}
+
+// level at the price, false if there is none
+func (this *BookVersion) Level(bidOrAsk bool, price float64) (Level, bool) {
+	side := this.side(bidOrAsk)
+	if !side.Contains(price) {
+		return Level{}, false
+	}
+	return side.Get(price), true
+}
+
+// levels of the side from the best one until fn returns false
+func (this *BookVersion) Walk(bidOrAsk bool, fn func(level Level) bool) {
+	this.side(bidOrAsk).Walk(bidOrAsk, func(price float64, level Level) bool {
+		return fn(level)
+	})
+}
+
+// top depth levels of each side, all of them if depth <= 0, as Orderbook.L2
+func (this *BookVersion) L2(depth int) L2Book {
+	return L2Book{
+		Bids: versionLevels(this.bids, true, depth),
+		Asks: versionLevels(this.asks, false, depth),
+	}
+}
+
+func versionLevels(side *persistentLevels, bidOrAsk bool, depth int) []Level {
+	if depth <= 0 || depth > side.Size() {
+		depth = side.Size()
+	}
+
+	levels := make([]Level, 0, depth)
+	side.Walk(bidOrAsk, func(price float64, level Level) bool {
+		levels = append(levels, level)
+		return len(levels) < depth
+	})
+	return levels
+}
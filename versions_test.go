@@ -0,0 +1,152 @@
+package hftorderbook
+
+import (
+	"reflect"
+	"math/rand"
+	"testing"
+)
+
+func TestOrderbookVersions(t *testing.T) {
+	book := NewOrderbookWithConfig(OrderbookConfig{Versions: true})
+	versions := make([]BookVersion, 0)
+	views := make([]L2Book, 0)
+
+	orders := make([]*Order, 0)
+	for i := 0; i < 10000; i += 1 {
+		switch {
+		case rand.Intn(4) == 0 && len(orders) > 0:
+			j := rand.Intn(len(orders))
+			book.Cancel(orders[j])
+			orders[j] = orders[len(orders) - 1]
+			orders = orders[:len(orders) - 1]
+		case rand.Intn(4) == 0 && len(orders) > 0:
+			j := rand.Intn(len(orders))
+			o := orders[j]
+			book.Reduce(o, 0.5)
+			if o.Volume <= 0 {
+				orders[j] = orders[len(orders) - 1]
+				orders = orders[:len(orders) - 1]
+			}
+		case rand.Intn(100) == 0 && book.BLength() > 0:
+			limit := book.Bids.MaxValue()
+			kept := orders[:0]
+			for _, o := range orders {
+				if o.Limit != limit {
+					kept = append(kept, o)
+				}
+			}
+			orders = kept
+			book.DeleteBidLimit(limit.Price)
+		default:
+			price := float64(rand.Intn(200)) / 100
+			o := &Order{Id: i, Volume: float64(1 + rand.Intn(3)) / 2, BidOrAsk: price < 1}
+			book.Add(price, o)
+			orders = append(orders, o)
+		}
+
+		if i % 500 == 0 {
+			versions = append(versions, book.Version())
+			views = append(views, book.L2(0))
+		}
+	}
+
+	// every version still shows the book as it was when taken
+	for i := range versions {
+		if got := versions[i].L2(0); !reflect.DeepEqual(got, views[i]) {
+			t.Fatalf("version %d differs from the book it was taken from", i)
+		}
+	}
+	latest := book.Version()
+	if got := latest.L2(5); !reflect.DeepEqual(got, book.L2(5)) {
+		t.Errorf("latest version should match the book")
+	}
+	if latest.Len(true) != book.BLength() || latest.Len(false) != book.ALength() {
+		t.Errorf("latest version should have the book levels")
+	}
+	best := book.GetBestBid()
+	if level, ok := latest.Level(true, best); !ok || level.Volume != book.GetVolumeAtBidLimit(best) {
+		t.Errorf("invalid level at the best bid")
+	}
+	if _, ok := latest.Level(true, -1); ok {
+		t.Errorf("there should be no level at -1")
+	}
+	first := Level{}
+	latest.Walk(false, func(level Level) bool {
+		first = level
+		return false
+	})
+	if first.Price != book.GetBestOffer() {
+		t.Errorf("walk should start from the best offer")
+	}
+
+	// snapshots restore the versions
+	data, _ := book.MarshalBinary()
+	var restored Orderbook
+	restored.config = book.config
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	restoredVersion := restored.Version()
+	if !reflect.DeepEqual(restoredVersion.L2(0), book.L2(0)) {
+		t.Errorf("restored book should have the same version")
+	}
+}
+
+func TestOrderbookVersionsDisabled(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("versions should panic without OrderbookConfig.Versions")
+		}
+	}()
+	book := NewOrderbook()
+	book.Version()
+}
+
+// O(1) version against a deep copy of the book through a binary snapshot
+func BenchmarkOrderbook10kLevelsVersion(b *testing.B) {
+	book := NewOrderbookWithConfig(OrderbookConfig{Versions: true})
+	for i := 0; i < 100000; i += 1 {
+		price := float64(rand.Intn(20000)) / 100
+		book.Add(price, &Order{Id: i, Volume: 1, BidOrAsk: price < 100})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		book.Version()
+	}
+}
+
+func BenchmarkOrderbook10kLevelsDeepCopy(b *testing.B) {
+	book := NewOrderbook()
+	for i := 0; i < 100000; i += 1 {
+		price := float64(rand.Intn(20000)) / 100
+		book.Add(price, &Order{Id: i, Volume: 1, BidOrAsk: price < 100})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		data, _ := book.MarshalBinary()
+		var copied Orderbook
+		copied.UnmarshalBinary(data)
+	}
+}
+
+func BenchmarkOrderbook10kLevelsAddCancelVersions(b *testing.B) {
+	book := NewOrderbookWithConfig(OrderbookConfig{Versions: true})
+	for i := 0; i < 10000; i += 1 {
+		book.Add(float64(i) / 100, &Order{Id: i, Volume: 1, BidOrAsk: true})
+	}
+	orders := make([]Order, 1024)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		if i % 100 == 0 {
+			book.Version()
+		}
+		o := &orders[i % len(orders)]
+		o.Volume = 1
+		o.BidOrAsk = true
+		book.Add(float64(rand.Intn(10000)) / 100, o)
+		book.Cancel(o)
+	}
+}
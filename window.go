@@ -0,0 +1,84 @@
+package hftorderbook
+
+import (
+	"errors"
+)
+
+// Depth window of OrderbookConfig.MaxLevels: a full side evicts its worst
+// level for an order at a better new price. Levels dropped this way do not
+// come back when the side shrinks, as in top N depth feeds.
+
+var ErrOutOfWindow = errors.New("order price is out of the depth window")
+
+// behaviour for an order at a new price worse than all levels of a full side
+type WindowPolicy uint8
+
+const (
+	WindowIgnore WindowPolicy = iota // the order is left out of the book, TryAdd returns nil
+	WindowReject // TryAdd returns ErrOutOfWindow, Add panics
+)
+
+// evicts the worst level if the side is full and the price is better,
+// false if the price is out of the window
+func (this *Orderbook) makeRoom(price float64, bidOrAsk bool) bool {
+	side := this.Asks
+	if bidOrAsk {
+		side = this.Bids
+	}
+	if side.Size() < this.config.MaxLevels {
+		return true
+	}
+
+	var worst *LimitOrder
+	if bidOrAsk {
+		worst = side.MinValue()
+		if price <= worst.Price {
+			return false
+		}
+	} else {
+		worst = side.MaxValue()
+		if price >= worst.Price {
+			return false
+		}
+	}
+	this.evict(worst, bidOrAsk)
+	return true
+}
+
+// removes the limit and detaches its orders, they stay Live until cancelled
+// or reduced to nothing, which only hands them back to the free list if they
+// came from there
+func (this *Orderbook) evict(limit *LimitOrder, bidOrAsk bool) {
+	if this.config.OnEvict != nil {
+		this.config.OnEvict(bidOrAsk, limit)
+	}
+	if this.listeners != nil {
+		this.emit(EventDeleteLimit, bidOrAsk, limit.Price, 0, limit.TotalVolume())
+	}
+
+	this.deleteLimit(limit.Price, bidOrAsk)
+	if bidOrAsk {
+		delete(this.bidLimitsCache, limit.Price)
+	} else {
+		delete(this.askLimitsCache, limit.Price)
+	}
+
+	for o := limit.Front(); o != nil; {
+		next := limit.Next(o)
+		o.Limit = nil
+		o.Next = nil
+		o.Prev = nil
+		o.detached = true
+		o = next
+	}
+	limit.Clear()
+	this.limits.put(limit)
+}
+
+// order out of the book, dropped by the depth window
+func (this *Orderbook) dropDetached(o *Order) {
+	o.detached = false
+	if o.pooled {
+		this.orders.put(o)
+	}
+}
@@ -0,0 +1,182 @@
+package hftorderbook
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestOrderbookWindowEviction(t *testing.T) {
+	evicted := make([]float64, 0)
+	evictedOrders := 0
+	book := NewOrderbookWithConfig(OrderbookConfig{
+		MaxLevels: 3,
+		OnEvict: func(bidOrAsk bool, limit *LimitOrder) {
+			if !bidOrAsk {
+				t.Errorf("only bids should be evicted")
+			}
+			evicted = append(evicted, limit.Price)
+			evictedOrders += limit.Size()
+		},
+	})
+	events := make([]Event, 0)
+	book.Subscribe(func(e Event) {
+		events = append(events, e)
+	})
+
+	orders := make([]*Order, 0)
+	for i, price := range []float64{10, 11, 12, 10, 13, 9, 14} {
+		o := &Order{Id: i, Volume: 1, BidOrAsk: true}
+		if err := book.TryAdd(price, o); err != nil {
+			t.Fatal(err)
+		}
+		orders = append(orders, o)
+	}
+
+	// 13 evicts 10 with its two orders, 9 is ignored, 14 evicts 11
+	if book.Bids.Size() != 3 || book.Bids.Min() != 12 || book.GetBestBid() != 14 {
+		t.Errorf("bids should keep the top 3 levels")
+	}
+	if len(evicted) != 2 || evicted[0] != 10 || evicted[1] != 11 || evictedOrders != 3 {
+		t.Errorf("invalid evictions %v", evicted)
+	}
+	for _, i := range []int{0, 1, 3, 5} {
+		if orders[i].Limit != nil || !orders[i].Live() {
+			t.Errorf("order %d should be out of the book and still live", i)
+		}
+	}
+	if book.BLength() != 3 || book.GetVolumeAtBidLimit(10) != 0 {
+		t.Errorf("evicted limits should leave the cache")
+	}
+
+	// the eviction is published before the order taking its place
+	deleted := 0
+	for i, e := range events {
+		if e.Type == EventDeleteLimit {
+			deleted += 1
+			if next := events[i + 1]; next.Type != EventAdd || next.Price <= e.Price {
+				t.Errorf("eviction should be followed by the better order")
+			}
+		}
+	}
+	if deleted != 2 || len(events) != 8 {
+		t.Errorf("expected 2 evictions among 8 events, got %d among %d", deleted, len(events))
+	}
+
+	// detached orders can be cancelled and reduced as usual
+	book.Cancel(orders[0])
+	book.Reduce(orders[5], 0.5)
+	if orders[5].Volume != 0.5 || len(events) != 8 {
+		t.Errorf("detached orders should change without events")
+	}
+
+	// the side shrinks without bringing evicted levels back
+	book.Cancel(orders[6])
+	if book.Bids.Size() != 2 || book.GetBestBid() != 13 {
+		t.Errorf("evicted levels should not come back")
+	}
+	o := &Order{Id: 100, Volume: 1, BidOrAsk: true}
+	book.Add(9, o)
+	if book.Bids.Size() != 3 || book.Bids.Min() != 9 {
+		t.Errorf("a side with room should take any price")
+	}
+}
+
+func TestOrderbookWindowReject(t *testing.T) {
+	book := NewOrderbookWithConfig(OrderbookConfig{MaxLevels: 2, OutOfWindow: WindowReject})
+	book.Add(10, &Order{Id: 1, Volume: 1})
+	book.Add(11, &Order{Id: 2, Volume: 1})
+
+	o := &Order{Id: 3, Volume: 1}
+	if err := book.TryAdd(12, o); !errors.Is(err, ErrOutOfWindow) || o.Live() {
+		t.Errorf("ask worse than the window should be rejected, got %v", err)
+	}
+	if err := book.TryAdd(11, o); err != nil || book.GetVolumeAtAskLimit(11) != 2 {
+		t.Errorf("orders at existing levels should be accepted")
+	}
+	if err := book.TryAdd(9, &Order{Id: 4, Volume: 1}); err != nil || book.Asks.Max() != 10 {
+		t.Errorf("better ask should evict the worst one")
+	}
+}
+
+// pooled orders of evicted levels go back to the free list on cancel
+func TestOrderbookWindowPooledOrders(t *testing.T) {
+	book := NewOrderbookWithConfig(OrderbookConfig{MaxLevels: 100, Limits: 100, Exhaustion: PoolFail})
+	orders := make([]*Order, 0)
+	for i := 0; i < 10000; i += 1 {
+		price := float64(rand.Intn(1000))
+		o := book.NewOrder(i, 1, false)
+		if err := book.TryAdd(price, o); err != nil {
+			t.Fatal(err)
+		}
+		orders = append(orders, o)
+	}
+	if book.Asks.Size() != 100 {
+		t.Errorf("asks should keep 100 levels, got %d", book.Asks.Size())
+	}
+
+	for _, o := range orders {
+		book.Cancel(o)
+	}
+	if book.OrdersInUse() != 0 || book.Asks.Size() != 0 {
+		t.Errorf("all orders should be back in the free list, %d in use", book.OrdersInUse())
+	}
+}
+
+// partial fills of evicted and ignored orders keep them tracked by the feed
+func TestItchFeedWindow(t *testing.T) {
+	feed := NewItchFeedWithConfig(OrderbookConfig{MaxLevels: 2})
+	stream := itchStream(
+		itchAdd(1, 100, 'B', 300, 1000000),
+		itchAdd(1, 101, 'B', 300, 1010000),
+		itchAdd(1, 102, 'B', 300, 1020000), // evicts 100
+		itchAdd(1, 103, 'B', 300, 990000), // ignored
+		itchExecuted(1, 100, 100),
+		itchCancel(1, 103, 100),
+		itchDelete(1, 100),
+		itchExecuted(1, 103, 200),
+		itchExecuted(1, 101, 300),
+	)
+	if err := feed.Replay(bytes.NewReader(stream)); err != nil {
+		t.Fatal(err)
+	}
+
+	book := feed.Book(1)
+	if book.BLength() != 1 || book.GetBestBid() != 102 {
+		t.Errorf("only the bid at 102 should be left")
+	}
+	if feed.Order(100) != nil || feed.Order(101) != nil || feed.Order(103) != nil || feed.Order(102) == nil {
+		t.Errorf("feed should track the live orders only")
+	}
+}
+
+// orders rejected by the window are not tracked and their messages are skipped
+func TestItchFeedWindowReject(t *testing.T) {
+	feed := NewItchFeedWithConfig(OrderbookConfig{MaxLevels: 2, OutOfWindow: WindowReject})
+	stream := itchStream(
+		itchAdd(1, 100, 'B', 300, 1000000),
+		itchAdd(1, 101, 'B', 300, 1010000),
+		itchAdd(1, 102, 'B', 300, 990000), // rejected
+		itchExecuted(1, 102, 100),
+		itchDelete(1, 102),
+		itchExecuted(1, 100, 100),
+	)
+	if err := feed.Replay(bytes.NewReader(stream)); err != nil {
+		t.Fatal(err)
+	}
+
+	book := feed.Book(1)
+	if book.BLength() != 2 || book.GetVolumeAtBidLimit(100) != 200 || book.GetVolumeAtBidLimit(101) != 300 {
+		t.Errorf("bids should keep the two levels in the window")
+	}
+	if feed.Order(102) != nil || feed.Len() != 2 {
+		t.Errorf("rejected order should not be tracked")
+	}
+
+	// without a window unknown references are still errors
+	feed = NewItchFeed()
+	if err := feed.Replay(bytes.NewReader(itchStream(itchDelete(1, 102)))); err == nil {
+		t.Errorf("unknown reference should be an error")
+	}
+}